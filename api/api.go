@@ -1,30 +1,141 @@
 // Реализация функций API
 package api
 
-import "fmt"
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"net/http"
+	"strings"
+)
 
 // Коды ошибок API
 type APIErrorCode int
 
 const (
 	OK                  = 200
+	Created             = 201
 	BadRequest          = 400
+	Unauthorized        = 401
+	Forbidden           = 403
+	NotFound            = 404
+	Conflict            = 409
 	InternalServerError = 500
 )
 
 // Описание ошибок API
 var APIErrorDesc = [...]string{
 	OK:                  "OK",
+	Created:             "Created",               // элемент создан
 	BadRequest:          "Bad Request",           // некорректный запрос
+	Unauthorized:        "Unauthorized",          // сессия не открыта или истекла, неверный логин или пароль
+	Forbidden:           "Forbidden",             // у пользователя сессии нет прав на запрос
+	NotFound:            "Not Found",             // элемент с заданным ID не найден
+	Conflict:            "Conflict",              // запрос противоречит состоянию данных: дубликат, недопустимый переход статуса
 	InternalServerError: "Internal server error", // внутренняя ошибка сервера
 }
 
+// Машиночитаемые коды ошибок для APIError.ErrorCode
+var APIErrorNames = [...]string{
+	BadRequest:          "bad_request",
+	Unauthorized:        "unauthorized",
+	Forbidden:           "forbidden",
+	NotFound:            "not_found",
+	Conflict:            "conflict",
+	InternalServerError: "internal_error",
+}
+
+const (
+	ErrValidation = "validation_failed" // неверные параметры запроса, список - в APIError.Fields
+	ErrReference  = "invalid_reference" // ссылка на несуществующий элемент
+)
+
+// Коды ошибок параметров запроса для APIFieldError.Code
+const (
+	FieldMissing   = "missing"   // не задан обязательный параметр
+	FieldInvalid   = "invalid"   // значение не удается распознать или оно недопустимо
+	FieldUnknown   = "unknown"   // параметр не предусмотрен запросом
+	FieldReference = "not_found" // элемент, на который ссылается параметр, не найден
+)
+
+// APIError - ошибка с кодом ответа, код ошибки заменяет код, заданный обработчиком
+// Выводится в Answer.Error для любой ошибки
+type APIError struct {
+	Code      APIErrorCode    `json:"-"`
+	ErrorCode string          `json:"code"`
+	Message   string          `json:"message"`
+	Fields    []APIFieldError `json:"fields,omitempty"`
+}
+
+// APIFieldError - ошибка в параметре запроса
+type APIFieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newAPIError - ошибка с машиночитаемым кодом по коду ответа
+func newAPIError(code APIErrorCode, message string) *APIError {
+	e := &APIError{Code: code, ErrorCode: APIErrorNames[BadRequest], Message: message}
+	if int(code) < len(APIErrorNames) && APIErrorNames[code] != "" {
+		e.ErrorCode = APIErrorNames[code]
+	}
+	return e
+}
+
+// validationError - ошибка в параметрах запроса, сообщение составляется из сообщений для отдельных параметров
+func validationError(fields []APIFieldError) error {
+	var messages []string
+	for _, f := range fields {
+		messages = append(messages, f.Message)
+	}
+	return &APIError{Code: BadRequest, ErrorCode: ErrValidation, Message: strings.Join(messages, "; "), Fields: fields}
+}
+
+// invalidField - ошибка в значении одного параметра запроса, выявленная после RequestParams.Parse
+func invalidField(field string, format string, a ...interface{}) error {
+	return validationError([]APIFieldError{{Field: field, Code: FieldInvalid, Message: fmt.Sprintf(format, a...)}})
+}
+
+// notFound - ошибка "не найден" для проверок, общих для нескольких обработчиков
+func notFound(format string, a ...interface{}) error {
+	return newAPIError(NotFound, fmt.Sprintf(format, a...))
+}
+
+// conflict - ошибка "конфликт с состоянием данных" для проверок, общих для нескольких обработчиков
+func conflict(format string, a ...interface{}) error {
+	return newAPIError(Conflict, fmt.Sprintf(format, a...))
+}
+
+// unauthorized - ошибка "требуется вход пользователя"
+func unauthorized(format string, a ...interface{}) error {
+	return newAPIError(Unauthorized, fmt.Sprintf(format, a...))
+}
+
+// forbidden - ошибка "нет прав на запрос"
+func forbidden(format string, a ...interface{}) error {
+	return newAPIError(Forbidden, fmt.Sprintf(format, a...))
+}
+
 // Ответ. Используется как возвращаемое значение для Get/Put/Post/Delete команд
 type Answer struct {
 	Code    APIErrorCode
 	Message string
 	ID      int64 // id измененного(возвращаемого) элемента
 	Result  interface{}
+	Error   *APIError `json:",omitempty"`
+	Total   *int64    `json:",omitempty"` // Количество элементов списка без учета limit и offset
+
+	// Ответ не в JSON: документ, чертеж и т.п. Выводится вместо Answer, если запрос выполнен без ошибок
+	ContentType string `json:"-"`
+	Content     []byte `json:"-"`
+	Filename    string `json:"-"` // Имя файла для сохранения документа, пустое - документ открывается в браузере
+
+	Cookie *http.Cookie `json:"-"` // Cookie сессии, задается при входе и выходе пользователя
 }
 
 type HTTPCallbackFunc func([]string, map[string][]string) Answer
@@ -37,13 +148,34 @@ type HTTPCallbackSet struct {
 }
 
 // make - defer функция, заполняющая ответ обработчика команды в конце каждого обработчика
+// Код ошибки без кода обработчика: нет строки в БД - NotFound, нарушение уникальности - Conflict,
+// ссылка на несуществующий элемент - BadRequest, иначе InternalServerError
 func (a *Answer) make(err *error, result interface{}) {
 	if (*err) != nil {
-		if a.Code == 0 {
+		e, ok := (*err).(*APIError)
+		switch {
+		case ok:
+			a.Code = e.Code
+		case a.Code != 0:
+		case *err == sql.ErrNoRows:
+			a.Code = NotFound
+			*err = fmt.Errorf("Элемент '%d' не найден", a.ID)
+		case db.IsConflict(*err):
+			a.Code = Conflict
+		case db.IsForeignKey(*err):
+			a.Code = BadRequest
+			e = &APIError{Code: BadRequest, ErrorCode: ErrReference, Message: "Задана ссылка на несуществующий элемент"}
+			*err = e
+		default:
 			a.Code = InternalServerError
 		}
+		if e == nil {
+			e = newAPIError(a.Code, (*err).Error())
+		}
 		a.Message = (*err).Error()
 		a.Result = nil
+		a.Total = nil
+		a.Error = e
 	} else {
 		a.Code = OK
 		a.Message = ""
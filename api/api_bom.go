@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"knx/report"
+	"math"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/bom[?format=xlsx|csv]
+//
+// Answer: спецификация материалов проекта в формате XLSX (по умолчанию) или CSV с разделителем ';', при ошибке - JSON
+// Первый лист - сводная спецификация, одинаковая номенклатура всех участков суммируется, затем по листу на каждый участок
+// Колонки: vendor_code, name, measure_unit, quantity, price, total. Цены в рублях на дату договора
+//
+func GetProjectBOM(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"format": {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	format := "xlsx"
+	if rp["format"].Exists() {
+		format = rp["format"].Value.StringValue
+	}
+	if err == nil && format != "xlsx" && format != "csv" {
+		err = invalidField("format", "Неверный формат '%s', ожидается xlsx или csv", format)
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	a := GetResultsOfProject(request, nil)
+	if a.Code != OK {
+		answer.Code, err = a.Code, errors.New(a.Message)
+		return
+	}
+	estimate := a.Result.(*APIProjectEstimate)
+
+	// Summary sheet and a sheet per region
+	var all []APIResult
+	var regions []report.Table
+	for i, r := range estimate.Regions {
+		name := r.Region.Description
+		if name == "" {
+			name = r.Region.RegionType.UserName
+		}
+		regions = append(regions, bomTable(fmt.Sprintf("%d %s", i+1, name), r.Results))
+		all = append(all, r.Results...)
+	}
+	tables := append([]report.Table{bomTable("Итого", all)}, regions...)
+
+	answer.Filename = fmt.Sprintf("bom_%d.%s", answer.ID, format)
+	if format == "csv" {
+		answer.ContentType = "text/csv; charset=utf-8"
+		answer.Content, err = report.CSV(tables)
+		return
+	}
+	answer.ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	answer.Content, err = report.XLSX(tables)
+	return
+}
+
+// bomLine - позиция спецификации
+type bomLine struct {
+	nomenclature *APINomenclature
+	quantity     float64
+	price        *APIPrice
+	total        int64
+}
+
+// bomTable - лист спецификации из результатов расчета с номенклатурой, одинаковая номенклатура суммируется
+// Последняя строка - итог по ценам
+func bomTable(name string, results []APIResult) report.Table {
+	var lines []bomLine
+	index := make(map[int64]int)
+	for _, r := range results {
+		if r.Nomenclature == nil {
+			continue
+		}
+		quantity, _ := strconv.ParseFloat(r.Value, 64)
+		if quantity == 0 {
+			continue
+		}
+		i, ok := index[r.Nomenclature.ID]
+		if !ok {
+			i = len(lines)
+			index[r.Nomenclature.ID] = i
+			lines = append(lines, bomLine{nomenclature: r.Nomenclature, price: r.Price})
+		}
+		lines[i].quantity += quantity
+		lines[i].total += r.Total
+	}
+
+	t := report.Table{Name: name, Header: []string{"vendor_code", "name", "measure_unit", "quantity", "price", "total"}}
+	var total int64
+	for _, l := range lines {
+		row := []interface{}{l.nomenclature.VendorCode, l.nomenclature.Name, l.nomenclature.MeasureUnit,
+			math.Round(l.quantity*1000) / 1000, nil, nil}
+		if l.price != nil {
+			row[4], row[5] = report.Kopecks(l.price.Price), report.Kopecks(l.total)
+			total += l.total
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	t.Rows = append(t.Rows, []interface{}{"", "Итого", "", nil, nil, report.Kopecks(total)})
+	return t
+}
@@ -1,19 +1,124 @@
 package api
 
+import (
+	"database/sql"
+	"fmt"
+	"knx/calc"
+	"knx/db"
+	"strconv"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // APICalculationType
 
 type APICalculationType struct {
-	ID       int64  `json:"id,omitempty"`
-	UserName string `json:"user_name,omitempty"`
-	CodeName string `json:"code_name,omitempty"`
+	ID       int64                  `json:"id,omitempty"`
+	UserName string                 `json:"user_name,omitempty"`
+	CodeName string                 `json:"code_name,omitempty"`
+	Inputs   []APICalculationInput  `json:"inputs,omitempty"`
+	Outputs  []APICalculationOutput `json:"outputs,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APICalculationInput
+type APICalculationInput struct {
+	Name              string `json:"name,omitempty"`
+	Unit              string `json:"unit,omitempty"`
+	Kind              string `json:"kind,omitempty"`
+	ParamTypeID       *int64 `json:"param_type_id,omitempty"`
+	CalculationTypeID *int64 `json:"calculation_type_id,omitempty"`
+	Output            string `json:"output,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APICalculationOutput
+type APICalculationOutput struct {
+	Name         string `json:"name,omitempty"`
+	Unit         string `json:"unit,omitempty"`
+	ResultTypeID int64  `json:"result_type_id"`
+	List         bool   `json:"list,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Answer:
+//	{
+//		id        int
+//		user_name string
+//		code_name string
+//		inputs [
+//			{
+//				name                string
+//				unit                string
+//				kind                string /*param, result, region_type, nomenclature*/
+//				param_type_id       int    /*kind = param*/
+//				calculation_type_id int    /*kind = result: расчет, результат которого используется*/
+//				output              string /*kind = result: имя результата*/
+//			}
+//		]
+//		outputs [
+//			{
+//				name           string
+//				unit           string
+//				result_type_id int
+//				list           bool /*список значений произвольной длины*/
+//			}
+//		]
+//	}
+
+// makeCalculationType - описание контракта зарегистрированного расчета
+func makeCalculationType(id calc.MaterialCalculationID, userName string) (t APICalculationType) {
+	mc := calc.MaterialCalculations[id]
+	t = APICalculationType{ID: int64(id), UserName: userName, CodeName: mc.Name}
+	for _, in := range mc.Inputs {
+		i := APICalculationInput{Name: in.Name, Unit: in.Unit, Kind: calc.InputKindNames[in.Kind]}
+		switch in.Kind {
+		case calc.IKParam:
+			paramID := int64(in.Param)
+			i.ParamTypeID = &paramID
+		case calc.IKResult:
+			calculationID := int64(in.Calculation)
+			i.CalculationTypeID = &calculationID
+			i.Output = in.Output
+		}
+		t.Inputs = append(t.Inputs, i)
+	}
+	for _, out := range mc.Outputs {
+		t.Outputs = append(t.Outputs, APICalculationOutput{Name: out.Name, Unit: out.Unit, ResultTypeID: int64(out.Result), List: out.List})
+	}
+	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /calculation_types
 //
 func GetCalculationTypes(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetCalculationTypes"
+	var err error
+	var res []APICalculationType
+	defer answer.make(&err, &res)
+
+	userNames := make(map[int64]string)
+	var rows *sql.Rows
+	rows, err = db.DB.Query("SELECT id, name FROM tcalculation")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return
+		}
+		userNames[id] = name
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	for _, id := range calc.MaterialCalculationIDs() {
+		res = append(res, makeCalculationType(id, userNames[int64(id)]))
+	}
 	return
 }
 
@@ -21,14 +126,58 @@ func GetCalculationTypes(request []string, params map[string][]string) (answer A
 // Request: GET /calculation_types/<id>
 //
 func GetCalculationType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetCalculationType"
+	var err error
+	var res APICalculationType
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if _, ok := calc.MaterialCalculations[calc.MaterialCalculationID(answer.ID)]; err != nil || !ok {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Get user name from db
+	var userName string
+	err = db.DB.QueryRow("SELECT name FROM tcalculation WHERE id=?", answer.ID).Scan(&userName)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
+	res = makeCalculationType(calc.MaterialCalculationID(answer.ID), userName)
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: GET /calculation_types/<id>?name=<Value>
+// Request: POST /calculation_types/<id>?name=<Value>
 //
 func PostCalculationType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PostCalculationType"
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if _, ok := calc.MaterialCalculations[calc.MaterialCalculationID(answer.ID)]; err != nil || !ok {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"name": {Optional: false, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Update [tcalculation]
+	sqlText, sqlParams := rp.MakeSQLUpdate("tcalculation", []string{"name"}, answer.ID)
+	_, err = db.DB.Exec(sqlText, sqlParams...)
 	return
 }
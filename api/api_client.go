@@ -17,16 +17,42 @@ type APIClient struct {
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: GET /clients
+// Request: GET /clients[?name=<value>][?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
+// name - подстрока имени, сортировка: id, name
 //
 func GetClients(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	var res []APIClient
 	defer answer.make(&err, &res)
 
+	var rp RequestParams = RequestParams{
+		"name": {Optional: true, Type: String},
+	}.WithListParams()
+
+	err = rp.Parse(params)
+	if err != nil {
+		return
+	}
+
+	sqlText, countText, sqlParams, err := rp.MakeSQLSelect(ListQuery{
+		Select:     "SELECT id, name, phone, comment",
+		From:       "FROM client",
+		Substrings: map[string]string{"name": "name"},
+		Sort:       map[string]string{"id": "id", "name": "name"},
+		Order:      "id",
+	})
+	if err != nil {
+		return
+	}
+	answer.Total = new(int64)
+	err = db.DB.QueryRow(countText, sqlParams...).Scan(answer.Total)
+	if err != nil {
+		return
+	}
+
 	// Select data from table [client]
 	var rows *sql.Rows
-	rows, err = db.DB.Query("SELECT id, name, phone, comment FROM client")
+	rows, err = db.DB.Query(sqlText, sqlParams...)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"database/sql"
+	"knx/db"
+	"knx/report"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// APICompany - реквизиты компании и настройки документов из meta
+type APICompany struct {
+	Name           string `json:"name"`
+	Address        string `json:"address"`
+	Phone          string `json:"phone"`
+	Email          string `json:"email"`
+	OfferValidDays int64  `json:"offer_valid_days"` // Срок действия коммерческого предложения, в днях
+	PDFFont        string `json:"pdf_font"`         // Путь к шрифту TrueType для PDF, пустой - поиск шрифта
+}
+
+// companyMeta - ключи meta параметров запроса /company
+var companyMeta map[string]string = map[string]string{
+	"name":             db.MetaKeyCompanyName,
+	"address":          db.MetaKeyCompanyAddress,
+	"phone":            db.MetaKeyCompanyPhone,
+	"email":            db.MetaKeyCompanyEmail,
+	"offer_valid_days": db.MetaKeyOfferValidDays,
+	"pdf_font":         db.MetaKeyPDFFont,
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /company
+//
+// Answer:
+//{
+//	name              string
+//	address           string
+//	phone             string
+//	email             string
+//	offer_valid_days  int    /*Срок действия коммерческого предложения, в днях*/
+//	pdf_font          string /*Путь к шрифту TrueType для PDF, пустой - поиск шрифта*/
+//}
+func GetCompany(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APICompany
+	defer answer.make(&err, &res)
+
+	values := make(map[string]string)
+	for _, key := range companyMeta {
+		values[key], err = db.Meta(key)
+		if err != nil {
+			return
+		}
+	}
+
+	res.Name = values[db.MetaKeyCompanyName]
+	res.Address = values[db.MetaKeyCompanyAddress]
+	res.Phone = values[db.MetaKeyCompanyPhone]
+	res.Email = values[db.MetaKeyCompanyEmail]
+	res.OfferValidDays, _ = strconv.ParseInt(values[db.MetaKeyOfferValidDays], 10, 64)
+	res.PDFFont = values[db.MetaKeyPDFFont]
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: POST /company[?name=<value>][?address=<value>][?phone=<value>][?email=<value>][?offer_valid_days=<value>][?pdf_font=<value>]
+// Только администратору
+//
+func PostCompany(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	_, err = sessionAdmin(params)
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"name":             {Optional: true, Type: String},
+		"address":          {Optional: true, Type: String},
+		"phone":            {Optional: true, Type: String},
+		"email":            {Optional: true, Type: String},
+		"offer_valid_days": {Optional: true, Type: Int},
+		"pdf_font":         {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err == nil && rp["offer_valid_days"].Exists() && rp["offer_valid_days"].Value.IntValue < 0 {
+		err = invalidField("offer_valid_days", "Срок действия предложения не может быть отрицательным")
+	}
+	if err == nil && rp["pdf_font"].Exists() && rp["pdf_font"].Value.StringValue != "" {
+		if _, e := report.LoadFont(rp["pdf_font"].Value.StringValue); e != nil {
+			err = invalidField("pdf_font", "%v", e)
+		}
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Update [meta]
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for name, key := range companyMeta {
+		if p := rp[name]; p.Exists() {
+			value := p.Value.StringValue
+			if p.Type == Int {
+				value = strconv.FormatInt(p.Value.IntValue, 10)
+			}
+			err = db.SetMeta(tx, key, value)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
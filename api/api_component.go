@@ -1,5 +1,12 @@
 package api
 
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"strconv"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // APIComponentSection
 type APIComponentSection struct {
@@ -20,7 +27,32 @@ type APIComponent struct {
 // Request: GET /projects/<id>/regions/<id>/components
 //
 func GetComponentsOfRegion(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetComponentsOfRegion"
+	var err error
+	var res []APIComponent
+	defer answer.make(&err, &res)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	res, err = getComponents(answer.ID, nil)
 	return
 }
 
@@ -28,7 +60,50 @@ func GetComponentsOfRegion(request []string, params map[string][]string) (answer
 // Request: GET /projects/<id>/regions/<id>/components/<id>
 //
 func GetComponent(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetComponent"
+	var err error
+	var res APIComponent
+	defer answer.make(&err, &res)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var regionID int64
+	regionID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, regionID)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[5], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID компонента '%s'", request[5])
+		return
+	}
+
+	var components []APIComponent
+	components, err = getComponents(regionID, &answer.ID)
+	if err != nil {
+		return
+	}
+	if len(components) == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Компонент '%d' не найден на участке '%d'", answer.ID, regionID)
+		return
+	}
+	res = components[0]
 	return
 }
 
@@ -36,7 +111,72 @@ func GetComponent(request []string, params map[string][]string) (answer Answer)
 // Request: PUT /projects/<id>/regions/<id>/components?component_type=<id>,<id>,...
 //
 func PutComponent(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PutComponent"
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err == nil {
+		err = checkProjectNotFrozen(projectID)
+	}
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"component_type": {Optional: false, Type: IntArray},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+	componentTypeIDs := rp["component_type"].Value.IntArray
+
+	err = checkComponentTypes(answer.ID, componentTypeIDs)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Components are added and materials recalculated in one transaction
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Add components with their parts
+	err = db.AddComponents(tx, answer.ID, componentTypeIDs)
+	if err != nil {
+		return
+	}
+
+	// Recalculate materials with the new set of components
+	err = db.CalculateRegion(tx, answer.ID)
 	return
 }
 
@@ -44,7 +184,91 @@ func PutComponent(request []string, params map[string][]string) (answer Answer)
 // Request: POST /projects/<id>/regions/<id>/components?component_type=<id>,<id>,...
 //
 func PostComponent(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PostComponent"
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err == nil {
+		err = checkProjectNotFrozen(projectID)
+	}
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"component_type": {Optional: false, Type: IntArray},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+	componentTypeIDs := rp["component_type"].Value.IntArray
+
+	err = checkComponentTypes(answer.ID, componentTypeIDs)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Components are replaced and materials recalculated in one transaction
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Replace the set of components: delete the components not in the list, add the new ones
+	var current []int64
+	current, err = db.ComponentTypesOfRegion(tx, answer.ID)
+	if err != nil {
+		return
+	}
+	keep := make(map[int64]bool)
+	for _, id := range componentTypeIDs {
+		keep[id] = true
+	}
+	var deleted []int64
+	for _, id := range current {
+		if !keep[id] {
+			deleted = append(deleted, id)
+		}
+	}
+	err = db.DeleteComponents(tx, answer.ID, deleted)
+	if err != nil {
+		return
+	}
+	err = db.AddComponents(tx, answer.ID, componentTypeIDs)
+	if err != nil {
+		return
+	}
+
+	// Recalculate materials with the new set of components
+	err = db.CalculateRegion(tx, answer.ID)
 	return
 }
 
@@ -52,6 +276,134 @@ func PostComponent(request []string, params map[string][]string) (answer Answer)
 // Request: DELETE /projects/<id>/regions/<id>/components?component_type=<id>,<id>,...
 //
 func DeleteComponent(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "DeleteComponent"
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err == nil {
+		err = checkProjectNotFrozen(projectID)
+	}
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"component_type": {Optional: false, Type: IntArray},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+	componentTypeIDs := rp["component_type"].Value.IntArray
+
+	err = checkComponentTypes(answer.ID, componentTypeIDs)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Components are deleted and materials recalculated in one transaction
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Delete components, their parts are deleted by cascade
+	err = db.DeleteComponents(tx, answer.ID, componentTypeIDs)
+	if err != nil {
+		return
+	}
+
+	// Recalculate materials with the new set of components
+	err = db.CalculateRegion(tx, answer.ID)
+	return
+}
+
+// checkRegionOfProject - проверка, что участок принадлежит проекту
+func checkRegionOfProject(projectID, regionID int64) (err error) {
+	var count int
+	err = db.DB.QueryRow("SELECT count(*) FROM region WHERE id=? AND project_id=?", regionID, projectID).Scan(&count)
+	if err == nil && count == 0 {
+		err = notFound("Участок '%d' не найден в проекте '%d'", regionID, projectID)
+	}
+	return
+}
+
+// checkComponentTypes - проверка, что типы компонентов доступны для типа участка
+func checkComponentTypes(regionID int64, componentTypeIDs []int64) (err error) {
+	for _, id := range componentTypeIDs {
+		var count int
+		err = db.DB.QueryRow(`SELECT count(*) FROM region r
+			INNER JOIN cn_tregion_tcomponent cn ON cn.tregion_id = r.tregion_id
+			WHERE r.id=? AND cn.tcomponent_id=?`, regionID, id).Scan(&count)
+		if err != nil {
+			return
+		}
+		if count == 0 {
+			return fmt.Errorf("Тип компонента '%d' недоступен для участка '%d'", id, regionID)
+		}
+	}
+	return
+}
+
+// getComponents - компоненты участка с типами частей, componentID - только указанный компонент
+func getComponents(regionID int64, componentID *int64) (res []APIComponent, err error) {
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT c.id, t.id, t.name, tp.id, tp.name, ifnull(tp.tcalculation_id, 0), tp.formula
+		FROM component c INNER JOIN tcomponent t ON c.tcomponent_id = t.id
+		LEFT JOIN part p ON p.component_id = c.id
+		LEFT JOIN tpart tp ON p.tpart_id = tp.id
+		WHERE c.region_id = ? AND (? IS NULL OR c.id = ?)
+		ORDER BY c.id, p.id`, regionID, componentID, componentID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c APIComponent
+		var ct APIComponentType
+		var partID *int64
+		var partName, formula *string
+		var calculationTypeID *int64
+		err = rows.Scan(&c.ID, &ct.ID, &ct.Name, &partID, &partName, &calculationTypeID, &formula)
+		if err != nil {
+			return
+		}
+		if len(res) == 0 || res[len(res)-1].ID != c.ID {
+			c.ComponentType = &ct
+			res = append(res, c)
+		}
+		if partID != nil {
+			last := &res[len(res)-1]
+			last.PartTypes = append(last.PartTypes, APIPartType{ID: *partID, Name: *partName, CalculationTypeID: *calculationTypeID, Formula: *formula})
+		}
+	}
+	err = rows.Err()
 	return
 }
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"knx/calc"
+	"knx/db"
+	"knx/report"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/regions/<id>/drawing.svg
+//
+// Answer: чертеж участка в формате SVG (image/svg+xml), при ошибке - JSON
+// Развертка забора вдоль отрезков участка: столбы, прожилины, стыки листов, цвета покраски, размеры пролетов и высоты
+//
+func GetRegionDrawing(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	var e report.Elevation
+	e, err = regionElevation(answer.ID)
+	if err != nil {
+		return
+	}
+
+	answer.ContentType = "image/svg+xml"
+	answer.Content = e.SVG()
+	return
+}
+
+// regionElevation - исходные данные чертежа участка из параметров, отрезков и результатов расчета
+func regionElevation(regionID int64) (e report.Elevation, err error) {
+	var regionType calc.RegionTypeID
+	var description, typeName string
+	err = db.DB.QueryRow(`SELECT r.tregion_id, r.description, t.name FROM region r
+		INNER JOIN tregion t ON t.id = r.tregion_id WHERE r.id=?`, regionID).Scan(&regionType, &description, &typeName)
+	if err != nil {
+		return
+	}
+	e.Title = typeName
+	if description != "" {
+		e.Title += ": " + description
+	}
+
+	// Parameters of the region
+	p := make(map[calc.ParamTypeID]float64)
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT tparam_id, value FROM param WHERE region_id=?`, regionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id calc.ParamTypeID
+		var value float64
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			return
+		}
+		p[id] = value
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	// Column layout along the segments, the same as in the calculation of columns
+	var segments []APISegment
+	segments, err = getSegments(regionID, nil)
+	if err != nil {
+		return
+	}
+	for _, s := range segments {
+		e.Segments = append(e.Segments, calc.Segment{Length: s.Length, Angle: s.Angle, Elevation: s.Elevation})
+	}
+	if len(e.Segments) == 0 {
+		e.Segments = []calc.Segment{{Length: p[calc.PTTotalLength]}}
+	}
+	e.Spans = calc.RegionSpans(regionType, e.Segments, p[calc.PTColumnStepLength], p[calc.PTColumnStepType], p[calc.PTColumnStepSpace])
+
+	e.Height = p[calc.PTTotalHeight]
+	e.BottomSpace = p[calc.PTBottomSpace] / 1000
+	e.UpSpace = p[calc.PTUpSpace] / 1000
+	e.SlopeType = p[calc.PTSlopeType]
+	e.Horizontal = regionType.Horizontal()
+	columnWidth, _, _ := calc.ProfileSize(calc.ParamValueName(calc.PTColumnSize, p[calc.PTColumnSize]))
+	e.ColumnWidth = columnWidth / 1000
+
+	// Sticks and sheets as they are calculated
+	rows, err = db.DB.Query(`SELECT tresult_id, value FROM result WHERE region_id=? AND tresult_id IN (?,?) ORDER BY id`,
+		regionID, calc.RSHStickHeight, calc.RSSheetWidth)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id calc.ResultTypeID
+		var value float64
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			return
+		}
+		switch id {
+		case calc.RSHStickHeight:
+			e.StickHeights = append(e.StickHeights, value)
+		case calc.RSSheetWidth:
+			e.SheetWidth = value / 1000
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	var components []int64
+	components, err = db.ComponentTypesOfRegion(db.DB, regionID)
+	if err != nil {
+		return
+	}
+	for _, id := range components {
+		if calc.ComponentTypeID(id) == calc.CMMeshPanels {
+			e.Mesh = true
+		}
+	}
+
+	// Colors: painted or own color of the material
+	e.ColumnColor = paintColor(p, calc.PTBoolColumnPaint, calc.PTColorColumnPaint, -1)
+	e.StickColor = paintColor(p, calc.PTBoolHStickPaint, calc.PTColorHStick, -1)
+	e.CanvasColor = paintColor(p, calc.PTBoolCanvasPaint, calc.PTColorCanvasPaint, calc.PTColorCanvas)
+	if e.Mesh {
+		e.CanvasColor = colorValue(p, calc.PTColorMesh)
+	}
+	return
+}
+
+// paintColor - цвет покраски, если она выбрана, иначе собственный цвет материала own, own = -1 - без цвета
+func paintColor(p map[calc.ParamTypeID]float64, paint, paintColor, own calc.ParamTypeID) int {
+	if p[paint] == 1 {
+		return colorValue(p, paintColor)
+	}
+	if own < 0 {
+		return calc.NoColor
+	}
+	return colorValue(p, own)
+}
+
+// colorValue - значение цвета из параметра, calc.NoColor если параметра нет или цвет не задан
+func colorValue(p map[calc.ParamTypeID]float64, id calc.ParamTypeID) int {
+	v, ok := p[id]
+	if !ok || v < 0 {
+		return calc.NoColor
+	}
+	return int(v)
+}
@@ -19,6 +19,7 @@ type APINomenclature struct {
 	Thickness                   float64              `json:"thickness,omitempty"`
 	Color                       *APIColor            `json:"color,omitempty"`
 	Size                        float64              `json:"size,omitempty"`
+	Width                       float64              `json:"width,omitempty"`
 	Price                       int                  `json:"price,omitempty"`
 	Division                    []float64            `json:"division,omitempty"`
 	DivisionServiceNomenclature *APINomenclature     `json:"division_service_nomencla,omitempty"`
@@ -52,6 +53,7 @@ type APINomenclature struct {
 //			value 	int
 //		}
 //		size        double
+//		width       double /*Полезная ширина, мм*/
 //		price       int
 //		division: [<value double>, <value double>, ...]
 //		division_service_nomenclature: {
@@ -63,11 +65,43 @@ type APINomenclature struct {
 //		}
 //	}
 
+// nomenclatureList - список номенклатуры с типом, цветом и текущей ценой
+var nomenclatureList ListQuery = ListQuery{
+	Select: `SELECT n.id, n.name, n.vendor_code, n.measure_unit, n.material, n.thickness, n.size, n.width,
+		t.id, t.name, ifnull(cl.name, ''), ifnull(cl.value, 0),
+		ifnull((SELECT pr.price FROM price pr WHERE pr.nomenclature_id = n.id AND pr.date <= date('now') ORDER BY pr.date DESC LIMIT 1), 0) AS price`,
+	From: `FROM nomenclature n INNER JOIN tnomenclature t ON t.id = n.tnomenclature_id LEFT JOIN color cl ON cl.id = n.color_id`,
+	Filters: map[string]string{
+		"nomenclature_type_id": "n.tnomenclature_id=?",
+		"material":             "n.material=?",
+	},
+	Substrings: map[string]string{
+		"name":        "n.name",
+		"vendor_code": "n.vendor_code",
+	},
+	Sort: map[string]string{
+		"id":                "n.id",
+		"name":              "n.name",
+		"vendor_code":       "n.vendor_code",
+		"material":          "n.material",
+		"nomenclature_type": "t.name",
+		"price":             "price",
+	},
+	Order: "n.id",
+}
+
 ///////////////////////////////////////////////////////////////////////////////
-// Request: GET /nomenclature
+// Request: GET /nomenclature[?nomenclature_type_id=<value>][?name=<value>][?vendor_code=<value>][?material=<value>]
+//	[?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
+// name, vendor_code - подстроки, сортировка: id, name, vendor_code, material, nomenclature_type, price
+// Total ответа - количество номенклатуры без учета limit и offset
 //
 func GetNomenclatures(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetNomenclatures"
+	var err error
+	var res []APINomenclature
+	defer answer.make(&err, &res)
+
+	res, answer.Total, err = getNomenclatures(nomenclatureList, params, true)
 	return
 }
 
@@ -81,9 +115,78 @@ func GetNomenclature(request []string, params map[string][]string) (answer Answe
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /nomenclature_types/<id>/nomenclature
+// Параметры - как в GET /nomenclature, кроме nomenclature_type_id
 //
 func GetNomenclatureOfNomenclatureType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetNomenclatureOfNomenclatureType"
+	var err error
+	var res []APINomenclature
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	q := nomenclatureList
+	q.Where, q.Args = "n.tnomenclature_id=?", []interface{}{answer.ID}
+	res, answer.Total, err = getNomenclatures(q, params, false)
+	return
+}
+
+// getNomenclatures - страница списка номенклатуры и количество номенклатуры по параметрам запроса
+// withType - выводить тип номенклатуры
+func getNomenclatures(q ListQuery, params map[string][]string, withType bool) (res []APINomenclature, total *int64, err error) {
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"name":        {Optional: true, Type: String},
+		"vendor_code": {Optional: true, Type: String},
+		"material":    {Optional: true, Type: String},
+	}.WithListParams()
+	if withType {
+		rp["nomenclature_type_id"] = RequestParam{Optional: true, Type: Int}
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		return
+	}
+
+	sqlText, countText, sqlParams, err := rp.MakeSQLSelect(q)
+	if err != nil {
+		return
+	}
+	total = new(int64)
+	err = db.DB.QueryRow(countText, sqlParams...).Scan(total)
+	if err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	rows, err = db.DB.Query(sqlText, sqlParams...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t APINomenclatureType
+		var c APIColor
+		var n APINomenclature
+		err = rows.Scan(&n.ID, &n.Name, &n.VendorCode, &n.MeasureUnit, &n.Material, &n.Thickness, &n.Size, &n.Width,
+			&t.ID, &t.Name, &c.Name, &c.Value, &n.Price)
+		if err != nil {
+			return
+		}
+		if withType {
+			n.NomenclatureType = &t
+		}
+		if c.Name != "" || c.Value != 0 {
+			n.Color = &c
+		}
+		res = append(res, n)
+	}
+	err = rows.Err()
 	return
 }
 
@@ -105,7 +208,7 @@ func GetNomenclatureForValueOfParamType(request []string, params map[string][]st
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: PUT /nomenclature_types/<id>/nomenclature?name=<value>[?vendor_code=<value>][mesure_unit=<value>]
-//	[?material=<value>][?thickness=<value>][?color_id=<value>][?size=<value>][division=<value>,<value>,...][division_service_nomenclature_id=<value>]
+//	[?material=<value>][?thickness=<value>][?color_id=<value>][?size=<value>][?width=<value>][division=<value>,<value>,...][division_service_nomenclature_id=<value>]
 //
 func PutNomenclature(request []string, params map[string][]string) (answer Answer) {
 	var err error
@@ -128,6 +231,7 @@ func PutNomenclature(request []string, params map[string][]string) (answer Answe
 		"thickness":                        {Optional: true, Type: Float},
 		"color_id":                         {Optional: true, Type: Int},
 		"size":                             {Optional: true, Type: Float},
+		"width":                            {Optional: true, Type: Float},
 		"division":                         {Optional: true, Type: String},
 		"division_service_nomenclature_id": {Optional: true, Type: Int},
 	}
@@ -143,9 +247,10 @@ func PutNomenclature(request []string, params map[string][]string) (answer Answe
 
 	// Insert into [tnomenclature]
 	sqlText, sqlParams := rp.MakeSQLInsert("nomenclature", []string{"tnomenclature_id", "name", "vendor_code", "measure_unit",
-		"material", "thickness", "color_id", "size", "division", "division_service_nomenclature_id"})
+		"material", "thickness", "color_id", "size", "width", "division", "division_service_nomenclature_id"})
 	var res sql.Result
 	res, err = db.DB.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("nomenclature", err)
 	if err != nil {
 		return
 	}
@@ -160,7 +265,7 @@ func PutNomenclature(request []string, params map[string][]string) (answer Answe
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: POST /nomenclature/<id>[?name=<value>][?vendor_code=<value>][mesure_unit=<value>]
-//	[?material=<value>][?thickness=<value>][?color_id=<value>][?size=<value>][division=<value>,<value>,...][division_service_nomenclature_id=<value>]
+//	[?material=<value>][?thickness=<value>][?color_id=<value>][?size=<value>][?width=<value>][division=<value>,<value>,...][division_service_nomenclature_id=<value>]
 //
 func PostNomenclature(request []string, params map[string][]string) (answer Answer) {
 	var err error
@@ -182,6 +287,7 @@ func PostNomenclature(request []string, params map[string][]string) (answer Answ
 		"thickness":                        {Optional: true, Type: Float},
 		"color_id":                         {Optional: true, Type: Int},
 		"size":                             {Optional: true, Type: Float},
+		"width":                            {Optional: true, Type: Float},
 		"division":                         {Optional: true, Type: String},
 		"division_service_nomenclature_id": {Optional: true, Type: Int},
 	}
@@ -194,14 +300,23 @@ func PostNomenclature(request []string, params map[string][]string) (answer Answ
 
 	// Update [tnomenclature]
 	sqlText, sqlParams := rp.MakeSQLUpdate("nomenclature", []string{"name", "vendor_code", "measure_unit",
-		"material", "thickness", "color_id", "size", "division", "division_service_nomenclature_id"}, answer.ID)
+		"material", "thickness", "color_id", "size", "width", "division", "division_service_nomenclature_id"}, answer.ID)
 	if len(sqlParams) > 0 {
 		_, err = db.DB.Exec(sqlText, sqlParams...)
+		err = rp.ReferenceError("nomenclature", err)
 		if err != nil {
 			return
 		}
 	}
 
+	// Recalculate materials of the regions using the nomenclature, if values used by calculations are changed
+	for _, name := range []string{"size", "width", "division", "division_service_nomenclature_id"} {
+		if rp[name].Exists() {
+			err = db.CalculateRegionsOfNomenclature(answer.ID)
+			break
+		}
+	}
+
 	return
 }
 
@@ -146,6 +146,7 @@ func PutNomenclatureType(request []string, params map[string][]string) (answer A
 	sqlText, sqlParams := rp.MakeSQLInsert("tnomenclature", []string{"name", "color_scheme_id"})
 	var res sql.Result
 	res, err = db.DB.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("tnomenclature", err)
 	if err != nil {
 		return
 	}
@@ -203,6 +204,7 @@ func PostNomenclatureType(request []string, params map[string][]string) (answer
 	sqlText, sqlParams := rp.MakeSQLUpdate("tnomenclature", []string{"name", "color_scheme_id"}, answer.ID)
 	if len(sqlParams) > 0 {
 		_, err = db.DB.Exec(sqlText, sqlParams...)
+		err = rp.ReferenceError("tnomenclature", err)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"knx/calc"
+	"knx/db"
+	"knx/report"
+	"strconv"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/offer[?format=html|pdf][?date=<value>][?valid_days=<value>]
+//
+// Answer: коммерческое предложение по проекту в формате HTML (по умолчанию) или PDF, при ошибке - JSON
+// Шапка с реквизитами компании из meta, заказчик, участки с описанием и спецификацией по ценам на дату договора,
+// итоги и срок действия
+// date - дата предложения в формате ГГГГ-ММ-ДД, по умолчанию текущая дата
+// valid_days - срок действия предложения, в днях, по умолчанию из meta
+//
+func GetProjectOffer(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"format":     {Optional: true, Type: String},
+		"date":       {Optional: true, Type: String},
+		"valid_days": {Optional: true, Type: Int},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePriceDate()
+	}
+	format := "html"
+	if rp["format"].Exists() {
+		format = rp["format"].Value.StringValue
+	}
+	if err == nil && format != "html" && format != "pdf" {
+		err = invalidField("format", "Неверный формат '%s', ожидается html или pdf", format)
+	}
+	if err == nil && rp["valid_days"].Exists() && rp["valid_days"].Value.IntValue < 0 {
+		err = invalidField("valid_days", "Срок действия предложения не может быть отрицательным")
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Project results with prices, then the project itself
+	a := GetResultsOfProject(request, nil)
+	if a.Code != OK {
+		answer.Code, err = a.Code, errors.New(a.Message)
+		return
+	}
+	estimate := a.Result.(*APIProjectEstimate)
+
+	a = GetProject(request, nil)
+	if a.Code != OK {
+		answer.Code, err = a.Code, errors.New(a.Message)
+		return
+	}
+	project := a.Result.(*APIProject)
+
+	var offer report.Offer
+	offer, err = makeOffer(project, estimate, format == "html")
+	if err != nil {
+		return
+	}
+
+	// Date and validity of the offer
+	offer.Date = time.Now()
+	if rp["date"].Exists() {
+		offer.Date, _ = time.Parse(PriceDateLayout, rp["date"].Value.StringValue)
+	}
+	validDays := rp["valid_days"].Value.IntValue
+	if !rp["valid_days"].Exists() {
+		var value string
+		value, err = db.Meta(db.MetaKeyOfferValidDays)
+		if err != nil {
+			return
+		}
+		validDays, _ = strconv.ParseInt(value, 10, 64)
+	}
+	offer.ValidUntil = offer.Date.AddDate(0, 0, int(validDays))
+
+	if format == "pdf" {
+		var path string
+		path, err = db.Meta(db.MetaKeyPDFFont)
+		if err != nil {
+			return
+		}
+		var font *report.Font
+		font, err = report.FindFont(path)
+		if err != nil {
+			return
+		}
+		answer.ContentType = "application/pdf"
+		answer.Content = offer.PDF(font)
+		return
+	}
+
+	answer.ContentType = "text/html; charset=utf-8"
+	answer.Content, err = offer.HTML()
+	return
+}
+
+// makeOffer - коммерческое предложение из проекта и его сметы, drawings - с чертежами участков
+func makeOffer(project *APIProject, estimate *APIProjectEstimate, drawings bool) (offer report.Offer, err error) {
+	offer.Company, err = company()
+	if err != nil {
+		return
+	}
+
+	offer.Number = project.Nr
+	if offer.Number == "" {
+		offer.Number = strconv.FormatInt(project.ID, 10)
+	}
+	offer.Address = project.Address
+	if project.Client != nil {
+		offer.Client = report.Contact{Name: project.Client.Name, Phone: project.Client.Phone}
+	}
+	if project.User != nil {
+		offer.Manager = report.Contact{Name: project.User.Name, Position: project.User.Position, Phone: project.User.Phone}
+	}
+	offer.Total = estimate.Estimate.Total
+	offer.MissingPrices = estimate.Estimate.MissingPrices
+
+	for _, regionResults := range estimate.Regions {
+		region := regionResults.Region
+		r := report.OfferRegion{Title: region.RegionType.UserName}
+		if region.Description != "" {
+			r.Title += ": " + region.Description
+		}
+		if regionResults.Estimate != nil {
+			r.Total = regionResults.Estimate.Total
+		}
+
+		// Main dimensions and the drawing of the region
+		var e report.Elevation
+		e, err = regionElevation(region.ID)
+		if err != nil {
+			return
+		}
+		r.Description = fmt.Sprintf("Длина %s м, высота %s м", report.Quantity(calc.SegmentsLength(e.Segments)), report.Quantity(e.Height))
+		if drawings {
+			r.Drawing = template.HTML(e.SVG())
+		}
+
+		// Bill of materials: only the results with nomenclature
+		for _, result := range regionResults.Results {
+			if result.Nomenclature == nil {
+				continue
+			}
+			line := report.OfferLine{
+				Name:       result.Nomenclature.Name,
+				VendorCode: result.Nomenclature.VendorCode,
+				Unit:       result.Nomenclature.MeasureUnit,
+				Total:      result.Total,
+			}
+			line.Quantity, _ = strconv.ParseFloat(result.Value, 64)
+			if line.Quantity == 0 {
+				continue
+			}
+			if result.Price != nil {
+				line.Price, line.Priced = int64(result.Price.Price), true
+			}
+			r.Lines = append(r.Lines, line)
+		}
+		offer.Regions = append(offer.Regions, r)
+	}
+	return
+}
+
+// company - реквизиты компании из meta
+func company() (c report.Company, err error) {
+	for key, value := range map[string]*string{
+		db.MetaKeyCompanyName:    &c.Name,
+		db.MetaKeyCompanyAddress: &c.Address,
+		db.MetaKeyCompanyPhone:   &c.Phone,
+		db.MetaKeyCompanyEmail:   &c.Email,
+	} {
+		*value, err = db.Meta(key)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
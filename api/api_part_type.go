@@ -1,19 +1,63 @@
 package api
 
+import (
+	"database/sql"
+	"fmt"
+	"knx/calc"
+	"knx/db"
+	"strconv"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // APIPartType
 type APIPartType struct {
 	ID                int64             `json:"id,omitempty"`
 	Name              string            `json:"name,omitempty"`
 	CalculationTypeID int64             `json:"calculation_type_id,omitempty"`
+	Formula           string            `json:"formula,omitempty"`
 	ComponentType     *APIComponentType `json:"component_type,omitempty"`
 }
 
+///////////////////////////////////////////////////////////////////////////////
+// Answer:
+//	{
+//		id                  int
+//		name                string
+//		calculation_type_id int
+//		formula             string /*Формула расчета, используется вместо calculation_type_id, синтаксис - см. calc.Formula*/
+//	}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /component_types/<id>/part_types
 //
 func GetPartTypesOfComponentType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetPartTypesOfComponentType"
+	var err error
+	var res []APIPartType
+	defer answer.make(&err, &res)
+
+	var componentTypeID int64
+	componentTypeID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID типа компонента '%s'", request[1])
+		return
+	}
+
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT id, name, ifnull(tcalculation_id, 0), formula FROM tpart WHERE tcomponent_id=? ORDER BY id`, componentTypeID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t APIPartType
+		err = rows.Scan(&t.ID, &t.Name, &t.CalculationTypeID, &t.Formula)
+		if err != nil {
+			return
+		}
+		res = append(res, t)
+	}
+	err = rows.Err()
 	return
 }
 
@@ -21,26 +65,195 @@ func GetPartTypesOfComponentType(request []string, params map[string][]string) (
 // Request: GET /component_types/<id>/part_types/<id>
 //
 func GetPartType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetPartType"
+	var err error
+	var res APIPartType
+	defer answer.make(&err, &res)
+
+	var componentTypeID int64
+	componentTypeID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID типа компонента '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID типа части '%s'", request[3])
+		return
+	}
+
+	err = db.DB.QueryRow(`SELECT id, name, ifnull(tcalculation_id, 0), formula FROM tpart WHERE id=? AND tcomponent_id=?`,
+		answer.ID, componentTypeID).Scan(&res.ID, &res.Name, &res.CalculationTypeID, &res.Formula)
+	if err == sql.ErrNoRows {
+		answer.Code = NotFound
+		err = fmt.Errorf("Тип части '%d' не найден в типе компонента '%d'", answer.ID, componentTypeID)
+	}
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: PUT /component_types/<id>/part_types?name=<value>[?calculation_type_id=<value>]
+// Request: PUT /component_types/<id>/part_types?name=<value>[?calculation_type_id=<value>][?formula=<value>]
 //
 func PutPartType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PutPartType"
+	var err error
+	defer answer.make(&err, nil)
+
+	var componentTypeID int64
+	componentTypeID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID типа компонента '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"name":                {Optional: false, Type: String},
+		"calculation_type_id": {Optional: true, Type: Int},
+		"formula":             {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = rp.parseCalculationType()
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = rp.parseFormula(0)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+	rp["tcomponent_id"] = RequestParam{Optional: false, Type: Int, Value: RequestParamValue{Type: Int, IntValue: componentTypeID}}
+
+	// Insert into [tpart]
+	sqlText, sqlParams := rp.MakeSQLInsert("tpart", []string{"tcomponent_id", "name", "tcalculation_id", "formula"})
+	var res sql.Result
+	res, err = db.DB.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("tpart", err)
+	if err != nil {
+		return
+	}
+
+	answer.ID, err = res.LastInsertId()
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: POST /component_types/<id>/part_types/<id>[?name=<value>][?calculation_type_id=<value>]
+// Request: POST /component_types/<id>/part_types/<id>[?name=<value>][?calculation_type_id=<value>][?formula=<value>]
 //
 func PostPartType(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PostPartType"
+	var err error
+	defer answer.make(&err, nil)
+
+	var componentTypeID int64
+	componentTypeID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID типа компонента '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID типа части '%s'", request[3])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"name":                {Optional: true, Type: String},
+		"calculation_type_id": {Optional: true, Type: Int},
+		"formula":             {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = rp.parseCalculationType()
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = rp.parseFormula(answer.ID)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Update [tpart]
+	sqlText, sqlParams := rp.MakeSQLUpdate("tpart", []string{"name", "tcalculation_id", "formula"}, answer.ID)
+	if len(sqlParams) == 0 {
+		return
+	}
+	sqlText += " AND tcomponent_id=?"
+	sqlParams = append(sqlParams, componentTypeID)
+
+	var res sql.Result
+	res, err = db.DB.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("tpart", err)
+	if err != nil {
+		return
+	}
+
+	var count int64
+	count, err = res.RowsAffected()
+	if err == nil && count == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Тип части '%d' не найден в типе компонента '%d'", answer.ID, componentTypeID)
+	}
+	if err != nil {
+		return
+	}
+
+	// Recalculate materials of the regions using the part type
+	if rp["tcalculation_id"].Exists() || rp["formula"].Exists() {
+		err = db.CalculateRegionsOfPartType(answer.ID)
+	}
 	return
 }
 
+// parseCalculationType - проверка параметра calculation_type_id: расчет должен быть зарегистрирован
+// Значение переносится в параметр tcalculation_id, совпадающий с полем таблицы [tpart]
+func (rps RequestParams) parseCalculationType() error {
+	rp := rps["calculation_type_id"]
+	if !rp.Exists() {
+		return nil
+	}
+	if _, ok := calc.MaterialCalculations[calc.MaterialCalculationID(rp.Value.IntValue)]; !ok {
+		return fmt.Errorf("Расчет '%d' не зарегистрирован", rp.Value.IntValue)
+	}
+	rps["tcalculation_id"] = rp
+	return nil
+}
+
+// parseFormula - проверка параметра formula типа части partTypeID (0 для нового типа части)
+// Пустая строка удаляет формулу, тогда используется расчет части
+func (rps RequestParams) parseFormula(partTypeID int64) error {
+	rp := rps["formula"]
+	if !rp.Exists() || rp.Value.StringValue == "" {
+		return nil
+	}
+	f, err := calc.ParseFormula(rp.Value.StringValue)
+	if err != nil {
+		return err
+	}
+	return db.CheckPartFormula(partTypeID, f)
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Request: DELETE /component_types/<id>/part_types/<id>
 //
@@ -1,5 +1,13 @@
 package api
 
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"strconv"
+	"time"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // APIPrice
 type APIPrice struct {
@@ -8,6 +16,9 @@ type APIPrice struct {
 	CostPrice int    `json:"cost_price,omitempty"`
 }
 
+// Формат даты цены
+const PriceDateLayout = "2006-01-02"
+
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /nomenclature/<id>/price
 // Answer:
@@ -20,26 +31,127 @@ type APIPrice struct {
 // ]
 //
 // Request: GET /nomenclature/<id>/price?date=<value>
-// Answer:
+// Answer: цена, действующая на дату - последняя цена с датой не позже указанной
 // {
+//      date        string
 //      price 		int /*Цены в копейках*/
 //	    cost_price 	int
 // }
 
+// parsePriceDate - проверка параметра date: дата в формате ГГГГ-ММ-ДД
+func (rps RequestParams) parsePriceDate() error {
+	return rps.parseDate("date")
+}
+
+// parseDate - проверка параметра с датой в формате ГГГГ-ММ-ДД
+func (rps RequestParams) parseDate(name string) error {
+	rp := rps[name]
+	if !rp.Exists() {
+		return nil
+	}
+	if _, err := time.Parse(PriceDateLayout, rp.Value.StringValue); err != nil {
+		return invalidField(name, "Неверная дата '%s', ожидается формат ГГГГ-ММ-ДД", rp.Value.StringValue)
+	}
+	return nil
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /nomenclature/<id>/price
 //
 func GetPrice(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetPrice"
+	var err error
+	var res []APIPrice
+	var resDate APIPrice
+	var result interface{} = &res
+	defer func() { answer.make(&err, result) }()
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"date": {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePriceDate()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Price at the date
+	if rp["date"].Exists() {
+		result = &resDate
+		err = db.DB.QueryRow(`SELECT date, price, cost_price FROM price WHERE nomenclature_id=? AND date<=?
+			ORDER BY date DESC LIMIT 1`, answer.ID, rp["date"].Value.StringValue).Scan(&resDate.Date, &resDate.Price, &resDate.CostPrice)
+		if err == sql.ErrNoRows {
+			answer.Code = BadRequest
+			err = fmt.Errorf("Нет цены номенклатуры '%d' на дату '%s'", answer.ID, rp["date"].Value.StringValue)
+		}
+		return
+	}
+
+	// All the prices
+	var rows *sql.Rows
+	rows, err = db.DB.Query("SELECT date, price, cost_price FROM price WHERE nomenclature_id=? ORDER BY date", answer.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p APIPrice
+		err = rows.Scan(&p.Date, &p.Price, &p.CostPrice)
+		if err != nil {
+			return
+		}
+		res = append(res, p)
+	}
+	err = rows.Err()
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: PUT /nomenclature/<id>/price?date=<value>[?price=<value>][?cost_price=<value>]
-
 //
 func PutPrice(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PutPrice"
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"date":       {Optional: false, Type: String},
+		"price":      {Optional: true, Type: Int},
+		"cost_price": {Optional: true, Type: Int},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePriceDate()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+	rp["nomenclature_id"] = RequestParam{Type: Int, Value: RequestParamValue{Type: Int, IntValue: answer.ID}}
+
+	// Insert into [price]
+	sqlText, sqlParams := rp.MakeSQLInsert("price", []string{"nomenclature_id", "date", "price", "cost_price"})
+	_, err = db.DB.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("price", err)
 	return
 }
 
@@ -47,7 +159,51 @@ func PutPrice(request []string, params map[string][]string) (answer Answer) {
 // Request: POST /nomenclature/<id>/price?date=<value>[?price=<value>][?cost_price=<value>]
 //
 func PostPrice(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "PostPrice"
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"date":       {Optional: false, Type: String},
+		"price":      {Optional: true, Type: Int},
+		"cost_price": {Optional: true, Type: Int},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePriceDate()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Update [price], the row is identified by nomenclature and date
+	sqlText, sqlParams := rp.MakeSQLUpdateWhere("price", []string{"price", "cost_price"}, "nomenclature_id=? AND date=?",
+		answer.ID, rp["date"].Value.StringValue)
+	if len(sqlParams) == 0 {
+		return
+	}
+
+	var res sql.Result
+	res, err = db.DB.Exec(sqlText, sqlParams...)
+	if err != nil {
+		return
+	}
+
+	var count int64
+	count, err = res.RowsAffected()
+	if err == nil && count == 0 {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Нет цены номенклатуры '%d' с датой '%s'", answer.ID, rp["date"].Value.StringValue)
+	}
 	return
 }
 
@@ -55,6 +211,35 @@ func PostPrice(request []string, params map[string][]string) (answer Answer) {
 // Request: DELETE /nomenclature/<id>/price[?date=<value>]
 //
 func DeletePrice(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "DeletePrice"
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"date": {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePriceDate()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Delete from [price] one price or all the prices of the nomenclature
+	if rp["date"].Exists() {
+		_, err = db.DB.Exec("DELETE FROM price WHERE nomenclature_id=? AND date=?", answer.ID, rp["date"].Value.StringValue)
+	} else {
+		_, err = db.DB.Exec("DELETE FROM price WHERE nomenclature_id=?", answer.ID)
+	}
 	return
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"knx/db"
 	"strconv"
+	"strings"
 )
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -16,10 +17,19 @@ type APIProject struct {
 	InstallDate  *string    `json:"install_date,omitempty"`
 	Address      string     `json:"address,omitempty"`
 	Comment      string     `json:"comment,omitempty"`
+	Status       string     `json:"status,omitempty"`
 	User         *APIUser   `json:"user,omitempty"`
 	Client       *APIClient `json:"client,omitempty"`
 }
 
+///////////////////////////////////////////////////////////////////////////////
+// APIProjectStatus - переход проекта в статус
+type APIProjectStatus struct {
+	Status string   `json:"status"`
+	Date   string   `json:"date"`
+	User   *APIUser `json:"user,omitempty"`
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 //
 // Request: GET /clients/<id>/projects
@@ -34,6 +44,7 @@ type APIProject struct {
 //			install_date   string
 //          address        string
 //			comment        string
+//			status         string /*draft, quoted, contracted, scheduled, installed, closed, cancelled*/
 //			user: {
 //			    id         int
 //				name       string
@@ -50,8 +61,40 @@ type APIProject struct {
 //		}
 //	]
 
+// projectsList - список проектов: фильтры - параметры запроса, сортировка - по полям проекта, клиенту и пользователю
+var projectsList ListQuery = ListQuery{
+	Select: `SELECT p.id, p.nr, p.contract_date, p.install_date, p.address, p.comment, p.status,
+		u.id, u.name, u.phone, u.position, u.comment,
+		c.id, c.name, c.phone, c.comment`,
+	From: `FROM project p INNER JOIN user u ON p.user_id = u.id INNER JOIN client c ON p.client_id = c.id`,
+	Filters: map[string]string{
+		"client_id":     "p.client_id=?",
+		"user_id":       "p.user_id=?",
+		"status":        "p.status IN (?)",
+		"contract_from": "date(p.contract_date) >= ?",
+		"contract_to":   "date(p.contract_date) <= ?",
+		"install_from":  "date(p.install_date) >= ?",
+		"install_to":    "date(p.install_date) <= ?",
+	},
+	Substrings: map[string]string{
+		"address": "p.address",
+	},
+	Sort: map[string]string{
+		"id":            "p.id",
+		"nr":            "p.nr",
+		"contract_date": "p.contract_date",
+		"install_date":  "p.install_date",
+		"address":       "p.address",
+		"status":        "p.status",
+		"client":        "c.name",
+		"user":          "u.name",
+	},
+	Order: "p.id",
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /clients/<id>/projects
+// Параметры - как в GET /projects, кроме client_id
 //
 func GetProjectsOfClient(request []string, params map[string][]string) (answer Answer) {
 	var err error
@@ -65,47 +108,75 @@ func GetProjectsOfClient(request []string, params map[string][]string) (answer A
 		return
 	}
 
-	var rows *sql.Rows
-	rows, err = db.DB.Query(`SELECT p.id, p.nr, p.contract_date, p.install_date, p.address, p.comment,
-		u.id, u.name, u.phone, u.position, u.comment,
-		c.id, c.name, c.phone, c.comment
-		FROM project p INNER JOIN user u ON p.user_id = u.id INNER JOIN client c ON p.client_id = c.id AND p.client_id=?`, answer.ID)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var u APIUser
-		var c APIClient
-		p := APIProject{User: &u, Client: &c}
-		err = rows.Scan(&p.ID, &p.Nr, &p.ContractDate, &p.InstallDate, &p.Address, &p.Comment,
-			&u.ID, &u.Name, &u.Phone, &u.Position, &u.Comment,
-			&c.ID, &c.Name, &c.Phone, &c.Comment)
-		if err != nil {
-			return
-		}
-		res = append(res, p)
-	}
-	err = rows.Err()
-	if err != nil {
-		return
-	}
+	q := projectsList
+	q.Where, q.Args = "p.client_id=?", []interface{}{answer.ID}
+	res, answer.Total, err = getProjects(q, params)
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: GET /projects
+// Request: GET /projects[?client_id=<value>][?user_id=<value>][?status=<value>,<value>,...]
+//	[?contract_from=<value>][?contract_to=<value>][?install_from=<value>][?install_to=<value>][?address=<value>]
+//	[?sort=[-]<column>,[-]<column>,...][?limit=<value>][?offset=<value>]
+// Даты - ГГГГ-ММ-ДД, address - подстрока адреса
+// Сортировка: id, nr, contract_date, install_date, address, status, client, user; '-' - по убыванию
+// Total ответа - количество проектов без учета limit и offset
 //
 func GetProjects(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	var res []APIProject
 	defer answer.make(&err, &res)
 
+	res, answer.Total, err = getProjects(projectsList, params)
+	return
+}
+
+// getProjects - страница списка проектов и количество проектов по параметрам запроса
+func getProjects(q ListQuery, params map[string][]string) (res []APIProject, total *int64, err error) {
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"status":        {Optional: true, Type: StringArray},
+		"user_id":       {Optional: true, Type: Int},
+		"contract_from": {Optional: true, Type: String},
+		"contract_to":   {Optional: true, Type: String},
+		"install_from":  {Optional: true, Type: String},
+		"install_to":    {Optional: true, Type: String},
+		"address":       {Optional: true, Type: String},
+	}.WithListParams()
+	if q.Where == "" {
+		rp["client_id"] = RequestParam{Optional: true, Type: Int}
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		for _, status := range rp["status"].Value.StringArray {
+			if _, ok := db.ProjectTransitions[status]; !ok {
+				err = invalidField("status", "Неверный статус проекта '%s', ожидается: %s", status, strings.Join(db.ProjectStatuses, ", "))
+				break
+			}
+		}
+	}
+	for _, name := range []string{"contract_from", "contract_to", "install_from", "install_to"} {
+		if err == nil {
+			err = rp.parseDate(name)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	sqlText, countText, sqlParams, err := rp.MakeSQLSelect(q)
+	if err != nil {
+		return
+	}
+	total = new(int64)
+	err = db.DB.QueryRow(countText, sqlParams...).Scan(total)
+	if err != nil {
+		return
+	}
+
 	var rows *sql.Rows
-	rows, err = db.DB.Query(`SELECT p.id, p.nr, p.contract_date, p.install_date, p.address, p.comment,
-		u.id, u.name, u.phone, u.position, u.comment,
-		c.id, c.name, c.phone, c.comment
-		FROM project p INNER JOIN user u ON p.user_id = u.id INNER JOIN client c ON p.client_id = c.id`)
+	rows, err = db.DB.Query(sqlText, sqlParams...)
 	if err != nil {
 		return
 	}
@@ -114,7 +185,7 @@ func GetProjects(request []string, params map[string][]string) (answer Answer) {
 		var u APIUser
 		var c APIClient
 		p := APIProject{User: &u, Client: &c}
-		err = rows.Scan(&p.ID, &p.Nr, &p.ContractDate, &p.InstallDate, &p.Address, &p.Comment,
+		err = rows.Scan(&p.ID, &p.Nr, &p.ContractDate, &p.InstallDate, &p.Address, &p.Comment, &p.Status,
 			&u.ID, &u.Name, &u.Phone, &u.Position, &u.Comment,
 			&c.ID, &c.Name, &c.Phone, &c.Comment)
 		if err != nil {
@@ -123,9 +194,6 @@ func GetProjects(request []string, params map[string][]string) (answer Answer) {
 		res = append(res, p)
 	}
 	err = rows.Err()
-	if err != nil {
-		return
-	}
 	return
 }
 
@@ -147,12 +215,12 @@ func GetProject(request []string, params map[string][]string) (answer Answer) {
 	}
 
 	var row *sql.Row
-	row = db.DB.QueryRow(`SELECT p.nr, p.contract_date, p.install_date, p.address, p.comment,
+	row = db.DB.QueryRow(`SELECT p.nr, p.contract_date, p.install_date, p.address, p.comment, p.status,
 		u.id, u.name, u.phone, u.position, u.comment,
 		c.id, c.name, c.phone, c.comment
 		FROM project p INNER JOIN user u ON p.user_id = u.id INNER JOIN client c ON p.client_id = c.id
 		WHERE p.id=?`, answer.ID)
-	err = row.Scan(&res.Nr, &res.ContractDate, &res.InstallDate, &res.Address, &res.Comment,
+	err = row.Scan(&res.Nr, &res.ContractDate, &res.InstallDate, &res.Address, &res.Comment, &res.Status,
 		&u.ID, &u.Name, &u.Phone, &u.Position, &u.Comment,
 		&c.ID, &c.Name, &c.Phone, &c.Comment)
 	if err != nil {
@@ -170,7 +238,6 @@ func PutProject(request []string, params map[string][]string) (answer Answer) {
 	defer answer.make(&err, nil)
 
 	var clientID int64
-	var userID int64 = 1 // Use 1 for default user, TODO: later make sessions and get user id from session, which opened by login-api
 	clientID, err = strconv.ParseInt(request[1], 10, 64)
 	if err != nil {
 		answer.Code = BadRequest
@@ -178,6 +245,13 @@ func PutProject(request []string, params map[string][]string) (answer Answer) {
 		return
 	}
 
+	// Проект закрепляется за пользователем, который его создал
+	var userID int64
+	userID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
 	// Parse user request parameters
 	var rp RequestParams = RequestParams{
 		"contract_date": {Optional: false, Type: String},
@@ -197,26 +271,56 @@ func PutProject(request []string, params map[string][]string) (answer Answer) {
 	rp["user_id"] = RequestParam{Type: Int, Value: RequestParamValue{Type: Int, IntValue: userID}}
 	rp["client_id"] = RequestParam{Type: Int, Value: RequestParamValue{Type: Int, IntValue: clientID}}
 
+	// Проект и его первый статус записываются в одной транзакции
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
 	// Insert into [project]
 	sqlText, sqlParams := rp.MakeSQLInsert("project", []string{"contract_date", "install_date", "comment", "address", "nr", "user_id", "client_id"})
 	var res sql.Result
-	res, err = db.DB.Exec(sqlText, sqlParams...)
+	res, err = tx.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("project", err)
 	if err != nil {
 		return
 	}
 
 	answer.ID, err = res.LastInsertId()
+	if err != nil {
+		return
+	}
 
+	err = db.SetProjectStatus(tx, answer.ID, db.ProjectDraft, userID)
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: POST /project/<project_id>[?contract_date=<value>][?install_date=<value>][?comment=<value>][address=<value>][?nr=<value>]
+// Request: POST /project/<project_id>[?contract_date=<value>][?install_date=<value>][?comment=<value>][address=<value>][?nr=<value>][?status=<value>]
+//
+// Переходы статусов: draft -> quoted, cancelled; quoted -> draft, contracted, cancelled; contracted -> scheduled, cancelled;
+// scheduled -> installed, cancelled; installed -> closed
+// В статусе contracted цены сохраняются и номенклатура резервируется на складе, участки проекта больше не изменяются;
+// в статус scheduled проект переходит только с датой монтажа; в статусе cancelled резерв снимается
 //
 func PostProject(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	defer answer.make(&err, nil)
 
+	var userID int64
+	userID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
 	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
 	if err != nil {
 		answer.Code = BadRequest
@@ -231,28 +335,96 @@ func PostProject(request []string, params map[string][]string) (answer Answer) {
 		"comment":       {Optional: true, Type: String},
 		"address":       {Optional: true, Type: String},
 		"nr":            {Optional: true, Type: String},
+		"status":        {Optional: true, Type: String},
 	}
 
 	err = rp.Parse(params)
+	if err == nil && rp["status"].Exists() {
+		status := rp["status"].Value.StringValue
+		if _, ok := db.ProjectTransitions[status]; !ok {
+			err = invalidField("status", "Неверный статус проекта '%s', ожидается: %s", status, strings.Join(db.ProjectStatuses, ", "))
+		}
+	}
 	if err != nil {
 		answer.Code = BadRequest
 		return
 	}
 
+	// Изменения проекта, переход статуса и резерв на складе записываются в одной транзакции
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var status, installDate *string
+	err = tx.QueryRow("SELECT status, install_date FROM project WHERE id=?", answer.ID).Scan(&status, &installDate)
+	if err == sql.ErrNoRows {
+		answer.Code = NotFound
+		err = fmt.Errorf("Проект '%d' не найден", answer.ID)
+	}
+	if err != nil {
+		return
+	}
+
+	// Check transition to the new status
+	newStatus := *status
+	if rp["status"].Exists() {
+		newStatus = rp["status"].Value.StringValue
+	}
+	if rp["install_date"].Exists() {
+		date := rp["install_date"].Value.StringValue
+		installDate = &date
+	}
+	if newStatus != *status && !db.CanChangeProjectStatus(*status, newStatus) {
+		answer.Code = Conflict
+		err = fmt.Errorf("Переход проекта '%d' из статуса '%s' в статус '%s' не допускается, возможные статусы: %s",
+			answer.ID, *status, newStatus, strings.Join(db.ProjectTransitions[*status], ", "))
+		return
+	}
+	if newStatus == db.ProjectScheduled && (installDate == nil || *installDate == "") {
+		answer.Code = BadRequest
+		err = invalidField("install_date", "Для статуса '%s' проекта '%d' нужна дата монтажа", newStatus, answer.ID)
+		return
+	}
+
 	// Update [project]
 	sqlText, sqlParams := rp.MakeSQLUpdate("project", []string{"contract_date", "install_date", "comment", "address", "nr"}, answer.ID)
 	if len(sqlParams) > 0 {
-		_, err = db.DB.Exec(sqlText, sqlParams...)
+		_, err = tx.Exec(sqlText, sqlParams...)
 		if err != nil {
 			return
 		}
 	}
 
+	if newStatus == *status {
+		return
+	}
+	err = db.SetProjectStatus(tx, answer.ID, newStatus, userID)
+	if err != nil {
+		return
+	}
+
+	// Reservation of the nomenclature on the stock follows the contract
+	switch newStatus {
+	case db.ProjectContracted:
+		err = db.ReserveProject(tx, answer.ID)
+	case db.ProjectCancelled:
+		err = db.ReleaseProject(tx, answer.ID)
+	}
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: DELETE /projects/<id>
+// Недоступно после договора: удаление проекта удалило бы резерв на складе, версии и историю статусов
 //
 func DeleteProject(request []string, params map[string][]string) (answer Answer) {
 	var err error
@@ -265,7 +437,71 @@ func DeleteProject(request []string, params map[string][]string) (answer Answer)
 		return
 	}
 
+	var frozen bool
+	frozen, err = db.ProjectFrozen(answer.ID)
+	if err == nil && frozen {
+		err = conflict("Проект '%d' по договору, удаление не допускается", answer.ID)
+	}
+	if err != nil {
+		return
+	}
+
 	// Delete from [project]
 	_, err = db.DB.Exec("DELETE FROM project WHERE id=?", answer.ID)
 	return
 }
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/statuses
+//
+// Answer: история статусов проекта по времени перехода
+//[
+//	{
+//		status  string
+//		date    string
+//		user    {id int, login string, name string}
+//	}
+//]
+//
+func GetStatusesOfProject(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res []APIProjectStatus
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT s.status, s.date, u.id, u.login, u.name
+		FROM project_status s INNER JOIN user u ON s.user_id = u.id
+		WHERE s.project_id=? ORDER BY s.date, s.id`, answer.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u APIUser
+		s := APIProjectStatus{User: &u}
+		err = rows.Scan(&s.Status, &s.Date, &u.ID, &u.Login, &u.Name)
+		if err != nil {
+			return
+		}
+		res = append(res, s)
+	}
+	err = rows.Err()
+	return
+}
+
+// checkProjectNotFrozen - проверка, что участки проекта можно изменять: после договора количества и цены не меняются
+func checkProjectNotFrozen(projectID int64) (err error) {
+	var frozen bool
+	frozen, err = db.ProjectFrozen(projectID)
+	if err == nil && frozen {
+		err = conflict("Проект '%d' по договору, участки проекта не изменяются", projectID)
+	}
+	return
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"knx/calc"
+	"knx/db"
+	"knx/report"
+	"math"
+	"sort"
+	"strings"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// APIPurchase - позиция списка закупки
+type APIPurchase struct {
+	Nomenclature     *APINomenclature `json:"nomenclature"`
+	Quantity         float64          `json:"quantity"`          // Сумма результатов расчета проектов
+	Bars             []APIStockBars   `json:"bars,omitempty"`    // Заготовки складских длин
+	PurchaseQuantity float64          `json:"purchase_quantity"` // Количество к закупке с учетом складских длин
+}
+
+// APIStockBars - количество заготовок одной складской длины
+type APIStockBars struct {
+	Length float64 `json:"length"`
+	Count  int     `json:"count"`
+}
+
+// APIPurchaseGroup - позиции списка закупки одного типа номенклатуры
+type APIPurchaseGroup struct {
+	NomenclatureType *APINomenclatureType `json:"nomenclature_type"`
+	Purchases        []APIPurchase        `json:"purchases"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APIPurchaseList
+type APIPurchaseList struct {
+	From     string             `json:"from,omitempty"`
+	To       string             `json:"to,omitempty"`
+	Projects []int64            `json:"projects"`
+	Groups   []APIPurchaseGroup `json:"groups"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /purchases[?from=<value>][?to=<value>][?format=json|csv]
+//
+// Список закупки по проектам с датой монтажа от from до to включительно (ГГГГ-ММ-ДД)
+// Количества номенклатуры из результатов расчета всех участков проектов суммируются, услуги не включаются,
+// номенклатура со складскими длинами (division) закупается целыми заготовками раскроя участков
+//
+// Answer:
+//{
+//	from      string
+//	to        string
+//	projects  []int /*ID проектов*/
+//	groups [
+//		{
+//			nomenclature_type {id int, name string}
+//			purchases [
+//				{
+//					nomenclature      {id int, name string, vendor_code string, measure_unit string, division []float}
+//					quantity          float
+//					bars              [{length float, count int}] /*Заготовки складских длин*/
+//					purchase_quantity float /*Количество к закупке*/
+//				}
+//			]
+//		}
+//	]
+//}
+//
+// format=csv - список в CSV с разделителем ';'
+//
+func GetPurchases(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APIPurchaseList
+	defer answer.make(&err, &res)
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"from":   {Optional: true, Type: String},
+		"to":     {Optional: true, Type: String},
+		"format": {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parseDate("from")
+	}
+	if err == nil {
+		err = rp.parseDate("to")
+	}
+	format := "json"
+	if rp["format"].Exists() {
+		format = rp["format"].Value.StringValue
+	}
+	if err == nil && format != "json" && format != "csv" {
+		err = invalidField("format", "Неверный формат '%s', ожидается json или csv", format)
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	var from, to *string
+	if rp["from"].Exists() {
+		res.From = rp["from"].Value.StringValue
+		from = &res.From
+	}
+	if rp["to"].Exists() {
+		res.To = rp["to"].Value.StringValue
+		to = &res.To
+	}
+
+	res.Projects, res.Groups, err = getPurchases(from, to)
+	if err != nil || format != "csv" {
+		return
+	}
+
+	t := report.Table{Header: []string{"tnomenclature", "vendor_code", "name", "measure_unit", "quantity", "bars", "purchase_quantity"}}
+	for _, g := range res.Groups {
+		for _, p := range g.Purchases {
+			var bars []string
+			for _, b := range p.Bars {
+				bars = append(bars, fmt.Sprintf("%dx%s", b.Count, report.Quantity(b.Length)))
+			}
+			t.Rows = append(t.Rows, []interface{}{g.NomenclatureType.Name, p.Nomenclature.VendorCode, p.Nomenclature.Name,
+				p.Nomenclature.MeasureUnit, p.Quantity, strings.Join(bars, " + "), p.PurchaseQuantity})
+		}
+	}
+	answer.Filename = "purchases.csv"
+	answer.ContentType = "text/csv; charset=utf-8"
+	answer.Content, err = report.CSV([]report.Table{t})
+	return
+}
+
+// Проекты с датой монтажа в периоде, кроме отмененных: from и to - ГГГГ-ММ-ДД, nil - без ограничения
+const purchaseProjectsSQL = `p.install_date IS NOT NULL AND p.status <> 'cancelled'
+	AND (? IS NULL OR date(p.install_date) >= ?) AND (? IS NULL OR date(p.install_date) <= ?)`
+
+// getPurchases - проекты периода и суммы номенклатуры их результатов по типам номенклатуры
+func getPurchases(from, to *string) (projects []int64, groups []APIPurchaseGroup, err error) {
+	projects = []int64{}
+	groups = []APIPurchaseGroup{}
+
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT p.id FROM project p WHERE `+purchaseProjectsSQL+` ORDER BY p.install_date, p.id`,
+		from, from, to, to)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		projects = append(projects, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	var bars map[int64]map[float64]int
+	bars, err = purchaseBars(from, to)
+	if err != nil {
+		return
+	}
+
+	rows, err = db.DB.Query(`SELECT t.id, t.name, n.id, n.name, n.vendor_code, n.measure_unit, n.division, sum(r.value)
+		FROM result r INNER JOIN region g ON g.id = r.region_id
+		INNER JOIN project p ON p.id = g.project_id
+		INNER JOIN nomenclature n ON n.id = r.nomenclature_id
+		INNER JOIN tnomenclature t ON t.id = n.tnomenclature_id
+		WHERE r.tresult_id = ? AND `+purchaseProjectsSQL+`
+		GROUP BY n.id ORDER BY t.name, t.id, n.name, n.id`, calc.RSNomenclature, from, from, to, to)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := new(APINomenclatureType)
+		n := new(APINomenclature)
+		var division string
+		var quantity float64
+		err = rows.Scan(&t.ID, &t.Name, &n.ID, &n.Name, &n.VendorCode, &n.MeasureUnit, &division, &quantity)
+		if err != nil {
+			return
+		}
+		if quantity <= 0 {
+			continue
+		}
+		n.Division = db.ParseDivision(division)
+
+		if len(groups) == 0 || groups[len(groups)-1].NomenclatureType.ID != t.ID {
+			groups = append(groups, APIPurchaseGroup{NomenclatureType: t})
+		}
+		g := &groups[len(groups)-1]
+		g.Purchases = append(g.Purchases, purchase(n, quantity, bars[n.ID]))
+	}
+	err = rows.Err()
+	return
+}
+
+// purchaseBars - количество заготовок по складским длинам из раскроя каждого участка проектов периода по ID номенклатуры
+// Куски разных участков не раскраиваются вместе: заготовки те же, что и в результатах расчета участков
+func purchaseBars(from, to *string) (bars map[int64]map[float64]int, err error) {
+	var regions []int64
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT g.id FROM region g INNER JOIN project p ON p.id = g.project_id
+		WHERE `+purchaseProjectsSQL+` ORDER BY g.id`, from, from, to, to)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		regions = append(regions, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	bars = make(map[int64]map[float64]int)
+	for _, id := range regions {
+		var cutting map[int64][]calc.Bar
+		cutting, err = db.RegionCutting(id)
+		if err != nil {
+			return
+		}
+		for nomenclatureID, regionBars := range cutting {
+			if bars[nomenclatureID] == nil {
+				bars[nomenclatureID] = make(map[float64]int)
+			}
+			for _, bar := range regionBars {
+				bars[nomenclatureID][bar.Length]++
+			}
+		}
+	}
+	return
+}
+
+// purchase - позиция закупки: номенклатура со складскими длинами закупается заготовками раскроя участков counts
+func purchase(n *APINomenclature, quantity float64, counts map[float64]int) (p APIPurchase) {
+	p = APIPurchase{Nomenclature: n, Quantity: round3(quantity), PurchaseQuantity: round3(quantity)}
+	if len(n.Division) == 0 || len(counts) == 0 {
+		return
+	}
+
+	var length float64
+	for l, count := range counts {
+		p.Bars = append(p.Bars, APIStockBars{Length: l, Count: count})
+		length += l * float64(count)
+	}
+	sort.Slice(p.Bars, func(i, j int) bool { return p.Bars[i].Length > p.Bars[j].Length })
+	p.PurchaseQuantity = round3(length)
+	return
+}
+
+// round3 - округление количества до трех знаков после запятой
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -249,7 +249,7 @@ func GetRegion(request []string, params map[string][]string) (answer Answer) {
 	// Get all the params and parts of the region
 	var resParams map[int64]db.DBParamValue
 	var resParts map[int64]db.DBPartNomenclatureValue
-	resParams, resParts, err = db.GetParamPartValues(answer.ID, map[int64]float64{}, map[int64]int64{})
+	resParams, resParts, err = db.GetParamPartValues(db.DB, answer.ID, map[int64]float64{}, map[int64]int64{})
 	if err != nil {
 		return
 	}
@@ -289,7 +289,7 @@ func GetRegion(request []string, params map[string][]string) (answer Answer) {
 				part.ValueList = append(part.ValueList, nil)
 			} else {
 				part.ValueList = append(part.ValueList, &APINomenclature{ID: *nomenclatureID})
-				listNomenclature.WriteString(fmt.Sprintf("%n,", nomenclatureID))
+				listNomenclature.WriteString(fmt.Sprintf("%d,", *nomenclatureID))
 			}
 		}
 		// Add "0" to close last comma
@@ -401,6 +401,11 @@ func PutRegion(request []string, params map[string][]string) (answer Answer) {
 		return
 	}
 
+	err = checkProjectNotFrozen(projectID)
+	if err != nil {
+		return
+	}
+
 	// Parse user request parameters
 	var rp RequestParams = RequestParams{
 		"region_type": {Optional: false, Type: Int},
@@ -429,15 +434,31 @@ func PutRegion(request []string, params map[string][]string) (answer Answer) {
 			return
 		}
 		if count > 0 {
+			answer.Code = Conflict
 			err = fmt.Errorf("В проекте [%d] уже добавлен основной участок. Допускается только один участок такого типа", projectID)
 			return
 		}
 	}
 
+	// Участок, его параметры, компоненты и результаты расчета записываются в одной транзакции
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
 	// Insert into [region]
 	sqlText, sqlParams := rp.MakeSQLInsert("region", []string{"tregion_id", "project_id", "description", "nr"})
 	var res sql.Result
-	res, err = db.DB.Exec(sqlText, sqlParams...)
+	res, err = tx.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("region", err)
 	if err != nil {
 		return
 	}
@@ -448,7 +469,7 @@ func PutRegion(request []string, params map[string][]string) (answer Answer) {
 	}
 
 	// Add params with default values for all the params of this region type
-	res, err = db.DB.Exec(`INSERT INTO param(region_id, tparam_id, value)
+	res, err = tx.Exec(`INSERT INTO param(region_id, tparam_id, value)
 		SELECT ?, p.tparam_id, ifnull(min(v.value), 0)
 		FROM cn_tparam_tregion p
 		LEFT JOIN tparamvalue v ON p.tparam_id = v.tparam_id
@@ -458,33 +479,14 @@ func PutRegion(request []string, params map[string][]string) (answer Answer) {
 		return
 	}
 
-	// Add all the possible components for this type of region
-	res, err = db.DB.Exec(`INSERT INTO component(region_id, tcomponent_id)
-		SELECT ?, cn.tcomponent_id FROM cn_tregion_tcomponent cn WHERE cn.tregion_id = ?`, answer.ID, regionTypeID)
+	// Add all the possible components for this type of region with their parts
+	err = db.AddComponents(tx, answer.ID, nil)
 	if err != nil {
 		return
 	}
 
-	// Add all the parts of components of the region, set default value of nomenclature for each part (AS SELECT min from possible values)
-	res, err = db.DB.Exec(`INSERT INTO part(tpart_id, component_id, nomenclature_id)  
-		SELECT p.id, c.id, min(cn.nomenclature_id)
-		FROM component c
-		INNER JOIN tpart p ON c.tcomponent_id = p.tcomponent_id
-		LEFT JOIN cn_tpart_nomenclature cn ON p.id = cn.tpart_id
-		WHERE c.region_id = ?
-		GROUP BY p.id, c.id`, answer.ID)
-	if err != nil {
-		return
-	}
-
-	// Correct dependent param and part values
-	var resParams map[int64]db.DBParamValue
-	var resParts map[int64]db.DBPartNomenclatureValue
-	resParams, resParts, err = db.GetParamPartValues(answer.ID, map[int64]float64{}, map[int64]int64{})
-	if err != nil {
-		return
-	}
-	err = db.WriteParamPartValues(answer.ID, resParams, resParts)
+	// Calculate materials of the new region
+	err = db.CalculateRegion(tx, answer.ID)
 	return
 }
 
@@ -495,7 +497,8 @@ func PostRegion(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	defer answer.make(&err, nil)
 
-	_, err = strconv.ParseInt(request[1], 10, 64)
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
 	if err != nil {
 		answer.Code = BadRequest
 		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
@@ -509,6 +512,14 @@ func PostRegion(request []string, params map[string][]string) (answer Answer) {
 		return
 	}
 
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err == nil {
+		err = checkProjectNotFrozen(projectID)
+	}
+	if err != nil {
+		return
+	}
+
 	// Parse user request parameters
 	var rp RequestParams = RequestParams{
 		"description": {Optional: true, Type: String},
@@ -522,10 +533,24 @@ func PostRegion(request []string, params map[string][]string) (answer Answer) {
 		return
 	}
 
+	// Участок, его параметры и результаты расчета записываются в одной транзакции
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
 	// Update [region]
 	sqlText, sqlParams := rp.MakeSQLUpdate("region", []string{"description", "nr"}, answer.ID)
 	if len(sqlParams) > 0 {
-		_, err = db.DB.Exec(sqlText, sqlParams...)
+		_, err = tx.Exec(sqlText, sqlParams...)
 		if err != nil {
 			return
 		}
@@ -543,11 +568,17 @@ func PostRegion(request []string, params map[string][]string) (answer Answer) {
 	// Correct dependent param and part values
 	var resParams map[int64]db.DBParamValue
 	var resParts map[int64]db.DBPartNomenclatureValue
-	resParams, resParts, err = db.GetParamPartValues(answer.ID, regionParams, map[int64]int64{})
+	resParams, resParts, err = db.GetParamPartValues(tx, answer.ID, regionParams, map[int64]int64{})
+	if err != nil {
+		return
+	}
+	err = db.WriteParamPartValues(tx, answer.ID, resParams, resParts)
 	if err != nil {
 		return
 	}
-	err = db.WriteParamPartValues(answer.ID, resParams, resParts)
+
+	// Recalculate materials with the new param values
+	err = db.CalculateRegion(tx, answer.ID)
 
 	return
 }
@@ -574,6 +605,11 @@ func DeleteRegion(request []string, params map[string][]string) (answer Answer)
 		return
 	}
 
+	err = checkProjectNotFrozen(projectID)
+	if err != nil {
+		return
+	}
+
 	// Delete from [region]
 	_, err = db.DB.Exec("DELETE FROM region WHERE id=? AND project_id=?", answer.ID, projectID)
 	return
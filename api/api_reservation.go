@@ -0,0 +1,159 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// APIReservation - резерв номенклатуры проекта
+type APIReservation struct {
+	Date     string    `json:"date"`
+	Quantity float64   `json:"quantity"` // Зарезервировано для проекта
+	Issued   float64   `json:"issued"`   // Уже выдано со склада на проект
+	Stock    *APIStock `json:"stock"`    // Остатки номенклатуры с учетом резервов всех проектов
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/reservations
+//
+// Answer:
+//[
+//	{
+//		date      string
+//		quantity  float /*Зарезервировано для проекта*/
+//		issued    float /*Уже выдано на проект*/
+//		stock {
+//			nomenclature  {id int, name string, vendor_code string, measure_unit string}
+//			on_hand       float
+//			reserved      float
+//			available     float /*Отрицательное - нехватка*/
+//		}
+//	}
+//]
+//
+func GetReservationsOfProject(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res []APIReservation
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT r.nomenclature_id, r.date, r.quantity, -ifnull((SELECT sum(s.quantity) FROM stock s
+		WHERE s.nomenclature_id = r.nomenclature_id AND s.project_id = r.project_id AND s.quantity < 0), 0)
+		FROM reservation r INNER JOIN nomenclature n ON n.id = r.nomenclature_id
+		WHERE r.project_id=? ORDER BY n.name, n.id`, answer.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var r APIReservation
+		err = rows.Scan(&id, &r.Date, &r.Quantity, &r.Issued)
+		if err != nil {
+			return
+		}
+		r.Quantity, r.Issued = round3(r.Quantity), round3(r.Issued)
+		ids = append(ids, id)
+		res = append(res, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		var stock []APIStock
+		stock, err = getStock(`WHERE n.id=?`, id)
+		if err != nil {
+			return
+		}
+		res[i].Stock = &stock[0]
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: PUT /projects/<id>/reservations
+// Подтверждение проекта: номенклатура результатов расчета резервируется на складе, прежний резерв заменяется
+//
+func PutReservations(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var count int
+	err = db.DB.QueryRow("SELECT count(*) FROM project WHERE id=?", answer.ID).Scan(&count)
+	if err == nil && count == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Проект '%d' не найден", answer.ID)
+	}
+	if err != nil {
+		return
+	}
+
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = db.ReserveProject(tx, answer.ID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: DELETE /projects/<id>/reservations
+// Снятие резерва проекта
+//
+func DeleteReservations(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = db.ReleaseProject(tx, answer.ID)
+	return
+}
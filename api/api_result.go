@@ -1,18 +1,62 @@
 package api
 
+import (
+	"database/sql"
+	"fmt"
+	"knx/calc"
+	"knx/db"
+	"math"
+	"strconv"
+)
+
 ///////////////////////////////////////////////////////////////////////////////
 // APIResult
 type APIResult struct {
 	ResultType   *APIResultType   `json:"result_type,omitempty"`
 	Value        string           `json:"value,omitempty"`
 	Nomenclature *APINomenclature `json:"nomenclature,omitempty"`
+	Price        *APIPrice        `json:"price,omitempty"`      // Цена номенклатуры на дату договора
+	Total        int64            `json:"total,omitempty"`      // Сумма по цене, в копейках
+	CostTotal    int64            `json:"cost_total,omitempty"` // Сумма по себестоимости, в копейках
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APIEstimate - смета, суммы в копейках
+type APIEstimate struct {
+	Total         int64 `json:"total"`
+	CostTotal     int64 `json:"cost_total"`
+	Margin        int64 `json:"margin"`
+	MissingPrices int   `json:"missing_prices,omitempty"` // Количество позиций номенклатуры без цены на дату договора
+}
+
+// add - добавление позиции сметы в итоги
+func (e *APIEstimate) add(r APIResult) {
+	if r.Nomenclature == nil {
+		return
+	}
+	if r.Price == nil {
+		e.MissingPrices++
+		return
+	}
+	e.Total += r.Total
+	e.CostTotal += r.CostTotal
+	e.Margin = e.Total - e.CostTotal
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // APIRegionResults
 type APIProjectResults struct {
-	Region  *APIRegion  `json:"region,omitempty"`
-	Results []APIResult `json:"results,omitempty"`
+	Region   *APIRegion   `json:"region,omitempty"`
+	Results  []APIResult  `json:"results,omitempty"`
+	Estimate *APIEstimate `json:"estimate,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APIProjectEstimate
+type APIProjectEstimate struct {
+	Date     string              `json:"date,omitempty"`
+	Regions  []APIProjectResults `json:"regions,omitempty"`
+	Estimate APIEstimate         `json:"estimate"`
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -20,31 +64,70 @@ type APIProjectResults struct {
 // GET /projects/<id>/results
 //
 // Answer:
-//[
-//		region {
-//			id             int
-//			description    string
-//			region_type   {
-//			     id        int
-//			     user_name string
-//			     code_name string
-//			}
-//		},
-//		results:
-//		[
-//				{
-//					result_type  {id int, name string, description string}
-//					value        string
-//					nomenclature {id int, ...}
+//{
+//	date    string /*Дата цен: дата договора или текущая дата, если дата договора не задана*/
+//	regions [
+//		{
+//			region {
+//				id             int
+//				description    string
+//				region_type   {
+//				     id        int
+//				     user_name string
+//				     code_name string
 //				}
-//		]
-//]
+//			},
+//			results:
+//			[
+//					{
+//						result_type  {id int, name string, description string}
+//						value        string
+//						nomenclature {id int, name string, vendor_code string, measure_unit string}
+//						price        {date string, price int, cost_price int} /*Последняя цена с датой не позже даты цен*/
+//						total        int /*value * price, в копейках*/
+//						cost_total   int /*value * cost_price, в копейках*/
+//					}
+//			]
+//			estimate {total int, cost_total int, margin int, missing_prices int} /*Итоги участка*/
+//		}
+//	]
+//	estimate {total int, cost_total int, margin int, missing_prices int} /*Итоги проекта*/
+//}
+//
+// Request: GET /projects/<id>/regions/<id>/results
+// Answer: results одного участка
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: GET /projects/<id>/regions/<id>/results
 //
 func GetResultsOfRegion(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetResultsOfRegion"
+	var err error
+	var res []APIResult
+	defer answer.make(&err, &res)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err != nil {
+		return
+	}
+
+	var results map[int64][]APIResult
+	results, err = getResults(projectID, &answer.ID)
+	res = results[answer.ID]
 	return
 }
 
@@ -52,6 +135,134 @@ func GetResultsOfRegion(request []string, params map[string][]string) (answer An
 // Request: GET /projects/<id>/results
 //
 func GetResultsOfProject(request []string, params map[string][]string) (answer Answer) {
-	answer.Message = "GetResultsOfProject"
+	var err error
+	var res APIProjectEstimate
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	err = db.DB.QueryRow(`SELECT `+db.PriceDateSQL+` FROM project p WHERE p.id=?`, answer.ID).Scan(&res.Date)
+	if err == sql.ErrNoRows {
+		answer.Code = NotFound
+		err = fmt.Errorf("Проект '%d' не найден", answer.ID)
+	}
+	if err != nil {
+		return
+	}
+
+	var results map[int64][]APIResult
+	results, err = getResults(answer.ID, nil)
+	if err != nil {
+		return
+	}
+
+	res.Regions, res.Estimate, err = getEstimate(db.RegionsOfProject, answer.ID, results)
+	return
+}
+
+// getEstimate - результаты и итоги всех участков проекта или версии проекта (regions - db.RegionsOfProject или db.RegionsOfVersion)
+func getEstimate(regions string, id int64, results map[int64][]APIResult) (res []APIProjectResults, total APIEstimate, err error) {
+	// All the regions in their order, with or without results
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT r.id, r.description, t.id, t.name
+		FROM region r INNER JOIN tregion t ON r.tregion_id = t.id WHERE r.`+regions+`=? ORDER BY r.nr, r.id`, id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r := APIRegion{RegionType: new(APIRegionType)}
+		err = rows.Scan(&r.ID, &r.Description, &r.RegionType.ID, &r.RegionType.UserName)
+		if err != nil {
+			return
+		}
+		if r.RegionType.ID >= 0 && r.RegionType.ID < int64(len(calc.Regions)) {
+			r.RegionType.CodeName = calc.Regions[r.RegionType.ID].Name
+		}
+
+		// Region and project totals
+		estimate := new(APIEstimate)
+		for _, result := range results[r.ID] {
+			estimate.add(result)
+			total.add(result)
+		}
+		res = append(res, APIProjectResults{Region: &r, Results: results[r.ID], Estimate: estimate})
+	}
+	err = rows.Err()
+	return
+}
+
+// getResults - результаты расчета участков проекта с ценами на дату договора по ID участка, regionID - только указанного участка
+// После заключения договора используются цены, сохраненные в результатах при переходе в статус contracted
+func getResults(projectID int64, regionID *int64) (results map[int64][]APIResult, err error) {
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT r.region_id, t.id, t.name, t.description, r.value,
+		n.id, n.name, n.vendor_code, n.measure_unit,
+		CASE WHEN `+db.ProjectFrozenSQL+` THEN r.price_date ELSE pr.date END,
+		CASE WHEN `+db.ProjectFrozenSQL+` THEN r.price ELSE pr.price END,
+		CASE WHEN `+db.ProjectFrozenSQL+` THEN r.cost_price ELSE pr.cost_price END
+		FROM result r INNER JOIN region g ON g.id = r.region_id
+		INNER JOIN project p ON p.id = g.project_id
+		INNER JOIN tresult t ON t.id = r.tresult_id
+		LEFT JOIN nomenclature n ON n.id = r.nomenclature_id
+		LEFT JOIN price pr ON pr.nomenclature_id = r.nomenclature_id AND pr.date = (
+			SELECT max(date) FROM price WHERE nomenclature_id = r.nomenclature_id AND date <= `+db.PriceDateSQL+`)
+		WHERE g.project_id=? AND (? IS NULL OR r.region_id=?) ORDER BY r.id`, projectID, regionID, regionID)
+	if err != nil {
+		return
+	}
+	return scanResults(rows)
+}
+
+// getVersionResults - результаты расчета участков версии проекта с ценами, сохраненными в версии, по ID участка
+func getVersionResults(versionID int64) (results map[int64][]APIResult, err error) {
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT r.region_id, t.id, t.name, t.description, r.value,
+		n.id, n.name, n.vendor_code, n.measure_unit, r.price_date, r.price, r.cost_price
+		FROM result r INNER JOIN region g ON g.id = r.region_id
+		INNER JOIN tresult t ON t.id = r.tresult_id
+		LEFT JOIN nomenclature n ON n.id = r.nomenclature_id
+		WHERE g.version_id=? ORDER BY r.id`, versionID)
+	if err != nil {
+		return
+	}
+	return scanResults(rows)
+}
+
+// scanResults - результаты расчета из запроса по ID участка: участок, тип результата, значение, номенклатура, цена
+func scanResults(rows *sql.Rows) (results map[int64][]APIResult, err error) {
+	results = make(map[int64][]APIResult)
+
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var t APIResultType
+		var value float64
+		var nomenclatureID *int64
+		var nomenclatureName, vendorCode, measureUnit, priceDate *string
+		var price, costPrice *int
+		r := APIResult{ResultType: &t}
+		err = rows.Scan(&id, &t.ID, &t.UserName, &t.Description, &value, &nomenclatureID, &nomenclatureName, &vendorCode, &measureUnit,
+			&priceDate, &price, &costPrice)
+		if err != nil {
+			return
+		}
+		r.Value = strconv.FormatFloat(value, 'f', -1, 64)
+		if nomenclatureID != nil {
+			r.Nomenclature = &APINomenclature{ID: *nomenclatureID, Name: *nomenclatureName, VendorCode: *vendorCode, MeasureUnit: *measureUnit}
+		}
+		if priceDate != nil {
+			r.Price = &APIPrice{Date: *priceDate, Price: *price, CostPrice: *costPrice}
+			r.Total = int64(math.Round(value * float64(*price)))
+			r.CostTotal = int64(math.Round(value * float64(*costPrice)))
+		}
+		results[id] = append(results[id], r)
+	}
+	err = rows.Err()
 	return
 }
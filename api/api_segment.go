@@ -0,0 +1,352 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"math"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// APISegment - прямой отрезок ломаной линии участка
+type APISegment struct {
+	ID        int64   `json:"id,omitempty"`
+	Nr        int64   `json:"nr,omitempty"`
+	Length    float64 `json:"length"`    // Длина по горизонтали, в м
+	Angle     float64 `json:"angle"`     // Угол поворота в начале отрезка, в градусах
+	Elevation float64 `json:"elevation"` // Перепад высоты земли на отрезке, в м
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/regions/<id>/segments
+//
+// Answer:
+//[
+//		{
+//			id          int
+//			nr          int
+//			length      float /*Длина по горизонтали, в м*/
+//			angle       float /*Угол поворота в начале отрезка относительно предыдущего, в градусах*/
+//			elevation   float /*Перепад высоты земли от начала до конца отрезка, в м*/
+//		}
+//]
+//
+func GetSegmentsOfRegion(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res []APISegment
+	defer answer.make(&err, &res)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, answer.ID)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	res, err = getSegments(answer.ID, nil)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/regions/<id>/segments/<id>
+//
+func GetSegment(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APISegment
+	defer answer.make(&err, &res)
+
+	var regionID int64
+	_, regionID, answer.ID, err = parseSegmentRequest(request)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	var segments []APISegment
+	segments, err = getSegments(regionID, &answer.ID)
+	if err != nil {
+		return
+	}
+	if len(segments) == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Отрезок '%d' не найден на участке '%d'", answer.ID, regionID)
+		return
+	}
+	res = segments[0]
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: PUT /projects/<id>/regions/<id>/segments?length=<value>[?angle=<value>][?elevation=<value>][?nr=<value>]
+// Длина участка (параметр 0) заменяется суммой длин отрезков
+//
+func PutSegment(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var regionID int64
+	regionID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, regionID)
+	if err == nil {
+		err = checkProjectNotFrozen(projectID)
+	}
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"length":    {Optional: false, Type: Float},
+		"angle":     {Optional: true, Type: Float},
+		"elevation": {Optional: true, Type: Float},
+		"nr":        {Optional: true, Type: Int},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.checkSegment()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Отрезок записывается, длина и материалы участка пересчитываются в одной транзакции
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Insert into [segment]
+	rp["region_id"] = RequestParam{Type: Int, Value: RequestParamValue{Type: Int, IntValue: regionID}}
+	sqlText, sqlParams := rp.MakeSQLInsert("segment", []string{"region_id", "nr", "length", "angle", "elevation"})
+	var res sql.Result
+	res, err = tx.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("segment", err)
+	if err != nil {
+		return
+	}
+
+	answer.ID, err = res.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	err = updateSegments(tx, regionID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: POST /projects/<id>/regions/<id>/segments/<id>[?length=<value>][?angle=<value>][?elevation=<value>][?nr=<value>]
+//
+func PostSegment(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID, regionID int64
+	projectID, regionID, answer.ID, err = parseSegmentRequest(request)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = checkProjectNotFrozen(projectID)
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"length":    {Optional: true, Type: Float},
+		"angle":     {Optional: true, Type: Float},
+		"elevation": {Optional: true, Type: Float},
+		"nr":        {Optional: true, Type: Int},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.checkSegment()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Update [segment]
+	sqlText, sqlParams := rp.MakeSQLUpdate("segment", []string{"nr", "length", "angle", "elevation"}, answer.ID)
+	if len(sqlParams) == 0 {
+		return
+	}
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var res sql.Result
+	res, err = tx.Exec(sqlText+" AND region_id=?", append(sqlParams, regionID)...)
+	if err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Отрезок '%d' не найден на участке '%d'", answer.ID, regionID)
+		return
+	}
+
+	err = updateSegments(tx, regionID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: DELETE /projects/<id>/regions/<id>/segments/<id>
+// После удаления последнего отрезка участок считается прямым с прежней длиной
+//
+func DeleteSegment(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID, regionID int64
+	projectID, regionID, answer.ID, err = parseSegmentRequest(request)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = checkProjectNotFrozen(projectID)
+	if err != nil {
+		return
+	}
+
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = tx.Exec("DELETE FROM segment WHERE id=? AND region_id=?", answer.ID, regionID)
+	if err != nil {
+		return
+	}
+
+	err = updateSegments(tx, regionID)
+	return
+}
+
+// parseSegmentRequest - ID проекта, участка и отрезка из запроса /projects/<id>/regions/<id>/segments/<id>
+func parseSegmentRequest(request []string) (projectID, regionID, segmentID int64, err error) {
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	regionID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Неверный ID участка '%s'", request[3])
+		return
+	}
+
+	err = checkRegionOfProject(projectID, regionID)
+	if err != nil {
+		return
+	}
+
+	segmentID, err = strconv.ParseInt(request[5], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Неверный ID отрезка '%s'", request[5])
+	}
+	return
+}
+
+// checkSegment - проверка значений отрезка: длина не отрицательная, угол поворота от -180 до 180 градусов
+func (rps RequestParams) checkSegment() error {
+	if rp := rps["length"]; rp.Exists() && rp.Value.FloatValue < 0 {
+		return fmt.Errorf("Длина отрезка не может быть отрицательной")
+	}
+	if rp := rps["angle"]; rp.Exists() && math.Abs(rp.Value.FloatValue) > 180 {
+		return fmt.Errorf("Угол поворота отрезка должен быть от -180 до 180 градусов")
+	}
+	return nil
+}
+
+// updateSegments - пересчет длины и материалов участка после изменения отрезков в транзакции изменения
+func updateSegments(tx *sql.Tx, regionID int64) (err error) {
+	err = db.UpdateRegionLength(tx, regionID)
+	if err != nil {
+		return
+	}
+	return db.CalculateRegion(tx, regionID)
+}
+
+// getSegments - отрезки участка по порядку, segmentID - только указанный отрезок
+func getSegments(regionID int64, segmentID *int64) (res []APISegment, err error) {
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT id, nr, length, angle, elevation FROM segment
+		WHERE region_id=? AND (? IS NULL OR id=?) ORDER BY nr, id`, regionID, segmentID, segmentID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s APISegment
+		err = rows.Scan(&s.ID, &s.Nr, &s.Length, &s.Angle, &s.Elevation)
+		if err != nil {
+			return
+		}
+		res = append(res, s)
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"fmt"
+	"knx/db"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SessionParam - параметр запроса с токеном сессии. Задается обработчиком HTTP из заголовка Authorization
+// или из cookie, значение из URL или тела запроса отбрасывается
+const SessionParam = "_session"
+
+// SessionCookie - cookie с токеном сессии для GUI, API передает токен в заголовке Authorization: Bearer <token>
+// Cookie не передается браузером в запросах с других сайтов (SameSite=Strict) и по HTTP без TLS (Secure)
+const SessionCookie = "knx_session"
+
+// CookieUnsafeRoutes - запросы GET, которые изменяют данные. Для них, как и для команд, заданных параметром ?method=,
+// cookie сессии не принимается: ссылка или <img> на другом сайте не должны выполнять изменения от имени пользователя,
+// токен передается только в заголовке Authorization
+var CookieUnsafeRoutes map[string]bool = map[string]bool{
+	"import_nomenclature": true,
+}
+
+// CredentialParams - параметры с паролями, которые не принимаются в строке запроса. Для входа - также логин
+var CredentialParams []string = []string{"password", "current_password"}
+
+///////////////////////////////////////////////////////////////////////////////
+// APISession - открытая сессия пользователя
+type APISession struct {
+	Token   string   `json:"token,omitempty"` // Выдается только при входе
+	Expires string   `json:"expires,omitempty"`
+	User    *APIUser `json:"user"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: POST /session, тело: {"login": <value>, "password": <value>} или форма login=<value>&password=<value>
+// Вход пользователя. Логин и пароль принимаются только из тела запроса, в строке запроса - ошибка
+//
+// Answer:
+//{
+//	token   string /*Токен для заголовка Authorization: Bearer <token>, для GUI - в cookie knx_session*/
+//	expires string /*Время окончания сессии, UTC*/
+//	user    {id int, login string, name string, phone string, position string, comment string, admin bool}
+//}
+//
+func PutSession(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APISession
+	defer answer.make(&err, &res)
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"login":    {Optional: false, Type: String},
+		"password": {Optional: false, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	var userID int64
+	var expires time.Time
+	userID, res.Token, expires, err = db.Login(rp["login"].Value.StringValue, rp["password"].Value.StringValue)
+	if err == db.ErrLogin {
+		err = unauthorized("Неверный логин или пароль")
+	}
+	if err != nil {
+		return
+	}
+	answer.ID = userID
+	res.Expires = expires.Format(time.RFC3339)
+
+	res.User, err = getSessionUser(answer.ID)
+	if err != nil {
+		return
+	}
+
+	answer.Cookie = sessionCookie(res.Token)
+	answer.Cookie.Expires = expires
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /session
+// Answer: пользователь открытой сессии {user {...}}
+//
+func GetSession(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APISession
+	defer answer.make(&err, &res)
+
+	answer.ID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
+	res.User, err = getSessionUser(answer.ID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: DELETE /session
+// Выход пользователя: сессия закрывается, cookie удаляется
+//
+func DeleteSession(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	answer.ID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
+	err = db.Logout(params[SessionParam][0])
+	if err != nil {
+		return
+	}
+
+	answer.Cookie = sessionCookie("")
+	answer.Cookie.MaxAge = -1
+	return
+}
+
+// checkQueryCredentials - ошибка, если пароль или логин входа заданы в строке запроса query к ресурсу key
+func checkQueryCredentials(key string, query map[string][]string) error {
+	names := CredentialParams
+	if key == "session" {
+		names = append([]string{"login"}, names...)
+	}
+
+	var fields []APIFieldError
+	for _, name := range names {
+		if _, ok := query[name]; ok {
+			fields = append(fields, APIFieldError{Field: name, Code: FieldInvalid,
+				Message: fmt.Sprintf("Параметр '%s' принимается только в теле запроса POST", name)})
+		}
+	}
+	if len(fields) > 0 {
+		return validationError(fields)
+	}
+	return nil
+}
+
+// sessionCookie - cookie сессии с токеном value
+// Secure задается в handler по запросу: сервис работает и по HTTP, где браузер не сохраняет cookie с Secure
+func sessionCookie(value string) *http.Cookie {
+	return &http.Cookie{Name: SessionCookie, Value: value, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode}
+}
+
+// sessionToken - токен сессии из заголовка Authorization: Bearer <token>, иначе из cookie
+// fromCookie - токен взят из cookie
+func sessionToken(r *http.Request) (token string, fromCookie bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		const bearer = "bearer "
+		if len(auth) > len(bearer) && strings.ToLower(auth[:len(bearer)]) == bearer {
+			return strings.TrimSpace(auth[len(bearer):]), false
+		}
+		return "", false
+	}
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// sessionUser - ID пользователя открытой сессии запроса
+// Ошибка Unauthorized, если токен не задан, сессия закрыта или истекла
+func sessionUser(params map[string][]string) (userID int64, err error) {
+	token, ok := params[SessionParam]
+	if !ok || token[0] == "" {
+		return 0, unauthorized("Требуется вход пользователя")
+	}
+
+	userID, err = db.SessionUser(token[0])
+	if err == nil && userID == 0 {
+		err = unauthorized("Сессия закрыта или истекла, требуется вход пользователя")
+	}
+	return
+}
+
+// getSessionUser - пользователь сессии
+func getSessionUser(userID int64) (u *APIUser, err error) {
+	u = &APIUser{ID: userID}
+	err = db.DB.QueryRow("SELECT login, name, phone, position, comment, admin FROM user WHERE id=?", userID).Scan(
+		&u.Login, &u.Name, &u.Phone, &u.Position, &u.Comment, &u.Admin)
+	return
+}
+
+// sessionAdmin - ID пользователя открытой сессии, ошибка Forbidden, если пользователь не администратор
+func sessionAdmin(params map[string][]string) (userID int64, err error) {
+	userID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
+	var admin bool
+	err = db.DB.QueryRow("SELECT admin FROM user WHERE id=?", userID).Scan(&admin)
+	if err == nil && !admin {
+		err = forbidden("Запрос доступен только администратору")
+	}
+	return
+}
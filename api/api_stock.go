@@ -0,0 +1,297 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// APIStock - остаток номенклатуры на складе
+type APIStock struct {
+	Nomenclature *APINomenclature `json:"nomenclature"`
+	OnHand       float64          `json:"on_hand"`   // На складе: приход минус расход
+	Reserved     float64          `json:"reserved"`  // В резерве проектов, еще не выданное
+	Available    float64          `json:"available"` // Свободно: на складе минус резерв, отрицательное - нехватка
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APIStockMove - приход или расход номенклатуры
+type APIStockMove struct {
+	ID        int64   `json:"id,omitempty"`
+	Type      string  `json:"type"` // receipt - приход, issue - расход
+	Date      string  `json:"date"`
+	Quantity  float64 `json:"quantity"`
+	ProjectID *int64  `json:"project_id,omitempty"` // Проект, на который выдан материал
+	Comment   string  `json:"comment,omitempty"`
+}
+
+// Типы движения номенклатуры. В таблице stock приход хранится положительным количеством, расход - отрицательным
+const (
+	StockReceipt = "receipt"
+	StockIssue   = "issue"
+)
+
+// stockSQL - остатки номенклатуры: на складе и в резерве
+// Резерв проекта уменьшается на количество, уже выданное на этот проект
+const stockSQL = `SELECT n.id, n.name, n.vendor_code, n.measure_unit,
+	ifnull((SELECT sum(s.quantity) FROM stock s WHERE s.nomenclature_id = n.id), 0),
+	ifnull((SELECT sum(max(0, r.quantity + ifnull((SELECT sum(s.quantity) FROM stock s
+		WHERE s.nomenclature_id = r.nomenclature_id AND s.project_id = r.project_id AND s.quantity < 0), 0)))
+		FROM reservation r WHERE r.nomenclature_id = n.id), 0)
+	FROM nomenclature n`
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /stock[?shortage=1]
+//
+// Answer: остатки номенклатуры, по которой были движения или есть резерв, shortage=1 - только с нехваткой
+//[
+//	{
+//		nomenclature  {id int, name string, vendor_code string, measure_unit string}
+//		on_hand       float /*На складе*/
+//		reserved      float /*В резерве проектов*/
+//		available     float /*Свободно, отрицательное - нехватка*/
+//	}
+//]
+//
+func GetStock(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res []APIStock
+	defer answer.make(&err, &res)
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"shortage": {Optional: true, Type: Int},
+	}
+
+	err = rp.Parse(params)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	var stock []APIStock
+	stock, err = getStock(`WHERE EXISTS(SELECT 1 FROM stock WHERE nomenclature_id = n.id)
+		OR EXISTS(SELECT 1 FROM reservation WHERE nomenclature_id = n.id) ORDER BY n.name, n.id`)
+	for _, s := range stock {
+		if rp["shortage"].Value.IntValue == 1 && s.Available >= 0 {
+			continue
+		}
+		res = append(res, s)
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /nomenclature/<id>/stock
+//
+// Answer: остаток и движения номенклатуры по дате
+//{
+//	stock {on_hand float, reserved float, available float}
+//	moves [
+//		{
+//			id          int
+//			type        string /*receipt - приход, issue - расход*/
+//			date        string
+//			quantity    float
+//			project_id  int
+//			comment     string
+//		}
+//	]
+//}
+//
+func GetStockOfNomenclature(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res struct {
+		Stock *APIStock      `json:"stock"`
+		Moves []APIStockMove `json:"moves"`
+	}
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	var stock []APIStock
+	stock, err = getStock(`WHERE n.id=?`, answer.ID)
+	if err != nil {
+		return
+	}
+	if len(stock) == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Номенклатура '%d' не найдена", answer.ID)
+		return
+	}
+	res.Stock = &stock[0]
+	res.Stock.Nomenclature = nil
+
+	var rows *sql.Rows
+	rows, err = db.DB.Query("SELECT id, date, quantity, project_id, comment FROM stock WHERE nomenclature_id=? ORDER BY date, id", answer.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m APIStockMove
+		err = rows.Scan(&m.ID, &m.Date, &m.Quantity, &m.ProjectID, &m.Comment)
+		if err != nil {
+			return
+		}
+		m.Type = StockReceipt
+		if m.Quantity < 0 {
+			m.Type, m.Quantity = StockIssue, -m.Quantity
+		}
+		res.Moves = append(res.Moves, m)
+	}
+	err = rows.Err()
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: PUT /nomenclature/<id>/stock?type=receipt|issue&quantity=<value>&date=<value>[?project_id=<value>][?comment=<value>]
+// Расход не может быть больше остатка на складе
+//
+func PutStockMove(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var nomenclatureID int64
+	nomenclatureID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"type":       {Optional: false, Type: String},
+		"quantity":   {Optional: false, Type: Float},
+		"date":       {Optional: false, Type: String},
+		"project_id": {Optional: true, Type: Int},
+		"comment":    {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parseDate("date")
+	}
+	if err == nil {
+		err = rp.parseStockMove()
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	// Остаток проверяется и расход записывается в одной транзакции: параллельный расход не выдаст тот же остаток дважды
+	var tx *sql.Tx
+	tx, err = db.DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if quantity := rp["quantity"].Value.FloatValue; quantity < 0 {
+		var onHand float64
+		err = tx.QueryRow("SELECT ifnull(sum(quantity), 0) FROM stock WHERE nomenclature_id=?", nomenclatureID).Scan(&onHand)
+		if err != nil {
+			return
+		}
+		if onHand < -quantity {
+			err = conflict("Недостаточно номенклатуры '%d' на складе", nomenclatureID)
+			return
+		}
+	}
+
+	// Insert into [stock]
+	rp["nomenclature_id"] = RequestParam{Type: Int, Value: RequestParamValue{Type: Int, IntValue: nomenclatureID}}
+	sqlText, sqlParams := rp.MakeSQLInsert("stock", []string{"nomenclature_id", "date", "quantity", "project_id", "comment"})
+	var res sql.Result
+	res, err = tx.Exec(sqlText, sqlParams...)
+	err = rp.ReferenceError("stock", err)
+	if err != nil {
+		return
+	}
+
+	answer.ID, err = res.LastInsertId()
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: DELETE /nomenclature/<id>/stock/<id>
+//
+func DeleteStockMove(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var nomenclatureID int64
+	nomenclatureID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID '%s'", request[1])
+		return
+	}
+
+	answer.ID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID движения '%s'", request[3])
+		return
+	}
+
+	_, err = db.DB.Exec("DELETE FROM stock WHERE id=? AND nomenclature_id=?", answer.ID, nomenclatureID)
+	return
+}
+
+// parseStockMove - проверка движения номенклатуры: тип, положительное количество
+// Количество расхода заменяется отрицательным, остаток для расхода проверяется при записи
+func (rps RequestParams) parseStockMove() error {
+	quantity := rps["quantity"]
+	if quantity.Value.FloatValue <= 0 {
+		return invalidField("quantity", "Количество должно быть больше нуля")
+	}
+
+	switch rps["type"].Value.StringValue {
+	case StockReceipt:
+	case StockIssue:
+		quantity.Value.FloatValue = -quantity.Value.FloatValue
+		rps["quantity"] = quantity
+	default:
+		return invalidField("type", "Неверный тип движения '%s', ожидается %s или %s", rps["type"].Value.StringValue, StockReceipt, StockIssue)
+	}
+	return nil
+}
+
+// getStock - остатки номенклатуры, where - условие и порядок для stockSQL
+func getStock(where string, args ...interface{}) (res []APIStock, err error) {
+	var rows *sql.Rows
+	rows, err = db.DB.Query(stockSQL+" "+where, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n := new(APINomenclature)
+		s := APIStock{Nomenclature: n}
+		err = rows.Scan(&n.ID, &n.Name, &n.VendorCode, &n.MeasureUnit, &s.OnHand, &s.Reserved)
+		if err != nil {
+			return
+		}
+		s.OnHand, s.Reserved = round3(s.OnHand), round3(s.Reserved)
+		s.Available = round3(s.OnHand - s.Reserved)
+		res = append(res, s)
+	}
+	err = rows.Err()
+	return
+}
@@ -14,26 +14,53 @@ type APIUser struct {
 	Phone    string `json:"phone,omitempty"`
 	Position string `json:"position,omitempty"`
 	Comment  string `json:"comment,omitempty"`
+	Admin    bool   `json:"admin,omitempty"` // Администратор: добавляет, изменяет и удаляет пользователей
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: GET /users
+// Request: GET /users[?name=<value>][?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
+// name - подстрока имени или логина, сортировка: id, login, name, position
 //
 func GetUsers(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	var res []APIUser
 	defer answer.make(&err, &res)
 
+	var rp RequestParams = RequestParams{
+		"name": {Optional: true, Type: String},
+	}.WithListParams()
+
+	err = rp.Parse(params)
+	if err != nil {
+		return
+	}
+
+	sqlText, countText, sqlParams, err := rp.MakeSQLSelect(ListQuery{
+		Select:     "SELECT id, login, name, phone, position, comment, admin",
+		From:       "FROM user",
+		Substrings: map[string]string{"name": "(name || ' ' || login)"},
+		Sort:       map[string]string{"id": "id", "login": "login", "name": "name", "position": "position"},
+		Order:      "id",
+	})
+	if err != nil {
+		return
+	}
+	answer.Total = new(int64)
+	err = db.DB.QueryRow(countText, sqlParams...).Scan(answer.Total)
+	if err != nil {
+		return
+	}
+
 	// Select data from table [user]
 	var rows *sql.Rows
-	rows, err = db.DB.Query("SELECT id, login, name, phone, position, comment FROM user")
+	rows, err = db.DB.Query(sqlText, sqlParams...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var u APIUser
-		err = rows.Scan(&u.ID, &u.Login, &u.Name, &u.Phone, &u.Position, &u.Comment)
+		err = rows.Scan(&u.ID, &u.Login, &u.Name, &u.Phone, &u.Position, &u.Comment, &u.Admin)
 		if err != nil {
 			return
 		}
@@ -56,8 +83,8 @@ func GetUser(request []string, params map[string][]string) (answer Answer) {
 
 	// Select data from [user]
 	var row *sql.Row
-	row = db.DB.QueryRow("SELECT id, name, phone, position, comment FROM user WHERE login=?", request[1])
-	err = row.Scan(&res.ID, &res.Name, &res.Phone, &res.Position, &res.Comment)
+	row = db.DB.QueryRow("SELECT id, name, phone, position, comment, admin FROM user WHERE login=?", request[1])
+	err = row.Scan(&res.ID, &res.Name, &res.Phone, &res.Position, &res.Comment, &res.Admin)
 	// If no rows, just return empty result
 	if err == sql.ErrNoRows {
 		err = nil
@@ -73,12 +100,18 @@ func GetUser(request []string, params map[string][]string) (answer Answer) {
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: PUT /users?login=<login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>]
+// Request: PUT /users?login=<login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>][?admin=0|1]
+// Тело: [password=<value>]. Только для администратора
 //
 func PutUser(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	defer answer.make(&err, nil)
 
+	_, err = sessionAdmin(params)
+	if err != nil {
+		return
+	}
+
 	// Parse user request parameters
 	var rp RequestParams = RequestParams{
 		"login":    {Optional: false, Type: String},
@@ -86,16 +119,24 @@ func PutUser(request []string, params map[string][]string) (answer Answer) {
 		"phone":    {Optional: true, Type: String},
 		"position": {Optional: true, Type: String},
 		"comment":  {Optional: true, Type: String},
+		"password": {Optional: true, Type: String},
+		"admin":    {Optional: true, Type: Int},
 	}
 
 	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePassword()
+	}
+	if err == nil {
+		err = rp.checkAdmin()
+	}
 	if err != nil {
 		answer.Code = BadRequest
 		return
 	}
 
 	// Insert into [user]
-	sqlText, sqlParams := rp.MakeSQLInsert("user", []string{"login", "name", "phone", "position", "comment"})
+	sqlText, sqlParams := rp.MakeSQLInsert("user", []string{"login", "name", "phone", "position", "comment", "password_hash", "admin"})
 	var res sql.Result
 	res, err = db.DB.Exec(sqlText, sqlParams...)
 	if err != nil {
@@ -108,7 +149,10 @@ func PutUser(request []string, params map[string][]string) (answer Answer) {
 }
 
 ///////////////////////////////////////////////////////////////////////////////
-// Request: POST /users/<login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>]
+// Request: POST /users/<login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>][?admin=0|1]
+// Тело: [password=<value>][current_password=<value>]
+// Пользователь изменяет свои данные, кроме admin, новый пароль - с текущим паролем current_password
+// Других пользователей и admin изменяет только администратор, текущий пароль другого пользователя не требуется
 //
 func PostUser(request []string, params map[string][]string) (answer Answer) {
 	var err error
@@ -118,28 +162,53 @@ func PostUser(request []string, params map[string][]string) (answer Answer) {
 
 	// Parse user request parameters
 	var rp RequestParams = RequestParams{
-		"name":     {Optional: true, Type: String},
-		"phone":    {Optional: true, Type: String},
-		"position": {Optional: true, Type: String},
-		"comment":  {Optional: true, Type: String},
+		"name":             {Optional: true, Type: String},
+		"phone":            {Optional: true, Type: String},
+		"position":         {Optional: true, Type: String},
+		"comment":          {Optional: true, Type: String},
+		"password":         {Optional: true, Type: String},
+		"current_password": {Optional: true, Type: String},
+		"admin":            {Optional: true, Type: Int},
 	}
 
 	err = rp.Parse(params)
+	if err == nil {
+		err = rp.parsePassword()
+	}
+	if err == nil {
+		err = rp.checkAdmin()
+	}
 	if err != nil {
 		answer.Code = BadRequest
 		return
 	}
 
 	// Get user id
+	var hash string
 	var row *sql.Row
-	row = db.DB.QueryRow("SELECT id FROM user WHERE login=?", login)
-	err = row.Scan(&answer.ID)
+	row = db.DB.QueryRow("SELECT id, password_hash FROM user WHERE login=?", login)
+	err = row.Scan(&answer.ID, &hash)
 	if err != nil {
 		return
 	}
 
+	var sessionID int64
+	sessionID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+	if sessionID != answer.ID || rp["admin"].Exists() {
+		_, err = sessionAdmin(params)
+		if err != nil {
+			return
+		}
+	} else if rp["password_hash"].Exists() && !db.CheckPassword(hash, rp["current_password"].Value.StringValue) {
+		err = validationError([]APIFieldError{{Field: "current_password", Code: FieldInvalid, Message: "Для смены пароля требуется верный текущий пароль"}})
+		return
+	}
+
 	// Update [user]
-	sqlText, sqlParams := rp.MakeSQLUpdate("user", []string{"name", "phone", "position", "comment"}, answer.ID)
+	sqlText, sqlParams := rp.MakeSQLUpdate("user", []string{"name", "phone", "position", "comment", "password_hash", "admin"}, answer.ID)
 	if len(sqlParams) > 0 {
 		_, err = db.DB.Exec(sqlText, sqlParams...)
 		if err != nil {
@@ -147,17 +216,54 @@ func PostUser(request []string, params map[string][]string) (answer Answer) {
 		}
 	}
 
+	// Смена пароля закрывает все сессии пользователя
+	if rp["password_hash"].Exists() {
+		_, err = db.DB.Exec("DELETE FROM session WHERE user_id=?", answer.ID)
+	}
+
 	return
 }
 
 ///////////////////////////////////////////////////////////////////////////////
 // Request: DELETE /users/<login>
+// Только для администратора
 //
 func DeleteUser(request []string, params map[string][]string) (answer Answer) {
 	var err error
 	defer answer.make(&err, nil)
 
+	_, err = sessionAdmin(params)
+	if err != nil {
+		return
+	}
+
 	// Delete from [user]
 	_, err = db.DB.Exec("DELETE FROM user WHERE login=?", request[1])
 	return
 }
+
+// checkAdmin - признак администратора admin: 0 или 1
+func (rps RequestParams) checkAdmin() error {
+	rp := rps["admin"]
+	if rp.Exists() && rp.Value.IntValue != 0 && rp.Value.IntValue != 1 {
+		return invalidField("admin", "Признак администратора должен быть 0 или 1")
+	}
+	return nil
+}
+
+// parsePassword - хеш пароля из параметра password для поля password_hash
+func (rps RequestParams) parsePassword() error {
+	rp := rps["password"]
+	if !rp.Exists() {
+		return nil
+	}
+	if len(rp.Value.StringValue) < 6 {
+		return invalidField("password", "Пароль должен содержать не менее 6 символов")
+	}
+	hash, err := db.HashPassword(rp.Value.StringValue)
+	if err != nil {
+		return err
+	}
+	rps["password_hash"] = RequestParam{Type: String, Value: RequestParamValue{Type: String, StringValue: hash}, Name: "password"}
+	return nil
+}
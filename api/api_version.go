@@ -0,0 +1,355 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"knx/db"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// APIVersion - версия сметы проекта: неизменяемая копия участков с параметрами, частями и результатами расчета
+type APIVersion struct {
+	ID       int64               `json:"id,omitempty"`
+	Name     string              `json:"name"`
+	Date     string              `json:"date"`
+	Comment  string              `json:"comment,omitempty"`
+	User     *APIUser            `json:"user,omitempty"`
+	Active   bool                `json:"active,omitempty"` // Участки проекта скопированы из этой версии
+	Regions  []APIProjectResults `json:"regions,omitempty"`
+	Estimate *APIEstimate        `json:"estimate,omitempty"`
+}
+
+// APIVersionLine - номенклатура двух сравниваемых версий
+type APIVersionLine struct {
+	Nomenclature *APINomenclature `json:"nomenclature"`
+	Quantity     [2]float64       `json:"quantity"`
+	Total        [2]int64         `json:"total"` // Сумма по цене версии, в копейках
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// APIVersionComparison
+type APIVersionComparison struct {
+	Versions [2]*APIVersion   `json:"versions"`
+	Lines    []APIVersionLine `json:"lines"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/versions
+//
+// Answer: версии проекта по дате создания
+//[
+//	{
+//		id        int
+//		name      string
+//		date      string
+//		comment   string
+//		user      {id int, login string, name string}
+//		active    bool /*Участки проекта скопированы из этой версии*/
+//		estimate  {total int, cost_total int, margin int, missing_prices int}
+//	}
+//]
+//
+// Request: GET /projects/<id>/versions/<id>
+// Answer: версия с участками и результатами в формате GET /projects/<id>/results, цены сохранены в версии
+//
+// Request: GET /projects/<id>/versions/<id>/compare/<id>
+// Answer:
+//{
+//	versions [2]{id int, name string, date string, comment string, estimate {...}}
+//	lines [
+//		{
+//			nomenclature {id int, name string, vendor_code string, measure_unit string}
+//			quantity     [2]float /*Количество в первой и второй версии*/
+//			total        [2]int   /*Сумма в первой и второй версии, в копейках*/
+//		}
+//	]
+//}
+//
+func GetVersionsOfProject(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res []APIVersion
+	defer answer.make(&err, &res)
+
+	answer.ID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var ids []int64
+	var rows *sql.Rows
+	rows, err = db.DB.Query("SELECT id FROM project_version WHERE project_id=? ORDER BY date, id", answer.ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		var v APIVersion
+		v, err = getVersion(answer.ID, id)
+		if err != nil {
+			return
+		}
+		v.Regions = nil
+		res = append(res, v)
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/versions/<id>
+//
+func GetVersion(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APIVersion
+	defer answer.make(&err, &res)
+
+	var projectID int64
+	projectID, answer.ID, err = parseVersionRequest(request)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	res, err = getVersion(projectID, answer.ID)
+	if err == sql.ErrNoRows {
+		answer.Code = NotFound
+		err = fmt.Errorf("Версия '%d' не найдена в проекте '%d'", answer.ID, projectID)
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: PUT /projects/<id>/versions?name=<value>[?comment=<value>]
+// Сохранение текущих участков проекта в новую версию
+//
+func PutVersion(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		answer.Code = BadRequest
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	var userID int64
+	userID, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
+	// Parse user request parameters
+	var rp RequestParams = RequestParams{
+		"name":    {Optional: false, Type: String},
+		"comment": {Optional: true, Type: String},
+	}
+
+	err = rp.Parse(params)
+	if err == nil && rp["name"].Value.StringValue == "" {
+		err = invalidField("name", "Не задано название версии")
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+	name := rp["name"].Value.StringValue
+
+	var projects, versions int
+	err = db.DB.QueryRow(`SELECT (SELECT count(*) FROM project WHERE id=?),
+		(SELECT count(*) FROM project_version WHERE project_id=? AND name=?)`, projectID, projectID, name).Scan(&projects, &versions)
+	if err == nil && projects == 0 {
+		answer.Code = NotFound
+		err = fmt.Errorf("Проект '%d' не найден", projectID)
+	}
+	if err == nil && versions > 0 {
+		answer.Code = Conflict
+		err = fmt.Errorf("Версия '%s' уже есть в проекте '%d'", name, projectID)
+	}
+	if err != nil {
+		return
+	}
+
+	answer.ID, err = db.SaveVersion(projectID, name, rp["comment"].Value.StringValue, userID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: PUT /projects/<id>/versions/<id>/promote
+// Участки проекта заменяются копией участков версии, версия становится активной
+// Недоступно после договора: участки проекта не изменяются
+//
+func PromoteVersion(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, answer.ID, err = parseVersionRequest(request)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = checkVersionOfProject(projectID, answer.ID)
+	if err == nil {
+		err = checkProjectNotFrozen(projectID)
+	}
+	if err != nil {
+		return
+	}
+
+	err = db.PromoteVersion(projectID, answer.ID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: DELETE /projects/<id>/versions/<id>
+//
+func DeleteVersion(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	defer answer.make(&err, nil)
+
+	var projectID int64
+	projectID, answer.ID, err = parseVersionRequest(request)
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	err = checkVersionOfProject(projectID, answer.ID)
+	if err != nil {
+		return
+	}
+
+	// Delete from [project_version], regions of the version are deleted by cascade
+	_, err = db.DB.Exec("DELETE FROM project_version WHERE id=? AND project_id=?", answer.ID, projectID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Request: GET /projects/<id>/versions/<id>/compare/<id>
+//
+func CompareVersions(request []string, params map[string][]string) (answer Answer) {
+	var err error
+	var res APIVersionComparison
+	defer answer.make(&err, &res)
+
+	var projectID, otherID int64
+	projectID, answer.ID, err = parseVersionRequest(request)
+	if err == nil {
+		otherID, err = strconv.ParseInt(request[5], 10, 64)
+		if err != nil {
+			err = fmt.Errorf("Неверный ID версии '%s'", request[5])
+		}
+	}
+	if err != nil {
+		answer.Code = BadRequest
+		return
+	}
+
+	for i, id := range []int64{answer.ID, otherID} {
+		var v APIVersion
+		v, err = getVersion(projectID, id)
+		if err == sql.ErrNoRows {
+			answer.Code = NotFound
+			err = fmt.Errorf("Версия '%d' не найдена в проекте '%d'", id, projectID)
+		}
+		if err != nil {
+			return
+		}
+		v.Regions = nil
+		res.Versions[i] = &v
+	}
+
+	// Nomenclature of both versions, quantities and totals by the prices of each version
+	var rows *sql.Rows
+	rows, err = db.DB.Query(`SELECT n.id, n.name, n.vendor_code, n.measure_unit,
+		ifnull(sum(CASE WHEN g.version_id=? THEN r.value END), 0), ifnull(sum(CASE WHEN g.version_id=? THEN r.value END), 0),
+		CAST(ifnull(sum(CASE WHEN g.version_id=? THEN round(r.value * r.price) END), 0) AS INTEGER),
+		CAST(ifnull(sum(CASE WHEN g.version_id=? THEN round(r.value * r.price) END), 0) AS INTEGER)
+		FROM result r INNER JOIN region g ON g.id = r.region_id
+		INNER JOIN nomenclature n ON n.id = r.nomenclature_id
+		WHERE g.version_id IN (?, ?)
+		GROUP BY n.id ORDER BY n.name, n.id`, answer.ID, otherID, answer.ID, otherID, answer.ID, otherID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n := new(APINomenclature)
+		l := APIVersionLine{Nomenclature: n}
+		err = rows.Scan(&n.ID, &n.Name, &n.VendorCode, &n.MeasureUnit, &l.Quantity[0], &l.Quantity[1], &l.Total[0], &l.Total[1])
+		if err != nil {
+			return
+		}
+		l.Quantity[0], l.Quantity[1] = round3(l.Quantity[0]), round3(l.Quantity[1])
+		res.Lines = append(res.Lines, l)
+	}
+	err = rows.Err()
+	return
+}
+
+// parseVersionRequest - ID проекта и версии из запроса /projects/<id>/versions/<id>
+func parseVersionRequest(request []string) (projectID, versionID int64, err error) {
+	projectID, err = strconv.ParseInt(request[1], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Неверный ID проекта '%s'", request[1])
+		return
+	}
+
+	versionID, err = strconv.ParseInt(request[3], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("Неверный ID версии '%s'", request[3])
+	}
+	return
+}
+
+// checkVersionOfProject - проверка, что версия принадлежит проекту
+func checkVersionOfProject(projectID, versionID int64) (err error) {
+	var count int
+	err = db.DB.QueryRow("SELECT count(*) FROM project_version WHERE id=? AND project_id=?", versionID, projectID).Scan(&count)
+	if err == nil && count == 0 {
+		err = notFound("Версия '%d' не найдена в проекте '%d'", versionID, projectID)
+	}
+	return
+}
+
+// getVersion - версия проекта с участками, результатами и итогами
+func getVersion(projectID, versionID int64) (v APIVersion, err error) {
+	var u APIUser
+	v = APIVersion{ID: versionID, User: &u}
+	err = db.DB.QueryRow(`SELECT v.name, v.date, v.comment, u.id, u.login, u.name, ifnull(p.version_id = v.id, 0)
+		FROM project_version v INNER JOIN project p ON p.id = v.project_id INNER JOIN user u ON u.id = v.user_id
+		WHERE v.id=? AND v.project_id=?`, versionID, projectID).Scan(&v.Name, &v.Date, &v.Comment, &u.ID, &u.Login, &u.Name, &v.Active)
+	if err != nil {
+		return
+	}
+
+	var results map[int64][]APIResult
+	results, err = getVersionResults(versionID)
+	if err != nil {
+		return
+	}
+
+	var estimate APIEstimate
+	v.Regions, estimate, err = getEstimate(db.RegionsOfVersion, versionID, results)
+	v.Estimate = &estimate
+	return
+}
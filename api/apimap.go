@@ -3,6 +3,24 @@ package api
 /*
 /////////////////////////////////////////////////////////////////////////////////////////////////////////
 // Карта всех вызовов функций для команд (get, put, post, del)
+// В API v1 команда задается HTTP-методом: PUT из карты - POST (создание), POST из карты - PUT или PATCH (изменение)
+// В API v1 ресурсы названы через дефис: /region-types, /param-types, /part-types, /component-types, /result-types,
+// /calculation-types, /nomenclature-types, /color-schemes; импорт номенклатуры - POST /nomenclature-import
+// API v0 устарела: ответы содержат заголовки Deprecation и Link на тот же запрос в v1
+// GET / - список поддерживаемых версий API
+// Изменения (PUT, POST, DELETE) - только в сессии пользователя, кроме POST /session (вход): токен сессии
+// передается в заголовке Authorization: Bearer <token> или в cookie knx_session, без сессии - Unauthorized (401)
+// Добавление и удаление пользователей, изменение других пользователей и признака admin - только администратору,
+// иначе Forbidden (403). Свой пароль пользователь меняет с параметром current_password
+// Cookie knx_session не принимается для команд, заданных параметром ?method=, и для GET /import_nomenclature
+// Параметры тела запроса (JSON-объект или форма) заменяют одноименные параметры строки запроса; login для /session,
+// password и current_password принимаются только в теле запроса
+// Списки с параметрами sort, limit, offset выводят в Answer.Total количество элементов без учета limit и offset
+// Массив JSON и повторенный параметр - несколько значений: значения списков объединяются, для прочих берется первое
+// В v0 коды ответа прежние: не найденный элемент и конфликт - BadRequest (400), в Answer.Error - точный код ошибки
+// Ошибка выводится в Answer.Error: {code string, message string, fields [{field string, code string, message string}]}
+// code: bad_request, validation_failed, invalid_reference, unauthorized, forbidden, not_found, conflict, internal_error
+// fields[].code: missing, invalid, unknown (параметр не предусмотрен запросом), not_found (ссылка на несуществующий элемент)
 
 GET /region_types
 GET /region_types/<id>
@@ -47,26 +65,38 @@ POST /result_types/<id>[?name=<Value>][?description=<Value>]
 
 --------------------------------------------------------------------------------------------------------
 
-GET /сalculation_types/
+GET /calculation_types
+GET /calculation_types/<id>
 
-PUT /calculation_types/<id>?name=<value>
+POST /calculation_types/<id>?name=<value>
 
 --------------------------------------------------------------------------------------------------------
 
-GET /users
+GET /users[?name=<value>][?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
 GET /users/<Login>
 
-PUT /users?login=<Login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>]
+PUT /users?login=<Login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>][?admin=0|1], тело: [password=<value>]
 
-POST /users/<login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>]
+POST /users/<login>[?name=<Value>][?phone=<value>][?position=<value>][?comment=<value>][?admin=0|1], тело: [password=<value>][current_password=<value>]
 
 DELETE /users/<login>
 
 --------------------------------------------------------------------------------------------------------
 
-GET /clients
+GET /session
+POST /session, тело (JSON или форма): login=<value>&password=<value>
+DELETE /session
+
+--------------------------------------------------------------------------------------------------------
+
+GET /company
+POST /company[?name=<value>][?address=<value>][?phone=<value>][?email=<value>][?offer_valid_days=<value>][?pdf_font=<value>] - только администратору
+
+--------------------------------------------------------------------------------------------------------
+
+GET /clients[?name=<value>][?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
 GET /clients/<id>
-GET /clients/<id>/projects
+GET /clients/<id>/projects[?<параметры GET /projects, кроме client_id>]
 
 PUT /clients?name=<value>[?comment=<value>]
 PUT /clients/<id>/projects?contract_date=<value>[?install_date=<value>][?comment=<value>]
@@ -76,25 +106,45 @@ POST /clients/<id>[?name=value][?comment=<value>]
 DELETE /clients/<id>
 
 --------------------------------------------------------------------------------------------------------
-GET /projects
+GET /projects[?client_id=<value>][?user_id=<value>][?status=<value>,<value>,...][?contract_from=<value>][?contract_to=<value>]
+	[?install_from=<value>][?install_to=<value>][?address=<value>][?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
 GET /projects/<id>
 GET /projects/<id>/regions
 GET /projects/<id>/regions/<id>
 GET /projects/<id>/regions/<id>/results
+GET /projects/<id>/regions/<id>/drawing.svg
 GET /projects/<id>/regions/<id>/components
 GET /projects/<id>/regions/<id>/components/<id>
 GET /projects/<id>/regions/<id>/components/<id>/parts
 GET /projects/<id>/regions/<id>/components/<id>/parts/<id>
+GET /projects/<id>/regions/<id>/segments
+GET /projects/<id>/regions/<id>/segments/<id>
 GET /projects/<id>/results
+GET /projects/<id>/offer[?format=html|pdf][?date=<value>][?valid_days=<value>]
+GET /projects/<id>/bom[?format=xlsx|csv]
+GET /projects/<id>/reservations
+GET /projects/<id>/statuses
+GET /projects/<id>/versions
+GET /projects/<id>/versions/<id>
+GET /projects/<id>/versions/<id>/compare/<id>
 
 PUT /projects/<id>/regions?region_type=<Value>[?description=<value>][?params=<param_id>(<value>),<param_id>(<value>),...]
 PUT /projects/<id>/regions/<id>/components?component_type=<id>,<id>,...
+PUT /projects/<id>/regions/<id>/segments?length=<value>[?angle=<value>][?elevation=<value>][?nr=<value>]
+PUT /projects/<id>/reservations
+PUT /projects/<id>/versions?name=<value>[?comment=<value>]
+PUT /projects/<id>/versions/<id>/promote
 
-POST /projects/<id>[?contract_date=<value>][?install_date=<value>][?comment=<value>]
+POST /projects/<id>[?contract_date=<value>][?install_date=<value>][?comment=<value>][?status=<value>]
 POST /projects/<id>/regions/<id>[?description=<value>][?params=<param_id>(<value>),<param_id>(<value>),...]
 POST /projects/<id>/regions/<id>/components?component_type=<id>,<id>,...
+POST /projects/<id>/regions/<id>/segments/<id>[?length=<value>][?angle=<value>][?elevation=<value>][?nr=<value>]
 
 DELETE /projects/<id>/regions/<id>
+DELETE /projects/<id>/regions/<id>/components?component_type=<id>,<id>,...
+DELETE /projects/<id>/regions/<id>/segments/<id>
+DELETE /projects/<id>/reservations
+DELETE /projects/<id>/versions/<id>
 
 --------------------------------------------------------------------------------------------------------
 
@@ -105,38 +155,43 @@ GET /component_types/<id>/part_types/<id>
 GET /component_types/<id>/part_types/<id>/nomenclature
 
 PUT /component_types?name=<value>
-PUT /component_types/<id>/part_types?name=<value>[?calculation_type_id=<value>]
+PUT /component_types/<id>/part_types?name=<value>[?calculation_type_id=<value>][?formula=<value>]
 PUT /component_types/<id>/part_types/<id>/nomenclature?<id>,<id>,...
 
 POST /component_types/<id>?name=<value>
-POST /component_types/<id>/part_types/<id>[?name=<value>][?calculation_type_id=<value>]
+POST /component_types/<id>/part_types/<id>[?name=<value>][?calculation_type_id=<value>][?formula=<value>]
 POST /component_types/<id>/part_types/<id>/nomenclature?<id>,<id>,...
 
 --------------------------------------------------------------------------------------------------------
 
 GET /nomenclature_types
 GET /nomenclature_types/<id>
-GET /nomenclature_types/<id>/nomenclature
-GET /nomenclature_types/<id>/nomenclature
+GET /nomenclature_types/<id>/nomenclature[?<параметры GET /nomenclature, кроме nomenclature_type_id>]
 
 PUT /nomenclature_types?name=<value>[?color_scheme_id=<value>][?use_fields=<field_name>,<field_name>,...]
 PUT /nomenclature_types/<id>/nomenclature?name=<value>[?vendor_code=<value>][mesure_unit=<value>]
-	[?material=<value>][?thickness=<value>][?color_id=<value>][?size=<value>][division=<value>,<value>,...][division_service_nomenclature_id=<value>]
+	[?material=<value>][?thickness=<value>][?color_id=<value>][?size=<value>][?width=<value>][division=<value>,<value>,...][division_service_nomenclature_id=<value>]
 
 POST /nomenclature_types/<id>?name=<value>[?color_scheme_id=<value>][?use_fields=<field_name>,<field_name>,...]
 POST /nomenclature_types/<id>/nomenclature/<id>[?те же параметры, что и в PUT]
 
 --------------------------------------------------------------------------------------------------------
 
-GET /nomenclature
+GET /nomenclature[?nomenclature_type_id=<value>][?name=<value>][?vendor_code=<value>][?material=<value>][?sort=[-]<column>,...][?limit=<value>][?offset=<value>]
 GET /nomenclature/<id>
 GET /nomenclature/<id>/price
 GET /nomenclature/<id>/price?date=<value>
+GET /nomenclature/<id>/stock
 
 PUT /nomenclature/<id>/price?date=<value>[?price=<value>][?cost_price=<value>]
+PUT /nomenclature/<id>/stock?type=receipt|issue&quantity=<value>&date=<value>[?project_id=<value>][?comment=<value>]
 
 POST /nomenclature/<id>/price?date=<value>[?price=<value>][?cost_price=<value>]
 
+DELETE /nomenclature/<id>/stock/<id>
+
+GET /stock[?shortage=1]
+
 --------------------------------------------------------------------------------------------------------
 
 GET /color_schemes
@@ -146,10 +201,37 @@ PUT /color_schemes[?name=<value>][?colors=<value>[(<name>)],<value>[(<name>)],..
 
 POST /color_schemes/<id>[?name=<value>][?colors=<value>[(<name>)],<value>[(<name>)],...]
 
+--------------------------------------------------------------------------------------------------------
+
+GET /purchases[?from=<value>][?to=<value>][?format=json|csv]
+
 /////////////////////////////////////////////////////////////////////////////////////////////////////////
 */
 
-var F map[string]HTTPCallbackSet = map[string]HTTPCallbackSet{
+// F - карты вызовов функций для каждой версии API
+var F map[string]map[string]HTTPCallbackSet = map[string]map[string]HTTPCallbackSet{
+	APIVersion0: F0,
+	APIVersion1: F1,
+}
+
+// Resources - переименования ресурсов v0 в версиях-преемниках, для заголовка Link устаревшей версии
+var Resources map[string]map[string]string = map[string]map[string]string{
+	APIVersion1: {
+		"region_types":        "region-types",
+		"param_types":         "param-types",
+		"part_types":          "part-types",
+		"component_types":     "component-types",
+		"result_types":        "result-types",
+		"calculation_types":   "calculation-types",
+		"сalculation_types":   "calculation-types",
+		"nomenclature_types":  "nomenclature-types",
+		"color_schemes":       "color-schemes",
+		"import_nomenclature": "nomenclature-import",
+	},
+}
+
+// F0 - API v0, устаревшая
+var F0 map[string]HTTPCallbackSet = map[string]HTTPCallbackSet{
 	"region_types":                    {GetRegionTypes, NotImplemented, NotImplemented, NotImplemented},
 	"region_types<id>":                {GetRegionType, NotImplemented, PostRegionType, NotImplemented},
 	"region_types<id>param_types":     {GetParamTypesOfRegionType, PutParamTypesOfRegionType, PostParamTypesOfRegionType, DeleteParamTypesOfRegionType},
@@ -164,12 +246,19 @@ var F map[string]HTTPCallbackSet = map[string]HTTPCallbackSet{
 	"result_types":     {GetResultTypes, NotImplemented, NotImplemented, NotImplemented},
 	"result_types<id>": {GetResultType, NotImplemented, PostResultType, NotImplemented},
 
+	"calculation_types":     {GetCalculationTypes, NotImplemented, NotImplemented, NotImplemented},
+	"calculation_types<id>": {GetCalculationType, NotImplemented, PostCalculationType, NotImplemented},
+	// Прежнее имя с кириллической "с", v0 не изменяется
 	"сalculation_types":     {GetCalculationTypes, NotImplemented, NotImplemented, NotImplemented},
 	"сalculation_types<id>": {GetCalculationType, NotImplemented, PostCalculationType, NotImplemented},
 
 	"users":     {GetUsers, PutUser, NotImplemented, NotImplemented},
 	"users<id>": {GetUser, NotImplemented, PostUser, DeleteUser},
 
+	"session": {GetSession, PutSession, PutSession, DeleteSession},
+
+	"company": {GetCompany, NotImplemented, PostCompany, NotImplemented},
+
 	"clients":             {GetClients, PutClient, NotImplemented, NotImplemented},
 	"clients<id>":         {GetClient, NotImplemented, PostClient, DeleteClient},
 	"clients<id>projects": {GetProjectsOfClient, PutProject, NotImplemented, NotImplemented},
@@ -179,11 +268,22 @@ var F map[string]HTTPCallbackSet = map[string]HTTPCallbackSet{
 	"projects<id>regions":                            {GetRegionsOfProject, PutRegion, NotImplemented, NotImplemented},
 	"projects<id>regions<id>":                        {GetRegion, NotImplemented, PostRegion, DeleteRegion},
 	"projects<id>regions<id>results":                 {GetResultsOfRegion, NotImplemented, NotImplemented, NotImplemented},
-	"projects<id>regions<id>components":              {GetComponentsOfRegion, PutComponent, NotImplemented, NotImplemented},
-	"projects<id>regions<id>components<id>":          {GetComponent, NotImplemented, PostComponent, DeleteComponent},
+	"projects<id>regions<id>drawing.svg":             {GetRegionDrawing, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>regions<id>components":              {GetComponentsOfRegion, PutComponent, PostComponent, DeleteComponent},
+	"projects<id>regions<id>components<id>":          {GetComponent, NotImplemented, NotImplemented, NotImplemented},
 	"projects<id>regions<id>components<id>parts":     {NotImplemented, NotImplemented, NotImplemented, NotImplemented},
 	"projects<id>regions<id>components<id>parts<id>": {NotImplemented, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>regions<id>segments":                {GetSegmentsOfRegion, PutSegment, NotImplemented, NotImplemented},
+	"projects<id>regions<id>segments<id>":            {GetSegment, NotImplemented, PostSegment, DeleteSegment},
 	"projects<id>results":                            {GetResultsOfProject, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>offer":                              {GetProjectOffer, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>bom":                                {GetProjectBOM, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>reservations":                       {GetReservationsOfProject, PutReservations, NotImplemented, DeleteReservations},
+	"projects<id>statuses":                           {GetStatusesOfProject, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>versions":                           {GetVersionsOfProject, PutVersion, NotImplemented, NotImplemented},
+	"projects<id>versions<id>":                       {GetVersion, NotImplemented, NotImplemented, DeleteVersion},
+	"projects<id>versions<id>promote":                {NotImplemented, PromoteVersion, NotImplemented, NotImplemented},
+	"projects<id>versions<id>compare<id>":            {CompareVersions, NotImplemented, NotImplemented, NotImplemented},
 
 	"component_types":                               {GetComponentTypes, PutComponentType, NotImplemented, NotImplemented},
 	"component_types<id>":                           {GetComponentType, NotImplemented, PostComponentType, DeleteComponentType},
@@ -195,12 +295,94 @@ var F map[string]HTTPCallbackSet = map[string]HTTPCallbackSet{
 	"nomenclature_types<id>":             {GetNomenclatureType, NotImplemented, PostNomenclatureType, DeleteNomenclatureType},
 	"nomenclature_types<id>nomenclature": {GetNomenclatureOfNomenclatureType, PutNomenclature, NotImplemented, NotImplemented},
 
-	"nomenclature":          {GetNomenclatures, NotImplemented, NotImplemented, NotImplemented},
-	"nomenclature<id>":      {GetNomenclature, NotImplemented, PostNomenclature, DeleteNomenclature},
-	"nomenclature<id>price": {GetPrice, PutPrice, PostPrice, DeletePrice},
+	"nomenclature":              {GetNomenclatures, NotImplemented, NotImplemented, NotImplemented},
+	"nomenclature<id>":          {GetNomenclature, NotImplemented, PostNomenclature, DeleteNomenclature},
+	"nomenclature<id>price":     {GetPrice, PutPrice, PostPrice, DeletePrice},
+	"nomenclature<id>stock":     {GetStockOfNomenclature, PutStockMove, NotImplemented, NotImplemented},
+	"nomenclature<id>stock<id>": {NotImplemented, NotImplemented, NotImplemented, DeleteStockMove},
+
+	"stock": {GetStock, NotImplemented, NotImplemented, NotImplemented},
 
 	"color_schemes":     {GetColorSchemes, PutColorScheme, NotImplemented, NotImplemented},
 	"color_schemes<id>": {GetColorScheme, NotImplemented, PostColorSchemes, DeleteColorScheme},
 
+	"purchases": {GetPurchases, NotImplemented, NotImplemented, NotImplemented},
+
 	"import_nomenclature": {GetImportNomenclature, NotImplemented, NotImplemented, NotImplemented},
 }
+
+// F1 - API v1: ресурсы названы через дефис, импорт номенклатуры - POST /nomenclature-import
+var F1 map[string]HTTPCallbackSet = map[string]HTTPCallbackSet{
+	"region-types":                    {GetRegionTypes, NotImplemented, NotImplemented, NotImplemented},
+	"region-types<id>":                {GetRegionType, NotImplemented, PostRegionType, NotImplemented},
+	"region-types<id>param-types":     {GetParamTypesOfRegionType, PutParamTypesOfRegionType, PostParamTypesOfRegionType, DeleteParamTypesOfRegionType},
+	"region-types<id>component-types": {GetComponentTypesOfRegionType, PutComponentTypesOfRegionType, PostComponentTypesOfRegionType, DeleteComponentTypesOfRegionType},
+
+	"param-types":                           {GetParamTypes, NotImplemented, NotImplemented, NotImplemented},
+	"param-types<id>":                       {GetParamType, NotImplemented, PostParamType, NotImplemented},
+	"param-types<id>part-types":             {GetPartTypesOfParamType, PutPartTypesOfParamType, PostPartTypesOfParamType, DeletePartTypesOfParamType},
+	"param-types<id>values":                 {GetValuesOfParamType, PutValuesOfParamType, PostValuesOfParamType, DeleteValuesOfParamType},
+	"param-types<id>values<id>nomenclature": {GetNomenclatureForValueOfParamType, PutNomenclatureForValueOfParamType, PostNomenclatureForValueOfParamType, DeleteNomenclatureForValueOfParamType},
+
+	"result-types":     {GetResultTypes, NotImplemented, NotImplemented, NotImplemented},
+	"result-types<id>": {GetResultType, NotImplemented, PostResultType, NotImplemented},
+
+	"calculation-types":     {GetCalculationTypes, NotImplemented, NotImplemented, NotImplemented},
+	"calculation-types<id>": {GetCalculationType, NotImplemented, PostCalculationType, NotImplemented},
+
+	"users":     {GetUsers, PutUser, NotImplemented, NotImplemented},
+	"users<id>": {GetUser, NotImplemented, PostUser, DeleteUser},
+
+	"session": {GetSession, PutSession, NotImplemented, DeleteSession},
+
+	"company": {GetCompany, NotImplemented, PostCompany, NotImplemented},
+
+	"clients":             {GetClients, PutClient, NotImplemented, NotImplemented},
+	"clients<id>":         {GetClient, NotImplemented, PostClient, DeleteClient},
+	"clients<id>projects": {GetProjectsOfClient, PutProject, NotImplemented, NotImplemented},
+
+	"projects":                              {GetProjects, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>":                          {GetProject, NotImplemented, PostProject, DeleteProject},
+	"projects<id>regions":                   {GetRegionsOfProject, PutRegion, NotImplemented, NotImplemented},
+	"projects<id>regions<id>":               {GetRegion, NotImplemented, PostRegion, DeleteRegion},
+	"projects<id>regions<id>results":        {GetResultsOfRegion, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>regions<id>drawing.svg":    {GetRegionDrawing, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>regions<id>components":     {GetComponentsOfRegion, PutComponent, PostComponent, DeleteComponent},
+	"projects<id>regions<id>components<id>": {GetComponent, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>regions<id>segments":       {GetSegmentsOfRegion, PutSegment, NotImplemented, NotImplemented},
+	"projects<id>regions<id>segments<id>":   {GetSegment, NotImplemented, PostSegment, DeleteSegment},
+	"projects<id>results":                   {GetResultsOfProject, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>offer":                     {GetProjectOffer, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>bom":                       {GetProjectBOM, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>reservations":              {GetReservationsOfProject, PutReservations, NotImplemented, DeleteReservations},
+	"projects<id>statuses":                  {GetStatusesOfProject, NotImplemented, NotImplemented, NotImplemented},
+	"projects<id>versions":                  {GetVersionsOfProject, PutVersion, NotImplemented, NotImplemented},
+	"projects<id>versions<id>":              {GetVersion, NotImplemented, NotImplemented, DeleteVersion},
+	"projects<id>versions<id>promote":       {NotImplemented, PromoteVersion, NotImplemented, NotImplemented},
+	"projects<id>versions<id>compare<id>":   {CompareVersions, NotImplemented, NotImplemented, NotImplemented},
+
+	"component-types":                               {GetComponentTypes, PutComponentType, NotImplemented, NotImplemented},
+	"component-types<id>":                           {GetComponentType, NotImplemented, PostComponentType, DeleteComponentType},
+	"component-types<id>part-types":                 {GetPartTypesOfComponentType, PutPartType, NotImplemented, NotImplemented},
+	"component-types<id>part-types<id>":             {GetPartType, NotImplemented, PostPartType, DeletePartType},
+	"component-types<id>part-types<id>nomenclature": {GetNomenclatureForPartType, PutNomenclatureForPartType, PostNomenclatureForPartType, DeleteNomenclatureForPartType},
+
+	"nomenclature-types":                 {GetNomenclatureTypes, PutNomenclatureType, NotImplemented, NotImplemented},
+	"nomenclature-types<id>":             {GetNomenclatureType, NotImplemented, PostNomenclatureType, DeleteNomenclatureType},
+	"nomenclature-types<id>nomenclature": {GetNomenclatureOfNomenclatureType, PutNomenclature, NotImplemented, NotImplemented},
+
+	"nomenclature":              {GetNomenclatures, NotImplemented, NotImplemented, NotImplemented},
+	"nomenclature<id>":          {GetNomenclature, NotImplemented, PostNomenclature, DeleteNomenclature},
+	"nomenclature<id>price":     {GetPrice, PutPrice, PostPrice, DeletePrice},
+	"nomenclature<id>stock":     {GetStockOfNomenclature, PutStockMove, NotImplemented, NotImplemented},
+	"nomenclature<id>stock<id>": {NotImplemented, NotImplemented, NotImplemented, DeleteStockMove},
+
+	"stock": {GetStock, NotImplemented, NotImplemented, NotImplemented},
+
+	"color-schemes":     {GetColorSchemes, PutColorScheme, NotImplemented, NotImplemented},
+	"color-schemes<id>": {GetColorScheme, NotImplemented, PostColorSchemes, DeleteColorScheme},
+
+	"purchases": {GetPurchases, NotImplemented, NotImplemented, NotImplemented},
+
+	"nomenclature-import": {NotImplemented, GetImportNomenclature, NotImplemented, NotImplemented},
+}
@@ -6,9 +6,41 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
+// Версии API
+// v0 - параметры в строке запроса, команда может быть задана параметром ?method=, HTTP-статус ответа всегда 200
+// v1 - REST: команда по HTTP-методу, параметры в строке запроса или JSON-объектом в теле, HTTP-статус равен коду ответа:
+// GET - получение, POST - создание (201) или действие, PUT и PATCH - изменение, DELETE - удаление
+const (
+	APIVersion0 = "v0"
+	APIVersion1 = "v1"
+)
+
+// APIVersionInfo - описание версии API, список версий выдается по запросу GET /
+type APIVersionInfo struct {
+	Version    string `json:"version"`
+	REST       bool   `json:"rest"`
+	Deprecated bool   `json:"deprecated,omitempty"` // Ответы содержат заголовки Deprecation и Link на тот же запрос в версии Successor
+	Sunset     string `json:"sunset,omitempty"`     // Дата прекращения поддержки, ГГГГ-ММ-ДД, выдается в заголовке Sunset
+	Successor  string `json:"successor,omitempty"`
+}
+
+// Versions - поддерживаемые версии API, карты вызовов версий - в F
+var Versions []APIVersionInfo = []APIVersionInfo{
+	{Version: APIVersion0, Deprecated: true, Successor: APIVersion1},
+	{Version: APIVersion1, REST: true},
+}
+
+// V0Codes - коды ответа, которых не было в v0: в v0 выдаются прежние коды, код ошибки остается в Answer.Error
+var V0Codes map[APIErrorCode]APIErrorCode = map[APIErrorCode]APIErrorCode{
+	NotFound: BadRequest,
+	Conflict: BadRequest,
+}
+
 func InitAPIMux() *http.ServeMux {
 	APIMux := http.NewServeMux()
 	APIMux.HandleFunc("/", handler)
@@ -17,16 +49,56 @@ func InitAPIMux() *http.ServeMux {
 
 func handler(w http.ResponseWriter, r *http.Request) {
 	var answer Answer
+	var version APIVersionInfo
+	var preflight bool
 
 	// Show answer struct as a result of any request to API at the end, whatever the request or result is
 	defer func() {
 		// CORS for angular debugging
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Headers", "content-type")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "content-type, authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
 		w.Header().Set("Access-Control-Max-Age", "5")
+		w.Header().Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link")
+
+		if answer.Cookie != nil {
+			answer.Cookie.Secure = r.TLS != nil
+			http.SetCookie(w, answer.Cookie)
+		}
+		if answer.Code == Unauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="knx"`)
+		}
+
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Документ выводится как есть, ошибки - в JSON
+		if answer.Code == OK && answer.ContentType != "" {
+			w.Header().Set("Content-Type", answer.ContentType)
+			if answer.Filename != "" {
+				w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(answer.Filename))
+			}
+			_, err := w.Write(answer.Content)
+			if err != nil {
+				log.Printf("Ошибка при выводе документа: %v\n", err)
+			}
+			return
+		}
+
+		// Ошибки, заданные без make: неверный URL, NotImplemented и т.п.
+		if answer.Code >= BadRequest && answer.Error == nil {
+			answer.Error = newAPIError(answer.Code, answer.Message)
+		}
+
 		w.Header().Set("Content-Type", "application/json")
+		if version.REST {
+			w.WriteHeader(int(answer.Code))
+		} else if code, ok := V0Codes[answer.Code]; ok {
+			answer.Code = code
+		}
 
 		// TODO: Для реальной работы использовать компактный вывод: json.Marshal
 		//data, err := json.Marshal(answer)
@@ -55,10 +127,23 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: обрабатывать версию API. Временное решение.
-	if version := request[1]; version != "v0" && version != "V0" {
+	// GET / - список версий API
+	if request[1] == "" && len(request) == 2 {
+		answer.Code = OK
+		answer.Result = Versions
+		return
+	}
+
+	var supported []string
+	for _, v := range Versions {
+		if v.Version == strings.ToLower(request[1]) {
+			version = v
+		}
+		supported = append(supported, v.Version)
+	}
+	if version.Version == "" {
 		answer.Code = BadRequest
-		answer.Message = fmt.Sprintf("Версия API '%s' не поддерживается.\n", request[1])
+		answer.Message = fmt.Sprintf("Версия API '%s' не поддерживается. Поддерживаемые версии: %s.\n", request[1], strings.Join(supported, ", "))
 		return
 	}
 
@@ -67,7 +152,7 @@ func handler(w http.ResponseWriter, r *http.Request) {
 
 	if len(request) == 0 {
 		answer.Code = BadRequest
-		answer.Message = fmt.Sprintf("Запрос '%s' не предусмотрен. Обратитесь к документации по API KNX v0.", r.URL.EscapedPath())
+		answer.Message = fmt.Sprintf("Запрос '%s' не предусмотрен. Обратитесь к документации по API KNX %s.", r.URL.EscapedPath(), version.Version)
 		return
 	}
 
@@ -80,7 +165,7 @@ func handler(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		if i%2 == 0 {
-			key.WriteString(v)
+			key.WriteString(resourceName(v))
 		} else {
 			key.WriteString("<id>")
 			IDs = append(IDs, v)
@@ -92,25 +177,93 @@ func handler(w http.ResponseWriter, r *http.Request) {
 
 	// Получаем метод. Приводим к нижнему регистру.
 	method := strings.ToLower(r.Method)
+	// Команда задана параметром ?method=
+	var overridden bool
+
+	if version.REST {
+		if method == "options" {
+			preflight = true
+			return
+		}
 
-	//TODO: временное решение для тестирования API. В дальнейшем method будет определяться только через r.Method
-	if mparam, ok := params["method"]; ok {
+		// REST-команды вызывают функции команд v0: создание в v0 - put, изменение - post
+		switch method {
+		case "post":
+			method = "put"
+		case "put", "patch":
+			method = "post"
+		}
+	} else if mparam, ok := params["method"]; ok {
+		//TODO: временное решение для тестирования API. В дальнейшем method будет определяться только через r.Method
 		method = mparam[0]
+		delete(params, "method")
+		overridden = true
+	}
+
+	// Параметры из тела запроса: JSON-объект или форма
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") {
+		if err := parseJSON(r.Body, params); err != nil {
+			answer.Code = BadRequest
+			answer.Message = err.Error()
+			return
+		}
+	} else if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			answer.Code = BadRequest
+			answer.Message = fmt.Sprintf("Тело запроса не является формой (%v)", err)
+			return
+		}
+		for name, values := range r.PostForm {
+			params[name] = values
+		}
 	}
 
 	// По первому слову request определяем какая функция должна выполняться
-	f, ok := F[key.String()]
+	f, ok := F[version.Version][key.String()]
 	if !ok {
-		answer.Code = BadRequest
-		answer.Message = fmt.Sprintf("Запрос '%s' не предусмотрен. Обратитесь к документации по API KNX v0.", r.URL.EscapedPath())
+		answer.Code = NotFound
+		answer.Message = fmt.Sprintf("Запрос '%s' не предусмотрен. Обратитесь к документации по API KNX %s.", r.URL.EscapedPath(), version.Version)
+		return
+	}
+
+	if version.Deprecated {
+		deprecate(w.Header(), version, request)
+	}
+
+	// Пароли и логин входа принимаются только из тела запроса: строка запроса остается в журналах и истории браузера
+	if err := checkQueryCredentials(key.String(), r.URL.Query()); err != nil {
+		answer.make(&err, nil)
 		return
 	}
 
+	// Токен сессии передается обработчикам в параметре запроса SessionParam
+	// Cookie не принимается для команд из ?method= и изменяющих запросов GET: их может выполнить ссылка с другого сайта
+	delete(params, SessionParam)
+	token, fromCookie := sessionToken(r)
+	if fromCookie && (overridden || method == "get" && CookieUnsafeRoutes[key.String()]) {
+		token = ""
+	}
+	if token != "" {
+		params[SessionParam] = []string{token}
+	}
+
+	// Изменения выполняются только в открытой сессии пользователя, кроме входа
+	if method != "get" && key.String() != "session" {
+		if _, err := sessionUser(params); err != nil {
+			answer.make(&err, nil)
+			return
+		}
+	}
+
 	switch method {
 	case "get":
 		answer = f.Get(request, params)
 	case "put":
 		answer = f.Put(request, params)
+		if version.REST && answer.Code == OK {
+			answer.Code = Created
+		}
 	case "post":
 		answer = f.Post(request, params)
 	case "delete":
@@ -120,3 +273,40 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		answer.Message = fmt.Sprintf("Неизвестная команда: '%s %s'.\n", method, path)
 	}
 }
+
+// resourceName - имя ресурса из слова пути URL: имена не в ASCII приходят в URL закодированными
+func resourceName(v string) string {
+	if name, err := url.PathUnescape(v); err == nil {
+		return name
+	}
+	return v
+}
+
+// deprecate - заголовки ответа на запрос request к устаревшей версии API: Deprecation, Sunset
+// и Link на тот же запрос в версии-преемнике, если такой запрос в ней есть
+func deprecate(header http.Header, version APIVersionInfo, request []string) {
+	header.Set("Deprecation", "true")
+	if sunset, err := time.Parse(PriceDateLayout, version.Sunset); err == nil {
+		header.Set("Sunset", sunset.Format(http.TimeFormat))
+	}
+	if version.Successor == "" {
+		return
+	}
+
+	var key bytes.Buffer
+	path := []string{"", version.Successor}
+	for i, v := range request {
+		if i%2 == 0 {
+			if name, ok := Resources[version.Successor][resourceName(v)]; ok {
+				v = name
+			}
+			key.WriteString(v)
+		} else if v != "" {
+			key.WriteString("<id>")
+		}
+		path = append(path, v)
+	}
+	if _, ok := F[version.Successor][key.String()]; ok {
+		header.Set("Link", "<"+strings.Join(path, "/")+">; rel=\"successor-version\"")
+	}
+}
@@ -2,7 +2,11 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"knx/db"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -37,6 +41,7 @@ type RequestParam struct {
 	Optional bool
 	Type     RequestParamType
 	Value    RequestParamValue
+	Name     string // Имя параметра запроса, задается в Parse и сохраняется при копировании параметра под именем поля таблицы
 }
 
 type RequestParams map[string]RequestParam
@@ -171,6 +176,105 @@ func parseIntFloat(str string) (result map[int64]float64, err error) {
 	return
 }
 
+// parseJSON - добавляет в параметры запроса поля JSON-объекта из тела запроса, поля тела заменяют параметры URL
+// Поля - те же, что и параметры URL, значения переводятся в текстовый вид параметров:
+// число и строка - как есть, true/false - 1/0, объект {"id": <id>} - ссылка на элемент по ID,
+// массив - несколько значений параметра, как повторенный параметр URL,
+// объект {"<key>": <value>, ...} - значения <key>(<value>) (значение может быть объектом {"value": <value>})
+func parseJSON(body io.Reader, params map[string][]string) error {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("Тело запроса не является JSON-объектом (%v)", err)
+	}
+
+	for name, v := range fields {
+		if v == nil {
+			continue
+		}
+		values, err := jsonParamValues(v)
+		if err != nil {
+			return fmt.Errorf("Значение поля '%s' не удается распознать (%v)", name, err)
+		}
+		params[name] = values
+	}
+	return nil
+}
+
+// jsonParamValues - текстовые значения параметра запроса из значения поля JSON:
+// массив и объект без id дают по значению на каждый элемент
+func jsonParamValues(v interface{}) (values []string, err error) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			value, err := jsonParamValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return
+	case map[string]interface{}:
+		if _, ok := v["id"]; ok {
+			break
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			item := v[key]
+			if object, ok := item.(map[string]interface{}); ok {
+				item = object["value"]
+			}
+			value, err := jsonParamValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, key+"("+value+")")
+		}
+		return
+	}
+
+	value, err := jsonParamValue(v)
+	if err != nil {
+		return
+	}
+	return []string{value}, nil
+}
+
+// jsonParamValue - текстовое значение параметра запроса из одного значения JSON: число, строка, true/false или ссылка {"id": <id>}
+// Ссылка задается только полем id: остальные поля объекта не могут быть переданы параметром
+func jsonParamValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case map[string]interface{}:
+		id, ok := v["id"]
+		if !ok || len(v) > 1 {
+			return "", fmt.Errorf("ссылка на элемент задается объектом только с полем id")
+		}
+		if _, ok := id.(json.Number); !ok {
+			return "", fmt.Errorf("неверный id ссылки %v", id)
+		}
+		return jsonParamValue(id)
+	}
+	return "", fmt.Errorf("неподдерживаемое значение %v", v)
+}
+
 // Value - return value of RequestParamValue dependent on its type
 func (v *RequestParamValue) Value() interface{} {
 	switch v.Type {
@@ -195,6 +299,55 @@ func (v *RequestParamValue) Value() interface{} {
 	}
 }
 
+// ParseValues - parse all the values of the request param: values of arrays and maps are merged,
+// a scalar takes the first value
+func (rp *RequestParamValue) ParseValues(values []string) (err error) {
+	switch rp.Type {
+	case IntArray, StringArray, IntStringMap, FloatStringMap, IntFloatMap:
+	default:
+		if len(values) == 0 {
+			return rp.Parse("")
+		}
+		return rp.Parse(values[0])
+	}
+
+	var merged RequestParamValue
+	for _, s := range values {
+		err = rp.Parse(s)
+		if err != nil {
+			return
+		}
+		merged.merge(rp)
+	}
+	merged.Type = rp.Type
+	*rp = merged
+	return
+}
+
+// merge - add the values of the array or map v
+func (rp *RequestParamValue) merge(v *RequestParamValue) {
+	rp.IntArray = append(rp.IntArray, v.IntArray...)
+	rp.StringArray = append(rp.StringArray, v.StringArray...)
+	if len(v.IntStringMap) > 0 && rp.IntStringMap == nil {
+		rp.IntStringMap = make(map[int64]string)
+	}
+	for k, s := range v.IntStringMap {
+		rp.IntStringMap[k] = s
+	}
+	if len(v.FloatStringMap) > 0 && rp.FloatStringMap == nil {
+		rp.FloatStringMap = make(map[float64]string)
+	}
+	for k, s := range v.FloatStringMap {
+		rp.FloatStringMap[k] = s
+	}
+	if len(v.IntFloatMap) > 0 && rp.IntFloatMap == nil {
+		rp.IntFloatMap = make(map[int64]float64)
+	}
+	for k, f := range v.IntFloatMap {
+		rp.IntFloatMap[k] = f
+	}
+}
+
 // Parse - parse string value into the RequestParamValue
 func (rp *RequestParamValue) Parse(s string) (err error) {
 	switch rp.Type {
@@ -221,11 +374,23 @@ func (rp *RequestParamValue) Parse(s string) (err error) {
 }
 
 // Parse - convert standard HTTP-Rerquest params into convinient RequestParams and return an error
+// All the problems are collected in one pass: missing, invalid and unknown params are listed in the fields of APIError
 func (rps *RequestParams) Parse(params map[string][]string) error {
-	for name, rp := range *rps {
+	var fields []APIFieldError
+
+	names := make([]string, 0, len(*rps))
+	for name := range *rps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rp := (*rps)[name]
+		rp.Name = name
 		p, ok := params[name]
 		if !ok && !rp.Optional {
-			return fmt.Errorf("Не задан обязательный параметр запроса '%s'", name)
+			fields = append(fields, APIFieldError{Field: name, Code: FieldMissing,
+				Message: fmt.Sprintf("Не задан обязательный параметр запроса '%s'", name)})
 		}
 
 		if !ok {
@@ -235,16 +400,68 @@ func (rps *RequestParams) Parse(params map[string][]string) error {
 		}
 
 		rp.Value.Type = rp.Type
-		err := rp.Value.Parse(p[0])
+		err := rp.Value.ParseValues(p)
 		(*rps)[name] = rp
 		if err != nil {
-			err = fmt.Errorf("Значение параметра '%s' не удается распознать (%v)", name, err)
-			return err
+			fields = append(fields, APIFieldError{Field: name, Code: FieldInvalid,
+				Message: fmt.Sprintf("Значение параметра '%s' не удается распознать (%v)", name, err)})
 		}
 	}
+
+	var unknown []string
+	for name := range params {
+		if _, ok := (*rps)[name]; !ok && name != SessionParam {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		fields = append(fields, APIFieldError{Field: name, Code: FieldUnknown,
+			Message: fmt.Sprintf("Параметр '%s' не предусмотрен запросом", name)})
+	}
+
+	if len(fields) > 0 {
+		return validationError(fields)
+	}
 	return nil
 }
 
+// ReferenceError - translate the violation of the foreign key in SQL-INSERT or SQL-UPDATE of the table into APIError
+// with the params referring to missing elements. Params set by the handler from the URL (without Name) mean
+// the missing element of the request itself: NotFound. Other errors are returned as is
+func (rps RequestParams) ReferenceError(tableName string, err error) error {
+	if !db.IsForeignKey(err) {
+		return err
+	}
+
+	values := make(map[string]interface{})
+	for column, rp := range rps {
+		if rp.Exists() {
+			values[column] = rp.GetValue()
+		}
+	}
+	columns, e := db.MissingReferences(tableName, values)
+	if e != nil || len(columns) == 0 {
+		return err
+	}
+
+	var fields []APIFieldError
+	code := APIErrorCode(BadRequest)
+	for _, column := range columns {
+		name := rps[column].Name
+		if name == "" {
+			name = column
+			code = NotFound
+		}
+		fields = append(fields, APIFieldError{Field: name, Code: FieldReference,
+			Message: fmt.Sprintf("Элемент '%v', заданный параметром '%s', не найден", values[column], name)})
+	}
+	apiErr := validationError(fields).(*APIError)
+	apiErr.Code = code
+	apiErr.ErrorCode = ErrReference
+	return apiErr
+}
+
 // MakeSQLInsert - construct SQL-INSERT request and return text of SQL and list of values as parmeters for the SQL
 func (rps RequestParams) MakeSQLInsert(tableName string, fields []string) (sqlText string, sqlParams []interface{}) {
 	var fieldDesc bytes.Buffer
@@ -275,6 +492,12 @@ func (rps RequestParams) MakeSQLInsert(tableName string, fields []string) (sqlTe
 
 // MakeSQLUpdate - construct SQL-UPDATE request and return text of SQL and list of values as parmeters for the SQL
 func (rps RequestParams) MakeSQLUpdate(tableName string, fields []string, id int64) (sqlText string, sqlParams []interface{}) {
+	return rps.MakeSQLUpdateWhere(tableName, fields, "id=?", id)
+}
+
+// MakeSQLUpdateWhere - construct SQL-UPDATE request of the rows selected by the condition where with the values args,
+// for the tables without id. Empty text of SQL if no field is set by user
+func (rps RequestParams) MakeSQLUpdateWhere(tableName string, fields []string, where string, args ...interface{}) (sqlText string, sqlParams []interface{}) {
 	var fieldDesc bytes.Buffer
 
 	for _, f := range fields {
@@ -293,8 +516,141 @@ func (rps RequestParams) MakeSQLUpdate(tableName string, fields []string, id int
 
 	// Remove last comma
 	fieldDesc.Truncate(fieldDesc.Len() - 1)
-	sqlParams = append(sqlParams, id)
-	sqlText = fmt.Sprintf("UPDATE %s SET %s WHERE id=?", tableName, fieldDesc.String())
+	sqlParams = append(sqlParams, args...)
+	sqlText = fmt.Sprintf("UPDATE %s SET %s WHERE %s", tableName, fieldDesc.String(), where)
+	return
+}
+
+// ListQuery - SQL-SELECT request of the list for MakeSQLSelect
+// Filters - condition for each param of the request, the param is applied if it is set by user:
+// one ? for the value of the param, (?) for the values of IntArray and StringArray params
+// Substrings - SQL-expression for each param of the substring search: the param is applied if it is set by user,
+// the expression contains the value of the param, % and _ in the value are searched as themselves
+// Sort - SQL-expressions for the names of the columns in the param sort
+type ListQuery struct {
+	Select     string // SELECT <fields>
+	From       string // FROM <tables> [JOIN ...], without WHERE
+	Where      string // Condition of the handler, applied always
+	Args       []interface{}
+	Filters    map[string]string
+	Substrings map[string]string
+	Sort       map[string]string
+	Order      string // Default sorting, also applied after the sorting set by user for stable pages
+}
+
+// likeEscaper - escaping of the LIKE pattern with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// WithListParams - add params of the list to the request params:
+// limit, offset - page of the list; sort - names of the columns, '-' before the name for the descending order
+func (rps RequestParams) WithListParams() RequestParams {
+	rps["limit"] = RequestParam{Optional: true, Type: Int}
+	rps["offset"] = RequestParam{Optional: true, Type: Int}
+	rps["sort"] = RequestParam{Optional: true, Type: StringArray}
+	return rps
+}
+
+// MakeSQLSelect - construct SQL-SELECT request of the list with filters, sorting and page set by the params,
+// SQL-request of the total count of the list and list of values as parmeters for both the SQLs
+func (rps RequestParams) MakeSQLSelect(q ListQuery) (sqlText, countText string, sqlParams []interface{}, err error) {
+	var fields []APIFieldError
+
+	var where []string
+	if q.Where != "" {
+		where = append(where, q.Where)
+	}
+	sqlParams = append(sqlParams, q.Args...)
+
+	names := make([]string, 0, len(q.Filters)+len(q.Substrings))
+	for name := range q.Filters {
+		names = append(names, name)
+	}
+	for name := range q.Substrings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rp, ok := rps[name]
+		if !ok || !rp.Exists() {
+			continue
+		}
+		if expression, ok := q.Substrings[name]; ok {
+			where = append(where, expression+` LIKE ? ESCAPE '\'`)
+			sqlParams = append(sqlParams, "%"+likeEscaper.Replace(rp.Value.StringValue)+"%")
+			continue
+		}
+		condition := q.Filters[name]
+		switch rp.Type {
+		case IntArray, StringArray:
+			var values []interface{}
+			for _, v := range rp.Value.IntArray {
+				values = append(values, v)
+			}
+			for _, v := range rp.Value.StringArray {
+				values = append(values, v)
+			}
+			if len(values) == 0 {
+				continue
+			}
+			condition = strings.Replace(condition, "(?)", "(?"+strings.Repeat(",?", len(values)-1)+")", 1)
+			sqlParams = append(sqlParams, values...)
+		default:
+			sqlParams = append(sqlParams, rp.GetValue())
+		}
+		where = append(where, condition)
+	}
+
+	var order []string
+	for _, column := range rps["sort"].Value.StringArray {
+		direction := ""
+		if strings.HasPrefix(column, "-") {
+			column, direction = column[1:], " DESC"
+		}
+		expression, ok := q.Sort[column]
+		if !ok {
+			columns := make([]string, 0, len(q.Sort))
+			for c := range q.Sort {
+				columns = append(columns, c)
+			}
+			sort.Strings(columns)
+			fields = append(fields, APIFieldError{Field: "sort", Code: FieldInvalid,
+				Message: fmt.Sprintf("Сортировка по '%s' не предусмотрена, ожидается: %s", column, strings.Join(columns, ", "))})
+			continue
+		}
+		order = append(order, expression+direction)
+	}
+	if q.Order != "" {
+		order = append(order, q.Order)
+	}
+
+	limit, offset := rps["limit"].Value.IntValue, rps["offset"].Value.IntValue
+	if limit < 0 {
+		fields = append(fields, APIFieldError{Field: "limit", Code: FieldInvalid, Message: "Размер страницы не может быть отрицательным"})
+	}
+	if offset < 0 {
+		fields = append(fields, APIFieldError{Field: "offset", Code: FieldInvalid, Message: "Смещение страницы не может быть отрицательным"})
+	}
+	if len(fields) > 0 {
+		err = validationError(fields)
+		return
+	}
+
+	var sqlWhere bytes.Buffer
+	if len(where) > 0 {
+		fmt.Fprintf(&sqlWhere, " WHERE %s", strings.Join(where, " AND "))
+	}
+	countText = fmt.Sprintf("SELECT count(*) %s%s", q.From, sqlWhere.String())
+
+	sqlText = fmt.Sprintf("%s %s%s", q.Select, q.From, sqlWhere.String())
+	if len(order) > 0 {
+		sqlText += " ORDER BY " + strings.Join(order, ", ")
+	}
+	if rps["limit"].Exists() {
+		sqlText += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	} else if offset > 0 {
+		sqlText += fmt.Sprintf(" LIMIT -1 OFFSET %d", offset)
+	}
 	return
 }
 
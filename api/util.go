@@ -12,8 +12,8 @@ import (
 	"strings"
 )
 
-// putRequest выполняет "PUT" запрос.
-func putRequest(request string, data io.Reader) (id int64, err error) {
+// putRequest выполняет "PUT" запрос в сессии пользователя с токеном token.
+func putRequest(request string, data io.Reader, token string) (id int64, err error) {
 	client := &http.Client{}
 
 	// Создаем запрос
@@ -22,6 +22,7 @@ func putRequest(request string, data io.Reader) (id int64, err error) {
 		err = error
 		return
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
 
 	// Выполняем запрос
 	resp, error := client.Do(req)
@@ -51,7 +52,7 @@ func putRequest(request string, data io.Reader) (id int64, err error) {
 // ImportNomenclature импортирует данные номенклатуры из указанной директории.
 // Обрабатываются только csv-файлы. Заполняются таблицы nomenclature и tnomenclature.
 
-func ImportNomenclature(filePath string) (err error) {
+func ImportNomenclature(filePath string, token string) (err error) {
 	// Открываем файл
 	var file *os.File
 	file, err = os.Open(filePath)
@@ -107,7 +108,7 @@ func ImportNomenclature(filePath string) (err error) {
 			// Формируем запрос для вставки типа номенклатуры. Задаем параметр "use_fields"
 			request := fmt.Sprintf("http://localhost:8080/v0/nomenclature_types?name=%s&use_fields=%s", url.QueryEscape(nomenclatureType), strings.Join(fields, ","))
 
-			nomenclatureID, err = putRequest(request, nil)
+			nomenclatureID, err = putRequest(request, nil, token)
 			if err != nil {
 				return
 			}
@@ -144,7 +145,7 @@ func ImportNomenclature(filePath string) (err error) {
 
 			request := fmt.Sprintf("http://localhost:8080/v0/nomenclature_types/%d/nomenclature?%s", nomenclatureID, values.Encode())
 
-			_, err = putRequest(request, nil)
+			_, err = putRequest(request, nil, token)
 			if err != nil {
 				return
 			}
@@ -165,6 +166,12 @@ func GetImportNomenclature(request []string, params map[string][]string) (answer
 	var report string
 	defer answer.make(&err, &report)
 
+	// Номенклатура добавляется запросами API в сессии пользователя
+	_, err = sessionUser(params)
+	if err != nil {
+		return
+	}
+
 	if params == nil || len(params) == 0 {
 		answer.Code = BadRequest
 		err = fmt.Errorf("Не задан параметр 'path' для запроса '%s'", request[0])
@@ -220,7 +227,7 @@ func GetImportNomenclature(request []string, params map[string][]string) (answer
 		// А этот не работает (т.к. путь должен заканчиваться символом '\'): http://api.localhost:8080/v0/import_nomenclature?path=D:\Dev\Projects\Knx\nomenclature
 		// TODO: в unix системах и windows используется прямой и обратный слэши соответсвенно. Предусмотреть в дальнейшем, чтобы работали оба варианта и на разных ОС.
 		fileName := path + file.Name()
-		err = ImportNomenclature(fileName)
+		err = ImportNomenclature(fileName, params[SessionParam][0])
 		if err == nil {
 			processedFiles = append(processedFiles, file.Name())
 		} else {
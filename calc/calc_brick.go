@@ -0,0 +1,136 @@
+package calc
+
+import "math"
+
+// BrickPillar - кладка кирпичного столба вокруг металлического столба
+type BrickPillar struct {
+	Bricks float64 // Кирпичей в одном ряду кладки, шт
+	Core   float64 // Сторона пустоты под металлический столб, в мм
+}
+
+// Кладка столбов по значениям параметра PTBrickPillarSize, ширина столба - Size значения параметра
+var BrickPillars = map[float64]BrickPillar{
+	BrickPillar15: {Bricks: 4.5, Core: 140},
+	BrickPillar2:  {Bricks: 6, Core: 270},
+}
+
+// Кирпич 250x120x65 мм: высота ряда с растворным швом 10 мм, в мм
+const BrickRowHeight = 75
+
+// Расход раствора на 1 м3 кладки, в м3
+const BrickMortarRate = 0.24
+
+// BrickPillarRows - количество рядов кладки столба
+// height - высота столба над землей, в м
+func BrickPillarRows(height float64) float64 {
+	if height <= lengthEpsilon {
+		return 0
+	}
+	return math.Ceil(height*1000/BrickRowHeight - lengthEpsilon)
+}
+
+// BrickPillarVolume - объем кладки одного столба за вычетом пустоты под металлический столб, в м3
+// height - высота столба над землей, в м
+// size - сечение столба, значение параметра PTBrickPillarSize
+func BrickPillarVolume(height, size float64) float64 {
+	width := ParamValueSize(PTBrickPillarSize, size) / 1000
+	core := BrickPillars[size].Core / 1000
+	volume := (width*width - core*core) * BrickPillarRows(height) * BrickRowHeight / 1000
+	if volume < 0 {
+		return 0
+	}
+	return volume
+}
+
+// Входящие значения расчетов кирпичных столбов
+// Кирпичный столб ставится вокруг каждого столба участка и выступает над забором на выступ столбов
+var brickInputs = []CalculationInput{
+	{Name: "columns", Unit: "шт", Kind: IKResult, Calculation: MCColumns, Output: "count"},
+	{Name: "height", Unit: "м", Param: PTTotalHeight},
+	{Name: "up_space", Unit: "мм", Param: PTUpSpace},
+	{Name: "size", Param: PTBrickPillarSize},
+}
+
+func init() {
+	RegisterCalculation(MCBrickPillars, MaterialCalculation{
+		Name:   "Кирпичные столбы: кирпич",
+		Func:   MCBrickPillarsFunc,
+		Inputs: brickInputs,
+		Outputs: []CalculationOutput{
+			{Name: "bricks", Unit: "шт", Result: RSNomenclature},
+			{Name: "volume", Unit: "м3", Result: RSMasonryVolume},
+		},
+	})
+
+	RegisterCalculation(MCBrickMortar, MaterialCalculation{
+		Name: "Кирпичные столбы: раствор",
+		Func: MCBrickMortarFunc,
+		Inputs: []CalculationInput{
+			{Name: "volume", Unit: "м3", Kind: IKResult, Calculation: MCBrickPillars, Output: "volume"},
+		},
+		Outputs: []CalculationOutput{{Name: "mortar", Unit: "м3", Result: RSNomenclature}},
+	})
+
+	RegisterCalculation(MCBrickCaps, MaterialCalculation{
+		Name: "Кирпичные столбы: колпаки",
+		Func: MCBrickCapsFunc,
+		Inputs: []CalculationInput{
+			{Name: "columns", Unit: "шт", Kind: IKResult, Calculation: MCColumns, Output: "count"},
+			{Name: "caps", Param: PTBoolBrickCaps},
+		},
+		Outputs: []CalculationOutput{{Name: "count", Unit: "шт", Result: RSNomenclature}},
+	})
+
+	RegisterCalculation(MCBrickLaying, MaterialCalculation{
+		Name: "Кирпичные столбы: кладка",
+		Func: MCBrickLayingFunc,
+		Inputs: []CalculationInput{
+			{Name: "volume", Unit: "м3", Kind: IKResult, Calculation: MCBrickPillars, Output: "volume"},
+			{Name: "install", Param: PTBoolInstallBrick},
+		},
+		Outputs: []CalculationOutput{{Name: "volume", Unit: "м3", Result: RSNomenclature}},
+	})
+}
+
+// MCBrickPillarsFunc - расчет кирпича на столбы участка
+// Высота столба над землей - высота забора и выступ столбов сверху, кладка - целое число рядов
+//
+// Результаты:
+// bricks - количество кирпича, шт
+// volume - объем кладки всех столбов, в м3
+func MCBrickPillarsFunc(args CalculationArgs) (res []float64) {
+	columns := args.Float("columns")
+	height := args.Float("height") + args.Float("up_space")/1000
+	size := args.Float("size")
+
+	bricks := math.Ceil(columns*BrickPillarRows(height)*BrickPillars[size].Bricks - lengthEpsilon)
+	res = []float64{bricks, columns * BrickPillarVolume(height, size)}
+	return
+}
+
+// MCBrickMortarFunc - расчет раствора на кладку столбов
+//
+// Результаты:
+// mortar - объем раствора, в м3
+func MCBrickMortarFunc(args CalculationArgs) (res []float64) {
+	res = []float64{args.Float("volume") * BrickMortarRate}
+	return
+}
+
+// MCBrickCapsFunc - расчет колпаков на кирпичные столбы: по колпаку на столб, если колпаки выбраны
+//
+// Результаты:
+// count - количество колпаков
+func MCBrickCapsFunc(args CalculationArgs) (res []float64) {
+	res = []float64{args.Float("columns") * args.Float("caps")}
+	return
+}
+
+// MCBrickLayingFunc - услуга кладки столбов по объему кладки, если выбран монтаж
+//
+// Результаты:
+// volume - объем кладки, в м3
+func MCBrickLayingFunc(args CalculationArgs) (res []float64) {
+	res = []float64{args.Float("volume") * args.Float("install")}
+	return
+}
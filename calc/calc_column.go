@@ -0,0 +1,143 @@
+package calc
+
+import "math"
+
+// Точность сравнения длин, в м
+const lengthEpsilon = 1e-6
+
+// ColumnSpans - разбиение участка на пролеты между столбами
+// totalLength - длина участка, в м
+// stepLength - шаг столбов, в м
+// stepType - разбиение: ColumnStepSpecified или ColumnStepEquable
+// stepSpace - размещение остатка при заданном шаге: ColumnStepSpaceEnd или ColumnStepSpaceStart
+//
+// Возвращает длины пролетов, в м, по порядку от начала участка
+func ColumnSpans(totalLength, stepLength, stepType, stepSpace float64) (spans []float64) {
+	if totalLength <= lengthEpsilon {
+		return
+	}
+
+	// Участок короче шага - один пролет на всю длину
+	if stepLength <= lengthEpsilon || totalLength <= stepLength+lengthEpsilon {
+		return []float64{totalLength}
+	}
+
+	// Разбить на ровные участки, не длиннее заданного шага
+	if stepType == ColumnStepEquable {
+		count := int(math.Ceil(totalLength/stepLength - lengthEpsilon))
+		for i := 0; i < count; i++ {
+			spans = append(spans, totalLength/float64(count))
+		}
+		return
+	}
+
+	// Заданный шаг, остаток в начале или в конце участка
+	count := int(math.Floor(totalLength/stepLength + lengthEpsilon))
+	rest := totalLength - float64(count)*stepLength
+
+	if rest > lengthEpsilon && stepSpace == ColumnStepSpaceStart {
+		spans = append(spans, rest)
+	}
+	for i := 0; i < count; i++ {
+		spans = append(spans, stepLength)
+	}
+	if rest > lengthEpsilon && stepSpace != ColumnStepSpaceStart {
+		spans = append(spans, rest)
+	}
+	return
+}
+
+// ColumnLength - длина одного столба, в м
+// totalHeight - высота забора, в м
+// depth - заглубление столба, в мм
+// upSpace - выступ столба над забором, в мм
+func ColumnLength(totalHeight, depth, upSpace float64) float64 {
+	return totalHeight + (depth+upSpace)/1000
+}
+
+// ColumnPieces - длины всех столбов участка по порядку, в м
+// length - длина столба на ровной земле, в м
+// При ступенчатом полотне столб между пролетами удлиняется на больший из перепадов соседних пролетов
+func ColumnPieces(spans []Span, length, slopeType float64) (pieces []float64) {
+	if len(spans) == 0 {
+		return
+	}
+	for i := 0; i <= len(spans); i++ {
+		piece := length
+		if slopeType != SlopeRaked {
+			var step float64
+			if i > 0 {
+				step = math.Abs(spans[i-1].Rise)
+			}
+			if i < len(spans) {
+				step = math.Max(step, math.Abs(spans[i].Rise))
+			}
+			piece += step
+		}
+		pieces = append(pieces, piece)
+	}
+	return
+}
+
+func init() {
+	RegisterCalculation(MCColumns, MaterialCalculation{
+		Name: "Расстановка столбов",
+		Func: MCColumnsFunc,
+		Inputs: []CalculationInput{
+			{Name: "region_type", Kind: IKRegionType},
+			{Name: "segments", Kind: IKSegments},
+			{Name: "step_length", Unit: "м", Param: PTColumnStepLength},
+			{Name: "step_type", Param: PTColumnStepType},
+			{Name: "step_space", Param: PTColumnStepSpace},
+			{Name: "slope_type", Param: PTSlopeType},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "depth", Unit: "мм", Param: PTColumnDepth},
+			{Name: "up_space", Unit: "мм", Param: PTUpSpace},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "count", Unit: "шт", Result: RSColumnCount},
+			{Name: "column_length", Unit: "м", Result: RSColumnLength},
+			{Name: "metal", Unit: "м", Result: RSNomenclature},
+			{Name: "cuts", Unit: "шт", Result: RSDivisionService},
+			{Name: "waste", Unit: "м", Result: RSWaste},
+			{Name: "corners", Unit: "шт", Result: RSCornerColumnCount},
+			{Name: "spans", Unit: "м", Result: RSColumnSpan, List: true},
+		},
+	})
+}
+
+// MCColumnsFunc - расстановка столбов на участке и расчет металла на столбы
+// Столбы ставятся на каждой вершине ломаной участка, на вершинах с поворотом - угловые столбы
+// Проем ворот или калитки - один пролет между двумя столбами независимо от шага
+// Номенклатура столбов: Division - складские длины, в м
+//
+// Результаты:
+// count - количество столбов
+// column_length - длина одного столба на ровной земле, в м
+// metal - металл на столбы, в м: длина заготовок с учетом раскроя или общая длина столбов
+// cuts - количество резов
+// waste - длина обрезков, в м
+// corners - количество угловых столбов
+// spans - длины полотна пролетов, в м: по уклону для полотна по уклону, по горизонтали для ступенчатого
+func MCColumnsFunc(args CalculationArgs) (res []float64) {
+	spans := RegionSpans(args.RegionType, args.Segments, args.Float("step_length"), args.Float("step_type"), args.Float("step_space"))
+
+	length := ColumnLength(args.Float("height"), args.Float("depth"), args.Float("up_space"))
+	pieces := ColumnPieces(spans, length, args.Float("slope_type"))
+
+	var corners int
+	for i := range args.Segments {
+		if IsCorner(args.Segments, i) {
+			corners++
+		}
+	}
+
+	res = []float64{float64(len(pieces)), length}
+	res = append(res, args.cuttingResults(pieces, args.Nomenclature)...)
+	res = append(res, float64(corners))
+	for _, span := range spans {
+		res = append(res, span.CanvasLength(args.Float("slope_type")))
+	}
+	return
+}
@@ -1,5 +1,7 @@
 package calc
 
+import "fmt"
+
 type ComponentTypeID int64
 
 const (
@@ -7,6 +9,12 @@ const (
 	CMColumns
 	CMHStick
 	CMFilling
+	CMMeshPanels
+	CMSwingGate
+	CMSlidingGate
+	CMWicket
+	CMBrickPillars
+	CMWeldedFilling
 )
 
 type Component struct {
@@ -20,23 +28,24 @@ var Components = [...]Component{
 	// Common parameters
 	CMRegion: {
 		Name:   "Параметры участка",
-		Params: []ParamTypeID{PTTotalLength, PTTotalHeight, PTBottomSpace, PTUpSpace},
+		Params: []ParamTypeID{PTTotalLength, PTTotalHeight, PTBottomSpace, PTUpSpace, PTSlopeType},
 	},
 
 	// Columns
 	CMColumns: {
 		Name: "Столбы",
 		Parts: []Part{
-			{Name: "Материал"},
+			{Name: "Материал", MC: MCColumns},
 			{Name: "Кронштейны"},
 			{Name: "Заглушки"},
 			{Name: "Монтаж"},
 			{Name: "Покраска(услуга)"},
-			{Name: "Покраска(материал)"},
-			{Name: "Цемент"},
-			{Name: "Щебень"},
-			{Name: "Песок"},
-			{Name: "HILST"},
+			{Name: "Покраска(материал)", MC: MCColumnPaint},
+			{Name: "Цемент", MC: MCFoundationCement},
+			{Name: "Щебень", MC: MCFoundationGravel},
+			{Name: "Песок", MC: MCFoundationSand},
+			{Name: "HILST", MC: MCFoundationHILST},
+			{Name: "Фланцы", MC: MCFoundationFlanges},
 		},
 		Params: []ParamTypeID{
 			PTColumnDepth,
@@ -44,12 +53,14 @@ var Components = [...]Component{
 			PTColumnStepType,
 			PTColumnStepSpace,
 			PTColumnInstallMethod,
+			PTColumnHoleDiameter,
 			PTColumnSize,
 			PTBoolInstallColumns,
 			PTBoolColumnPaint,
 			PTBoolColumnCover,
 			PTColorColumnPaint,
 			PTBoolColumnBrackets,
+			PTPaintConsumption,
 		},
 	},
 
@@ -57,10 +68,10 @@ var Components = [...]Component{
 	CMHStick: {
 		Name: "Прожилины",
 		Parts: []Part{
-			{Name: "Материал"},
-			{Name: "Монтаж"},
+			{Name: "Материал", MC: MCHStick},
+			{Name: "Монтаж", MC: MCHStickMount},
 			{Name: "Окрашивание(услуга)"},
-			{Name: "Окрашивание(материал)"},
+			{Name: "Окрашивание(материал)", MC: MCHStickPaint},
 		},
 		Params: []ParamTypeID{
 			PTHStickCount,
@@ -71,6 +82,7 @@ var Components = [...]Component{
 			PTBoolInstallHStick,
 			PTColorHStick,
 			PTBoolHStickPaint,
+			PTPaintConsumption,
 		},
 	},
 
@@ -78,11 +90,15 @@ var Components = [...]Component{
 	CMFilling: {
 		Name: "Наполнение",
 		Parts: []Part{
-			{Name: "Материал"},
-			{Name: "Крепеж"},
+			{
+				Name:   "Материал",
+				MC:     MCProfileSheet,
+				Params: []ParamTypeID{PTProfileSheetType, PTProfileSheetThickness, PTColorCanvas},
+			},
+			{Name: "Крепеж", MC: MCFix, Params: []ParamTypeID{PTColorFix}},
 			{Name: "Монтаж"},
 			{Name: "Окрашивание(услуга)"},
-			{Name: "Окрашивание(материал)"},
+			{Name: "Окрашивание(материал)", MC: MCCanvasPaint},
 		},
 		Params: []ParamTypeID{
 			PTProfileSheetThickness,
@@ -93,6 +109,116 @@ var Components = [...]Component{
 			PTColorCanvasPaint,
 			PTColorFix,
 			PTBoolFix,
+			PTFixStep,
+			PTPaintConsumption,
+		},
+	},
+
+	// Mesh panels: 2D, 3D
+	CMMeshPanels: {
+		Name: "Панели сетки",
+		Parts: []Part{
+			{Name: "Материал", MC: MCMeshPanels, Params: []ParamTypeID{PTMeshPanelHeight, PTColorMesh}},
+			{Name: "Хомуты", MC: MCMeshClamps, Params: []ParamTypeID{PTColorMesh}},
+			{Name: "Монтаж", Formula: fmt.Sprintf("param(%d) * param(%d)", PTBoolInstallMesh, PTTotalLength)},
+		},
+		Params: []ParamTypeID{
+			PTMeshPanelWidth,
+			PTMeshPanelHeight,
+			PTMeshClamps,
+			PTColorMesh,
+			PTBoolInstallMesh,
+		},
+	},
+
+	// Swing gate
+	CMSwingGate: {
+		Name: "Распашные ворота",
+		Parts: []Part{
+			{Name: "Каркас", MC: MCGateFrame, Params: []ParamTypeID{PTGateFrameSize}},
+			{Name: "Петли", Formula: fmt.Sprintf("param(%d) * param(%d)", PTGateLeafCount, PTGateHinges)},
+			{Name: "Замок", Formula: fmt.Sprintf("param(%d)", PTBoolGateLock)},
+			{Name: "Автоматика", Formula: fmt.Sprintf("param(%d)", PTBoolGateAutomation), Params: []ParamTypeID{PTGateLeafCount}},
+			{Name: "Монтаж", Formula: fmt.Sprintf("param(%d)", PTBoolInstallGate)},
+		},
+		Params: []ParamTypeID{
+			PTGateLeafCount,
+			PTGateHinges,
+			PTHStickCount,
+			PTGateFrameSize,
+			PTBoolGateLock,
+			PTBoolGateAutomation,
+			PTBoolInstallGate,
+		},
+	},
+
+	// Sliding gate
+	CMSlidingGate: {
+		Name: "Откатные ворота",
+		Parts: []Part{
+			{Name: "Каркас", MC: MCGateFrame, Params: []ParamTypeID{PTGateFrameSize}},
+			{Name: "Направляющая", Formula: fmt.Sprintf("param(%d) * (1 + param(%d) / 100)", PTTotalLength, PTGateCounterweight)},
+			{Name: "Ролики", Formula: "1"},
+			{Name: "Замок", Formula: fmt.Sprintf("param(%d)", PTBoolGateLock)},
+			{Name: "Автоматика", Formula: fmt.Sprintf("param(%d)", PTBoolGateAutomation)},
+			{Name: "Монтаж", Formula: fmt.Sprintf("param(%d)", PTBoolInstallGate)},
+		},
+		Params: []ParamTypeID{
+			PTGateCounterweight,
+			PTHStickCount,
+			PTGateFrameSize,
+			PTBoolGateLock,
+			PTBoolGateAutomation,
+			PTBoolInstallGate,
+		},
+	},
+
+	// Wicket
+	CMWicket: {
+		Name: "Калитка",
+		Parts: []Part{
+			{Name: "Каркас", MC: MCGateFrame, Params: []ParamTypeID{PTGateFrameSize}},
+			{Name: "Петли", Formula: fmt.Sprintf("param(%d)", PTGateHinges)},
+			{Name: "Замок", Formula: fmt.Sprintf("param(%d)", PTBoolGateLock)},
+			{Name: "Монтаж", Formula: fmt.Sprintf("param(%d)", PTBoolInstallGate)},
+		},
+		Params: []ParamTypeID{
+			PTGateHinges,
+			PTHStickCount,
+			PTGateFrameSize,
+			PTBoolGateLock,
+			PTBoolInstallGate,
+		},
+	},
+
+	// Brick pillars around the columns
+	CMBrickPillars: {
+		Name: "Кирпичные столбы",
+		Parts: []Part{
+			{Name: "Кирпич", MC: MCBrickPillars, Params: []ParamTypeID{PTBrickPillarSize}},
+			{Name: "Раствор", MC: MCBrickMortar},
+			{Name: "Колпаки", MC: MCBrickCaps, Params: []ParamTypeID{PTBrickPillarSize}},
+			{Name: "Кладка", MC: MCBrickLaying},
+		},
+		Params: []ParamTypeID{
+			PTBrickPillarSize,
+			PTBoolBrickCaps,
+			PTBoolInstallBrick,
+		},
+	},
+
+	// Welded filling: vertical pickets on the horizontal sticks
+	CMWeldedFilling: {
+		Name: "Сварное полотно",
+		Parts: []Part{
+			{Name: "Материал", MC: MCWeldedPickets, Params: []ParamTypeID{PTWeldedPicketSize}},
+			{Name: "Сварка", MC: MCWeldedJoints},
+			{Name: "Монтаж", Formula: fmt.Sprintf("param(%d) * param(%d)", PTBoolInstallWelded, PTTotalLength)},
+		},
+		Params: []ParamTypeID{
+			PTWeldedPicketStep,
+			PTWeldedPicketSize,
+			PTBoolInstallWelded,
 		},
 	},
 }
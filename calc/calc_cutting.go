@@ -0,0 +1,155 @@
+package calc
+
+import "sort"
+
+// Bar - заготовка складской длины и куски, отрезаемые из нее
+type Bar struct {
+	Length float64   // Длина заготовки, в м
+	Pieces []float64 // Длины кусков, в м
+}
+
+// Used - суммарная длина кусков заготовки, в м
+func (b Bar) Used() (used float64) {
+	for _, piece := range b.Pieces {
+		used += piece
+	}
+	return
+}
+
+// Rest - обрезок, остающийся от заготовки, в м
+func (b Bar) Rest() float64 {
+	rest := b.Length - b.Used()
+	if rest < lengthEpsilon {
+		return 0
+	}
+	return rest
+}
+
+// Cuts - количество резов заготовки
+// Последний кусок не требует реза, если заготовка использована без остатка
+func (b Bar) Cuts() int {
+	if b.Rest() > 0 {
+		return len(b.Pieces)
+	}
+	return len(b.Pieces) - 1
+}
+
+// CuttingPlan - раскрой кусков по заготовкам
+type CuttingPlan struct {
+	Bars []Bar
+}
+
+// Length - общая длина заготовок, в м
+func (p CuttingPlan) Length() (length float64) {
+	for _, b := range p.Bars {
+		length += b.Length
+	}
+	return
+}
+
+// Waste - общая длина обрезков, в м
+func (p CuttingPlan) Waste() (waste float64) {
+	for _, b := range p.Bars {
+		waste += b.Rest()
+	}
+	return
+}
+
+// Cuts - общее количество резов
+func (p CuttingPlan) Cuts() (cuts int) {
+	for _, b := range p.Bars {
+		cuts += b.Cuts()
+	}
+	return
+}
+
+// CutStock - раскрой кусков заданной длины по заготовкам складских длин с минимальными обрезками
+// pieces - длины требуемых кусков, в м
+// stock - доступные складские длины заготовок, в м
+//
+// Куски раскладываются по убыванию длины в заготовку с наименьшим подходящим остатком,
+// новая заготовка берется максимальной длины, после раскладки каждая заготовка
+// заменяется на самую короткую складскую длину, в которую помещаются ее куски.
+// Кусок длиннее максимальной заготовки составляется из целых заготовок и остатка
+func CutStock(pieces []float64, stock []float64) (plan CuttingPlan) {
+	var lengths []float64
+	for _, l := range stock {
+		if l > lengthEpsilon {
+			lengths = append(lengths, l)
+		}
+	}
+	if len(lengths) == 0 {
+		return
+	}
+	sort.Float64s(lengths)
+	maxLength := lengths[len(lengths)-1]
+
+	// Split the pieces longer than the longest bar
+	var sorted []float64
+	for _, piece := range pieces {
+		for piece > maxLength+lengthEpsilon {
+			plan.Bars = append(plan.Bars, Bar{Length: maxLength, Pieces: []float64{maxLength}})
+			piece -= maxLength
+		}
+		if piece > lengthEpsilon {
+			sorted = append(sorted, piece)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+
+	// Best fit decreasing
+	var bars []Bar
+	for _, piece := range sorted {
+		best := -1
+		for i := range bars {
+			rest := bars[i].Length - bars[i].Used() - piece
+			if rest < -lengthEpsilon {
+				continue
+			}
+			if best < 0 || rest < bars[best].Length-bars[best].Used()-piece {
+				best = i
+			}
+		}
+		if best < 0 {
+			bars = append(bars, Bar{Length: maxLength})
+			best = len(bars) - 1
+		}
+		bars[best].Pieces = append(bars[best].Pieces, piece)
+	}
+
+	// Replace every bar with the shortest suitable stock length
+	for i := range bars {
+		used := bars[i].Used()
+		for _, l := range lengths {
+			if l+lengthEpsilon >= used {
+				bars[i].Length = l
+				break
+			}
+		}
+	}
+
+	plan.Bars = append(plan.Bars, bars...)
+	return
+}
+
+// cuttingResults - расход материала с учетом раскроя по складским длинам номенклатуры
+// Заготовки раскроя сохраняются в args для списка закупки
+// Результаты:
+// [0] Количество материала, в м: длина заготовок или, если у номенклатуры нет складских длин, длина кусков
+// [1] Количество резов
+// [2] Длина обрезков, в м
+func (args CalculationArgs) cuttingResults(pieces []float64, n Nomenclature) []float64 {
+	if len(n.Division) == 0 {
+		var length float64
+		for _, piece := range pieces {
+			length += piece
+		}
+		return []float64{length, 0, 0}
+	}
+
+	plan := CutStock(pieces, n.Division)
+	if args.cutting != nil {
+		*args.cutting = append(*args.cutting, plan.Bars...)
+	}
+	return []float64{plan.Length(), float64(plan.Cuts()), plan.Waste()}
+}
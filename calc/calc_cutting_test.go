@@ -0,0 +1,77 @@
+package calc
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCutStock(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []float64
+		stock  []float64
+		bars   []Bar
+		cuts   int
+		waste  float64
+	}{
+		{
+			name:   "piece longer than the stock",
+			pieces: []float64{14},
+			stock:  []float64{6},
+			bars:   []Bar{{6, []float64{6}}, {6, []float64{6}}, {6, []float64{2}}},
+			cuts:   1,
+			waste:  4,
+		},
+		{
+			name:   "rest of the long piece from the shortest stock",
+			pieces: []float64{14},
+			stock:  []float64{6, 3},
+			bars:   []Bar{{6, []float64{6}}, {6, []float64{6}}, {3, []float64{2}}},
+			cuts:   1,
+			waste:  1,
+		},
+		{
+			name:   "exact fit",
+			pieces: []float64{2, 2, 2},
+			stock:  []float64{6},
+			bars:   []Bar{{6, []float64{2, 2, 2}}},
+			cuts:   2,
+			waste:  0,
+		},
+		{
+			name:   "best fit decreasing",
+			pieces: []float64{1, 3, 6, 8},
+			stock:  []float64{10},
+			bars:   []Bar{{10, []float64{8}}, {10, []float64{6, 3, 1}}},
+			cuts:   3,
+			waste:  2,
+		},
+		{
+			name:   "bar replaced with the shortest suitable stock",
+			pieces: []float64{3, 8},
+			stock:  []float64{10, 6},
+			bars:   []Bar{{10, []float64{8}}, {6, []float64{3}}},
+			cuts:   2,
+			waste:  5,
+		},
+		{
+			name:   "no stock",
+			pieces: []float64{3},
+			stock:  []float64{0},
+		},
+	}
+
+	for _, test := range tests {
+		plan := CutStock(test.pieces, test.stock)
+		if !reflect.DeepEqual(plan.Bars, test.bars) {
+			t.Errorf("%s: bars %v, want %v", test.name, plan.Bars, test.bars)
+		}
+		if plan.Cuts() != test.cuts {
+			t.Errorf("%s: cuts %d, want %d", test.name, plan.Cuts(), test.cuts)
+		}
+		if math.Abs(plan.Waste()-test.waste) > lengthEpsilon {
+			t.Errorf("%s: waste %v, want %v", test.name, plan.Waste(), test.waste)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package calc
+
+import "fmt"
+
+// RegionPart - часть участка: расчет или формула и выбранная номенклатура
+type RegionPart struct {
+	PartTypeID   int64
+	Calculation  MaterialCalculationID
+	Formula      *Formula // Если задана, используется вместо расчета Calculation
+	Nomenclature Nomenclature
+}
+
+// ResultType - тип записи в таблице результатов для значения части с порядковым номером i
+// Формула возвращает одно значение - количество номенклатуры части
+func (p RegionPart) ResultType(i int) (rt ResultTypeID, ok bool) {
+	if p.Formula != nil {
+		return RSNomenclature, i == 0
+	}
+	return MaterialCalculations[p.Calculation].ResultType(i)
+}
+
+// RegionData - исходные данные для расчета участка
+type RegionData struct {
+	RegionType RegionTypeID
+	Params     map[ParamTypeID]float64
+	Segments   []Segment // Если не заданы, участок - один прямой отрезок длиной PTTotalLength
+	Parts      []RegionPart
+}
+
+// regionEvaluator - выполнение расчетов частей участка с учетом зависимостей между расчетами
+type regionEvaluator struct {
+	RegionData
+	values   map[MaterialCalculationID][]float64 // Результаты расчетов, на которые ссылаются другие расчеты
+	bars     map[MaterialCalculationID][]Bar     // Раскрой расчетов из values
+	visiting map[MaterialCalculationID]bool      // Расчеты, выполняемые в данный момент, для поиска циклов
+
+	parts        map[int][]float64 // Результаты частей по индексу
+	partBars     map[int][]Bar     // Раскрой номенклатуры частей по индексу
+	visitingPart map[int]bool      // Части, вычисляемые в данный момент
+}
+
+// CalculateRegion - расчет всех частей участка
+// Возвращает значения результатов каждой части в порядке частей, nil для частей с незарегистрированным расчетом
+// Результаты других расчетов, нужные части, берутся у первой части участка с этим расчетом,
+// если такой части нет - расчет выполняется без номенклатуры
+// Формулы ссылаются на результаты первой части участка с указанным типом части
+// bars - заготовки раскроя номенклатуры каждой части по складским длинам, nil для частей без раскроя
+func CalculateRegion(data RegionData) (values [][]float64, bars [][]Bar, err error) {
+	if len(data.Segments) == 0 {
+		data.Segments = []Segment{{Length: data.Params[PTTotalLength]}}
+	}
+
+	e := regionEvaluator{
+		RegionData:   data,
+		values:       make(map[MaterialCalculationID][]float64),
+		bars:         make(map[MaterialCalculationID][]Bar),
+		visiting:     make(map[MaterialCalculationID]bool),
+		parts:        make(map[int][]float64),
+		partBars:     make(map[int][]Bar),
+		visitingPart: make(map[int]bool),
+	}
+
+	values = make([][]float64, len(data.Parts))
+	bars = make([][]Bar, len(data.Parts))
+	for i := range data.Parts {
+		values[i], err = e.part(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		bars[i] = e.partBars[i]
+	}
+	return
+}
+
+// part - результаты части с индексом i, вычисляются один раз
+func (e *regionEvaluator) part(i int) (values []float64, err error) {
+	if values, ok := e.parts[i]; ok {
+		return values, nil
+	}
+
+	p := e.Parts[i]
+	switch {
+	case p.Formula != nil:
+		if e.visitingPart[i] {
+			return nil, fmt.Errorf("Формула части '%d' зависит от собственного результата", p.PartTypeID)
+		}
+		e.visitingPart[i] = true
+		defer delete(e.visitingPart, i)
+
+		var v float64
+		v, err = p.Formula.Eval(FormulaEnv{
+			Param:        func(id ParamTypeID) float64 { return e.Params[id] },
+			Part:         e.partValue,
+			Nomenclature: p.Nomenclature,
+		})
+		if err != nil {
+			return
+		}
+		values = []float64{v}
+
+	case e.first(p.Calculation) == i:
+		values, err = e.result(p.Calculation)
+		e.partBars[i] = e.bars[p.Calculation]
+
+	default:
+		if _, ok := MaterialCalculations[p.Calculation]; ok {
+			values, e.partBars[i], err = e.run(p.Calculation, p.Nomenclature)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	e.parts[i] = values
+	return
+}
+
+// partValue - результат n первой части участка с типом partTypeID, 0 если такой части или результата нет
+func (e *regionEvaluator) partValue(partTypeID int64, n int) (float64, error) {
+	for i, p := range e.Parts {
+		if p.PartTypeID != partTypeID {
+			continue
+		}
+		values, err := e.part(i)
+		if err != nil || n >= len(values) {
+			return 0, err
+		}
+		return values[n], nil
+	}
+	return 0, nil
+}
+
+// first - индекс первой части участка с расчетом id без формулы, -1 если такой части нет
+func (e *regionEvaluator) first(id MaterialCalculationID) int {
+	for i, p := range e.Parts {
+		if p.Calculation == id && p.Formula == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// result - результаты расчета id для использования другими расчетами, выполняется один раз
+func (e *regionEvaluator) result(id MaterialCalculationID) (values []float64, err error) {
+	if values, ok := e.values[id]; ok {
+		return values, nil
+	}
+
+	var n Nomenclature
+	if i := e.first(id); i >= 0 {
+		n = e.Parts[i].Nomenclature
+	}
+	var bars []Bar
+	values, bars, err = e.run(id, n)
+	if err != nil {
+		return
+	}
+	e.values[id] = values
+	e.bars[id] = bars
+	return
+}
+
+// run - выполнение расчета id с номенклатурой n, bars - заготовки раскроя номенклатуры
+func (e *regionEvaluator) run(id MaterialCalculationID, n Nomenclature) (values []float64, bars []Bar, err error) {
+	mc, ok := MaterialCalculations[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("Расчет %d не зарегистрирован", id)
+	}
+	if e.visiting[id] {
+		return nil, nil, fmt.Errorf("Расчет '%s' зависит от собственного результата", mc.Name)
+	}
+	e.visiting[id] = true
+	defer delete(e.visiting, id)
+
+	args := CalculationArgs{RegionType: e.RegionType, Segments: e.Segments, Nomenclature: n, Values: make(map[string][]float64), cutting: &bars}
+	for _, in := range mc.Inputs {
+		switch in.Kind {
+		case IKParam:
+			args.Values[in.Name] = []float64{e.Params[in.Param]}
+		case IKResult:
+			dep, ok := MaterialCalculations[in.Calculation]
+			if !ok || dep.OutputIndex(in.Output) < 0 {
+				return nil, nil, fmt.Errorf("Вход '%s' расчета '%s' ссылается на неизвестный результат %d.%s", in.Name, mc.Name, in.Calculation, in.Output)
+			}
+			var depValues []float64
+			depValues, err = e.result(in.Calculation)
+			if err != nil {
+				return
+			}
+			args.Values[in.Name] = dep.Split(depValues)[in.Output]
+		}
+	}
+
+	values = mc.Func(args)
+	return
+}
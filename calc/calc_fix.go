@@ -0,0 +1,68 @@
+package calc
+
+import "math"
+
+// FixPerSheetRow - количество саморезов, крепящих один лист к одной прожилине или столбу
+// width - ширина крепления листа, в мм
+// step - шаг крепежа, в мм
+func FixPerSheetRow(width, step float64) float64 {
+	if width <= 0 {
+		return 0
+	}
+	if step <= 0 {
+		return 1
+	}
+	return math.Floor(width/step+lengthEpsilon) + 1
+}
+
+func init() {
+	RegisterCalculation(MCFix, MaterialCalculation{
+		Name: "Крепеж полотна",
+		Func: MCFixFunc,
+		Inputs: []CalculationInput{
+			{Name: "region_type", Kind: IKRegionType},
+			{Name: "enabled", Param: PTBoolFix},
+			{Name: "step", Unit: "мм", Param: PTFixStep},
+			{Name: "rows", Unit: "шт", Param: PTHStickCount},
+			{Name: "sheets", Unit: "шт", Kind: IKResult, Calculation: MCProfileSheet, Output: "count"},
+			{Name: "sheet_width", Unit: "мм", Kind: IKResult, Calculation: MCProfileSheet, Output: "width"},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "count", Unit: "шт", Result: RSFixCount},
+			{Name: "quantity", Unit: "шт", Result: RSNomenclature},
+		},
+	})
+}
+
+// MCFixFunc - расчет крепежа (саморезов) для полотна из профнастила
+// Вертикальные листы крепятся к каждой прожилине, горизонтальные - к столбам с обоих концов листа
+// Количество и ширина листов берутся из результата расчета MCProfileSheet
+// Номенклатура крепежа: Size - количество в упаковке
+//
+// Результаты:
+// count - количество саморезов
+// quantity - количество саморезов, округленное до целых упаковок
+// Пустой срез, если крепеж не выбран
+func MCFixFunc(args CalculationArgs) (res []float64) {
+	if args.Float("enabled") != 1 {
+		return
+	}
+
+	perRow := FixPerSheetRow(args.Float("sheet_width"), args.Float("step"))
+
+	var count float64
+	if args.RegionType.Horizontal() {
+		count = args.Float("sheets") * 2 * perRow
+	} else {
+		count = args.Float("sheets") * args.Float("rows") * perRow
+	}
+
+	quantity := count
+	if pack := args.Nomenclature.Size; pack > 0 {
+		quantity = math.Ceil(count/pack-lengthEpsilon) * pack
+	}
+
+	res = []float64{count, quantity}
+	return
+}
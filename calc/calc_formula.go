@@ -0,0 +1,443 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Формула расчета части, хранится в tpart.formula
+//
+// Синтаксис:
+//   числа:        12, 0.5, 1e3
+//   операции:     + - * / %, сравнения < <= > >= == != (1 - истина, 0 - ложь), && || !, скобки
+//   функции:      min(a, b, ...), max(a, b, ...), ceil(a), floor(a), round(a), abs(a), if(условие, a, b)
+//   ссылки:       param(<id>)     - значение параметра участка с типом <id>
+//                 part(<id>)      - первый результат части участка с типом <id>
+//                 part(<id>, <n>) - результат с порядковым номером <n>, начиная с 0
+//   номенклатура: size, width     - Size и Width номенклатуры части
+//
+// Пример: ceil(param(0) / 1.1) * param(23) * 5
+// Деление на 0 дает 0, ссылка на часть, отсутствующую на участке, или на несуществующий результат - 0
+
+// Formula - разобранная формула
+type Formula struct {
+	Text   string
+	Params []ParamTypeID // Параметры, на которые ссылается формула
+	Parts  []int64       // Типы частей, на результаты которых ссылается формула
+
+	root *formulaNode
+}
+
+// FormulaEnv - источник значений для вычисления формулы
+type FormulaEnv struct {
+	Param        func(id ParamTypeID) float64
+	Part         func(partTypeID int64, n int) (float64, error)
+	Nomenclature Nomenclature
+}
+
+type formulaNode struct {
+	op    string // Число (""), идентификатор, оператор или имя функции
+	value float64
+	args  []*formulaNode
+}
+
+// Функции формул: минимальное и максимальное число аргументов, -1 - без ограничения
+var formulaFuncs = map[string][2]int{
+	"min":   {1, -1},
+	"max":   {1, -1},
+	"ceil":  {1, 1},
+	"floor": {1, 1},
+	"round": {1, 1},
+	"abs":   {1, 1},
+	"if":    {3, 3},
+	"param": {1, 1},
+	"part":  {1, 2},
+}
+
+// Переменные формул
+var formulaVars = map[string]bool{
+	"size":  true,
+	"width": true,
+}
+
+// ParseFormula - разбор и проверка формулы
+// Проверяется синтаксис, имена функций, число аргументов и существование параметров
+func ParseFormula(text string) (f *Formula, err error) {
+	p := formulaParser{text: text}
+	err = p.tokenize()
+	if err != nil {
+		return
+	}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("Пустая формула")
+	}
+
+	f = &Formula{Text: text}
+	p.formula = f
+	f.root, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("Лишний символ '%s' в формуле", p.tokens[p.pos])
+	}
+	return
+}
+
+// Eval - вычисление формулы
+func (f *Formula) Eval(env FormulaEnv) (float64, error) {
+	return f.root.eval(env)
+}
+
+func (n *formulaNode) eval(env FormulaEnv) (v float64, err error) {
+	switch n.op {
+	case "":
+		return n.value, nil
+	case "size":
+		return env.Nomenclature.Size, nil
+	case "width":
+		return env.Nomenclature.Width, nil
+	case "param":
+		if env.Param == nil {
+			return 0, nil
+		}
+		return env.Param(ParamTypeID(n.args[0].value)), nil
+	case "part":
+		if env.Part == nil {
+			return 0, nil
+		}
+		var i int
+		if len(n.args) > 1 {
+			i = int(n.args[1].value)
+		}
+		return env.Part(int64(n.args[0].value), i)
+	case "if":
+		var c float64
+		c, err = n.args[0].eval(env)
+		if err != nil {
+			return
+		}
+		if c != 0 {
+			return n.args[1].eval(env)
+		}
+		return n.args[2].eval(env)
+	case "&&", "||":
+		// Второй операнд вычисляется только при необходимости
+		var a float64
+		a, err = n.args[0].eval(env)
+		if err != nil || (n.op == "&&") == (a == 0) {
+			return boolValue(a != 0), err
+		}
+		a, err = n.args[1].eval(env)
+		return boolValue(a != 0), err
+	}
+
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		args[i], err = arg.eval(env)
+		if err != nil {
+			return
+		}
+	}
+
+	switch n.op {
+	case "neg":
+		v = -args[0]
+	case "!":
+		v = boolValue(args[0] == 0)
+	case "+":
+		v = args[0] + args[1]
+	case "-":
+		v = args[0] - args[1]
+	case "*":
+		v = args[0] * args[1]
+	case "/":
+		if args[1] != 0 {
+			v = args[0] / args[1]
+		}
+	case "%":
+		if args[1] != 0 {
+			v = math.Mod(args[0], args[1])
+		}
+	case "<":
+		v = boolValue(args[0] < args[1])
+	case "<=":
+		v = boolValue(args[0] <= args[1])
+	case ">":
+		v = boolValue(args[0] > args[1])
+	case ">=":
+		v = boolValue(args[0] >= args[1])
+	case "==":
+		v = boolValue(args[0] == args[1])
+	case "!=":
+		v = boolValue(args[0] != args[1])
+	case "min":
+		v = args[0]
+		for _, a := range args[1:] {
+			v = math.Min(v, a)
+		}
+	case "max":
+		v = args[0]
+		for _, a := range args[1:] {
+			v = math.Max(v, a)
+		}
+	case "ceil":
+		v = math.Ceil(args[0] - lengthEpsilon)
+	case "floor":
+		v = math.Floor(args[0] + lengthEpsilon)
+	case "round":
+		v = math.Round(args[0])
+	case "abs":
+		v = math.Abs(args[0])
+	default:
+		err = fmt.Errorf("Неизвестная операция '%s' в формуле", n.op)
+	}
+	return
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// formulaParser - разбор формулы методом рекурсивного спуска
+type formulaParser struct {
+	text    string
+	tokens  []string
+	pos     int
+	formula *Formula
+}
+
+// tokenize - разбиение текста формулы на числа, идентификаторы и операторы
+func (p *formulaParser) tokenize() error {
+	s := p.text
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			// Экспонента: 1e3, 1e-3
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && unicode.IsDigit(rune(s[k])) {
+					for j = k; j < len(s) && unicode.IsDigit(rune(s[j])); j++ {
+					}
+				}
+			}
+			p.tokens = append(p.tokens, s[i:j])
+			i = j
+		case isFormulaLetter(s[i]):
+			j := i
+			for j < len(s) && (isFormulaLetter(s[j]) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			p.tokens = append(p.tokens, strings.ToLower(s[i:j]))
+			i = j
+		default:
+			if i+1 < len(s) {
+				if op := s[i : i+2]; op == "<=" || op == ">=" || op == "==" || op == "!=" || op == "&&" || op == "||" {
+					p.tokens = append(p.tokens, op)
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("+-*/%<>!(),", c) {
+				return fmt.Errorf("Недопустимый символ '%s' в формуле", string([]rune(s[i:])[:1]))
+			}
+			p.tokens = append(p.tokens, string(c))
+			i++
+		}
+	}
+	return nil
+}
+
+// isFormulaLetter - символ идентификатора: латинская буква или '_'
+func isFormulaLetter(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// next - текущая лексема, пустая строка в конце формулы
+func (p *formulaParser) next() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// expect - пропуск обязательной лексемы
+func (p *formulaParser) expect(token string) error {
+	if p.next() != token {
+		if p.next() == "" {
+			return fmt.Errorf("Ожидается '%s' в конце формулы", token)
+		}
+		return fmt.Errorf("Ожидается '%s' вместо '%s' в формуле", token, p.next())
+	}
+	p.pos++
+	return nil
+}
+
+// binary - разбор левоассоциативных бинарных операций одного приоритета
+func (p *formulaParser) binary(operand func() (*formulaNode, error), ops ...string) (n *formulaNode, err error) {
+	n, err = operand()
+	if err != nil {
+		return
+	}
+	for {
+		op := p.next()
+		found := false
+		for _, o := range ops {
+			if op == o {
+				found = true
+			}
+		}
+		if !found {
+			return
+		}
+		p.pos++
+
+		var right *formulaNode
+		right, err = operand()
+		if err != nil {
+			return
+		}
+		n = &formulaNode{op: op, args: []*formulaNode{n, right}}
+	}
+}
+
+func (p *formulaParser) parseOr() (*formulaNode, error) {
+	return p.binary(p.parseAnd, "||")
+}
+
+func (p *formulaParser) parseAnd() (*formulaNode, error) {
+	return p.binary(p.parseCompare, "&&")
+}
+
+func (p *formulaParser) parseCompare() (*formulaNode, error) {
+	return p.binary(p.parseAdd, "<", "<=", ">", ">=", "==", "!=")
+}
+
+func (p *formulaParser) parseAdd() (*formulaNode, error) {
+	return p.binary(p.parseMul, "+", "-")
+}
+
+func (p *formulaParser) parseMul() (*formulaNode, error) {
+	return p.binary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *formulaParser) parseUnary() (n *formulaNode, err error) {
+	switch p.next() {
+	case "-", "!":
+		op := p.next()
+		if op == "-" {
+			op = "neg"
+		}
+		p.pos++
+		n, err = p.parseUnary()
+		if err != nil {
+			return
+		}
+		return &formulaNode{op: op, args: []*formulaNode{n}}, nil
+	case "+":
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *formulaParser) parsePrimary() (n *formulaNode, err error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("Неожиданный конец формулы")
+
+	case token == "(":
+		p.pos++
+		n, err = p.parseOr()
+		if err != nil {
+			return
+		}
+		return n, p.expect(")")
+
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		var v float64
+		v, err = strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Неверное число '%s' в формуле", token)
+		}
+		p.pos++
+		return &formulaNode{value: v}, nil
+
+	case isFormulaLetter(token[0]):
+		p.pos++
+		if p.next() != "(" {
+			if !formulaVars[token] {
+				return nil, fmt.Errorf("Неизвестная переменная '%s' в формуле", token)
+			}
+			return &formulaNode{op: token}, nil
+		}
+		return p.parseCall(token)
+	}
+	return nil, fmt.Errorf("Неожиданный символ '%s' в формуле", token)
+}
+
+// parseCall - разбор вызова функции, текущая лексема - открывающая скобка
+func (p *formulaParser) parseCall(name string) (n *formulaNode, err error) {
+	arity, ok := formulaFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("Неизвестная функция '%s' в формуле", name)
+	}
+	p.pos++
+
+	n = &formulaNode{op: name}
+	for p.next() != ")" {
+		if len(n.args) > 0 {
+			if p.next() != "," {
+				return nil, p.expect(")")
+			}
+			p.pos++
+		}
+		var arg *formulaNode
+		arg, err = p.parseOr()
+		if err != nil {
+			return
+		}
+		n.args = append(n.args, arg)
+	}
+	p.pos++
+
+	if len(n.args) < arity[0] || arity[1] >= 0 && len(n.args) > arity[1] {
+		return nil, fmt.Errorf("Неверное число аргументов функции '%s' в формуле", name)
+	}
+
+	// Ссылки на параметры и части задаются целыми числами, чтобы зависимости формулы были известны до расчета
+	if name == "param" || name == "part" {
+		for _, arg := range n.args {
+			if arg.op != "" || arg.value < 0 || arg.value != math.Trunc(arg.value) {
+				return nil, fmt.Errorf("Аргументы функции '%s' в формуле должны быть целыми неотрицательными числами", name)
+			}
+		}
+		id := int64(n.args[0].value)
+		if name == "param" {
+			if id >= int64(len(Params)) {
+				return nil, fmt.Errorf("Неизвестный параметр '%d' в формуле", id)
+			}
+			p.formula.Params = append(p.formula.Params, ParamTypeID(id))
+		} else {
+			p.formula.Parts = append(p.formula.Parts, id)
+		}
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package calc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormulaEval(t *testing.T) {
+	env := FormulaEnv{
+		Param:        func(id ParamTypeID) float64 { return float64(id) * 10 },
+		Nomenclature: Nomenclature{Size: 2.5, Width: 1150},
+	}
+
+	tests := []struct {
+		text  string
+		value float64
+	}{
+		// Operator precedence
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"7 % 4 * 2", 6},
+		{"1 + 2 < 4", 1},
+		{"1 < 2 == 1", 1},
+		{"1 || 0 && 0", 1},
+		{"(1 || 0) && 0", 0},
+
+		// Unary minus and negation
+		{"-2 * 3", -6},
+		{"-(2 + 3)", -5},
+		{"2 - -3", 5},
+		{"--4", 4},
+		{"!0 + !5", 1},
+
+		// Division by zero gives 0
+		{"5 / 0", 0},
+		{"5 % 0", 0},
+		{"1 + 4 / (2 - 2)", 1},
+
+		// Functions, params and nomenclature
+		{"min(3, 1, 2) + max(3, 1, 2)", 4},
+		{"ceil(2.1) + floor(2.9) + round(2.5) + abs(-1)", 9},
+		{"if(param(1) > 5, size, width)", 2.5},
+		{"param(2) * 2", 40},
+		{"1e3 / width", 1000.0 / 1150},
+	}
+
+	for _, test := range tests {
+		f, err := ParseFormula(test.text)
+		if err != nil {
+			t.Errorf("ParseFormula(%q): %v", test.text, err)
+			continue
+		}
+		v, err := f.Eval(env)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", test.text, err)
+			continue
+		}
+		if math.Abs(v-test.value) > 1e-9 {
+			t.Errorf("Eval(%q) = %v, want %v", test.text, v, test.value)
+		}
+	}
+}
+
+func TestParseFormulaErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"length * 2",    // Unknown identifier
+		"sqrt(4)",       // Unknown function
+		"param(100000)", // Unknown param
+		"min()",
+		"ceil(1, 2)",
+		"param(1, 2)",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"2 $ 3",
+	}
+
+	for _, text := range tests {
+		if _, err := ParseFormula(text); err == nil {
+			t.Errorf("ParseFormula(%q) accepts the wrong formula", text)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package calc
+
+import "math"
+
+// FoundationMix - расход материалов на заполнение 1 м3 ямы под столб
+type FoundationMix struct {
+	Cement float64 // Цемент, в кг
+	Gravel float64 // Щебень, в м3
+	Sand   float64 // Песок, в м3
+}
+
+// Расход материалов для методов установки столбов, требующих заполнения ям
+var FoundationMixes = map[float64]FoundationMix{
+	ColumnInstallMethodСoncreting: {Cement: 290, Gravel: 0.85, Sand: 0.45}, // Бетон М200
+	ColumnInstallMethodButting:    {Cement: 0, Gravel: 0.9, Sand: 0.3},     // Щебень с песком, послойная трамбовка
+}
+
+// FoundationVolume - объем одной ямы под столб за вычетом столба, в м3
+// depth - заглубление столба, в мм
+// columnSize - размер столбов, значение параметра PTColumnSize
+// holeDiameter - диаметр ямы, в мм
+func FoundationVolume(depth, columnSize, holeDiameter float64) float64 {
+	width, height, _ := ProfileSize(ParamValueName(PTColumnSize, columnSize))
+	radius := holeDiameter / 2000
+	volume := (math.Pi*radius*radius - width*height/1000000) * depth / 1000
+	if volume < 0 {
+		return 0
+	}
+	return volume
+}
+
+// foundation - метод установки, количество столбов и общий объем ям участка, в м3
+func foundation(args CalculationArgs) (method float64, columns float64, volume float64) {
+	method = args.Float("method")
+	columns = args.Float("columns")
+	volume = columns * FoundationVolume(args.Float("depth"), args.Float("column_size"), args.Float("hole_diameter"))
+	return
+}
+
+// Входящие значения расчетов фундамента
+var foundationInputs = []CalculationInput{
+	{Name: "method", Param: PTColumnInstallMethod},
+	{Name: "columns", Unit: "шт", Kind: IKResult, Calculation: MCColumns, Output: "count"},
+	{Name: "depth", Unit: "мм", Param: PTColumnDepth},
+	{Name: "column_size", Param: PTColumnSize},
+	{Name: "hole_diameter", Unit: "мм", Param: PTColumnHoleDiameter},
+}
+
+func init() {
+	RegisterCalculation(MCFoundationCement, MaterialCalculation{
+		Name:   "Фундамент: цемент",
+		Func:   MCFoundationCementFunc,
+		Inputs: append([]CalculationInput{{Name: "nomenclature", Kind: IKNomenclature}}, foundationInputs...),
+		Outputs: []CalculationOutput{
+			{Name: "volume", Unit: "м3", Result: RSFoundationVolume},
+			{Name: "cement", Unit: "шт", Result: RSNomenclature},
+		},
+	})
+
+	RegisterCalculation(MCFoundationGravel, MaterialCalculation{
+		Name:    "Фундамент: щебень",
+		Func:    MCFoundationGravelFunc,
+		Inputs:  foundationInputs,
+		Outputs: []CalculationOutput{{Name: "gravel", Unit: "м3", Result: RSNomenclature}},
+	})
+
+	RegisterCalculation(MCFoundationSand, MaterialCalculation{
+		Name:    "Фундамент: песок",
+		Func:    MCFoundationSandFunc,
+		Inputs:  foundationInputs,
+		Outputs: []CalculationOutput{{Name: "sand", Unit: "м3", Result: RSNomenclature}},
+	})
+
+	RegisterCalculation(MCFoundationHILST, MaterialCalculation{
+		Name:    "Фундамент: HILST",
+		Func:    MCFoundationHILSTFunc,
+		Inputs:  foundationInputs,
+		Outputs: []CalculationOutput{{Name: "count", Unit: "шт", Result: RSNomenclature}},
+	})
+
+	RegisterCalculation(MCFoundationFlanges, MaterialCalculation{
+		Name:    "Фундамент: фланцы",
+		Func:    MCFoundationFlangesFunc,
+		Inputs:  foundationInputs,
+		Outputs: []CalculationOutput{{Name: "count", Unit: "шт", Result: RSNomenclature}},
+	})
+}
+
+// MCFoundationCementFunc - расчет цемента для установки столбов
+// Количество столбов берется из результата расчета MCColumns
+// Номенклатура цемента: Size - масса мешка, в кг
+//
+// Результаты:
+// volume - общий объем ям, в м3
+// cement - цемент: количество мешков или, если масса мешка не задана, в кг
+// Пустой срез, если метод установки не требует заполнения ям
+func MCFoundationCementFunc(args CalculationArgs) (res []float64) {
+	method, _, volume := foundation(args)
+	mix, ok := FoundationMixes[method]
+	if !ok {
+		return
+	}
+
+	res = []float64{volume}
+	if mix.Cement > 0 {
+		cement := volume * mix.Cement
+		if bag := args.Nomenclature.Size; bag > 0 {
+			cement = math.Ceil(cement/bag - lengthEpsilon)
+		}
+		res = append(res, cement)
+	}
+	return
+}
+
+// MCFoundationGravelFunc - расчет щебня для установки столбов
+//
+// Результаты:
+// gravel - щебень, в м3, пустой срез, если метод установки не требует заполнения ям
+func MCFoundationGravelFunc(args CalculationArgs) (res []float64) {
+	method, _, volume := foundation(args)
+	if mix, ok := FoundationMixes[method]; ok {
+		res = []float64{volume * mix.Gravel}
+	}
+	return
+}
+
+// MCFoundationSandFunc - расчет песка для установки столбов
+//
+// Результаты:
+// sand - песок, в м3, пустой срез, если метод установки не требует заполнения ям
+func MCFoundationSandFunc(args CalculationArgs) (res []float64) {
+	method, _, volume := foundation(args)
+	if mix, ok := FoundationMixes[method]; ok {
+		res = []float64{volume * mix.Sand}
+	}
+	return
+}
+
+// MCFoundationHILSTFunc - расчет количества HILST для установки столбов
+//
+// Результаты:
+// count - количество HILST, по одному на столб, пустой срез для других методов установки
+func MCFoundationHILSTFunc(args CalculationArgs) (res []float64) {
+	method, columns, _ := foundation(args)
+	if method == ColumnInstallMethodHILST {
+		res = []float64{columns}
+	}
+	return
+}
+
+// MCFoundationFlangesFunc - расчет количества фланцев для установки столбов
+//
+// Результаты:
+// count - количество фланцев, по одному на столб, пустой срез для других методов установки
+func MCFoundationFlangesFunc(args CalculationArgs) (res []float64) {
+	method, columns, _ := foundation(args)
+	if method == ColumnInstallMethodFlanges {
+		res = []float64{columns}
+	}
+	return
+}
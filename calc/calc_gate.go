@@ -0,0 +1,93 @@
+package calc
+
+// GateLeafWidth - ширина одной створки ворот или калитки, в м
+// width - ширина проема, в м
+// leaves - количество створок, меньше одной считается одной створкой
+// counterweight - длина противовеса откатных ворот в процентах от ширины проема
+func GateLeafWidth(width, leaves, counterweight float64) float64 {
+	if leaves < 1 {
+		leaves = 1
+	}
+	return width / leaves * (1 + counterweight/100)
+}
+
+// GateFramePieces - куски профиля каркаса одной створки, в м
+// Горизонтальные ряды на ширину створки, две вертикальные стойки на высоту створки
+// rails - количество горизонтальных рядов, не меньше двух: верх и низ створки
+func GateFramePieces(leafWidth, leafHeight, rails float64) (pieces []float64) {
+	if leafWidth <= lengthEpsilon || leafHeight <= lengthEpsilon {
+		return
+	}
+	if rails < 2 {
+		rails = 2
+	}
+	for i := 0; i < int(rails); i++ {
+		pieces = append(pieces, leafWidth)
+	}
+	return append(pieces, leafHeight, leafHeight)
+}
+
+func init() {
+	RegisterCalculation(MCGateFrame, MaterialCalculation{
+		Name: "Каркас ворот",
+		Func: MCGateFrameFunc,
+		Inputs: []CalculationInput{
+			{Name: "segments", Kind: IKSegments},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "bottom_space", Unit: "мм", Param: PTBottomSpace},
+			{Name: "leaves", Unit: "шт", Param: PTGateLeafCount},
+			{Name: "rails", Unit: "шт", Param: PTHStickCount},
+			{Name: "counterweight", Unit: "%", Param: PTGateCounterweight},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "leaves", Unit: "шт", Result: RSGateLeafCount},
+			{Name: "leaf_width", Unit: "м", Result: RSGateLeafWidth},
+			{Name: "metal", Unit: "м", Result: RSNomenclature},
+			{Name: "cuts", Unit: "шт", Result: RSDivisionService},
+			{Name: "waste", Unit: "м", Result: RSWaste},
+		},
+	})
+}
+
+// MCGateFrameFunc - расчет металла на каркас створок ворот и калиток
+// Каждый отрезок участка - отдельный проем, как в расчете MCColumns, ширина проема - длина отрезка,
+// высота створки - высота забора за вычетом зазора снизу
+// Распашные ворота делятся на створки, откатные и калитка - одна створка,
+// створка откатных ворот удлиняется на противовес
+// Горизонтальные ряды каркаса - количество прожилин, к ним крепится полотно
+// Номенклатура профиля: Division - складские длины, в м
+//
+// Результаты:
+// leaves - количество створок всех проемов
+// leaf_width - ширина створки, в м, при нескольких проемах - наибольшая
+// metal - металл на каркас, в м: длина заготовок с учетом раскроя или общая длина кусков
+// cuts - количество резов
+// waste - длина обрезков, в м
+func MCGateFrameFunc(args CalculationArgs) (res []float64) {
+	leaves := args.Float("leaves")
+	if leaves < 1 {
+		leaves = 1
+	}
+	leafHeight := args.Float("height") - args.Float("bottom_space")/1000
+
+	var pieces []float64
+	var count, maxWidth float64
+	for _, segment := range args.Segments {
+		if segment.Length <= lengthEpsilon {
+			continue
+		}
+		leafWidth := GateLeafWidth(segment.Length, leaves, args.Float("counterweight"))
+		if leafWidth > maxWidth {
+			maxWidth = leafWidth
+		}
+		for i := 0; i < int(leaves); i++ {
+			pieces = append(pieces, GateFramePieces(leafWidth, leafHeight, args.Float("rails"))...)
+		}
+		count += leaves
+	}
+
+	res = []float64{count, maxWidth}
+	res = append(res, args.cuttingResults(pieces, args.Nomenclature)...)
+	return
+}
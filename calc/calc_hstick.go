@@ -0,0 +1,169 @@
+package calc
+
+// HStickPieces - куски одного ряда прожилин со стыками на столбах
+// Соседние пролеты объединяются в один кусок, пока он не длиннее прожилины,
+// пролет длиннее прожилины становится отдельным куском и стыкуется внутри пролета
+// spans - длины пролетов, в м
+// stickLength - длина прожилины, в м
+//
+// Возвращает длины кусков, в м, и количество стыков на столбах
+func HStickPieces(spans []float64, stickLength float64) (pieces []float64, joints int) {
+	var piece float64
+	for _, span := range spans {
+		if piece > 0 && (stickLength <= 0 || piece+span > stickLength+lengthEpsilon) {
+			pieces = append(pieces, piece)
+			joints++
+			piece = 0
+		}
+		piece += span
+	}
+	if piece > 0 {
+		pieces = append(pieces, piece)
+	}
+	return
+}
+
+// HStickHeights - высоты рядов прожилин от земли, в м
+// Нижняя прожилина отступает от низа полотна, верхняя - от верха столбов, остальные распределяются равномерно
+// count - количество прожилин
+// totalHeight - высота забора, в м
+// bottomSpace - зазор снизу, в мм
+// upSpace - выступ столбов сверху, в мм
+// hstickBottomSpace - нижняя прожилина от низа профнастила, в мм
+// hstickUpSpace - верхняя прожилина от верха столбов, в мм
+func HStickHeights(count int, totalHeight, bottomSpace, upSpace, hstickBottomSpace, hstickUpSpace float64) (heights []float64) {
+	if count <= 0 {
+		return
+	}
+
+	bottom := (bottomSpace + hstickBottomSpace) / 1000
+	top := totalHeight + (upSpace-hstickUpSpace)/1000
+	if count == 1 {
+		return []float64{bottom}
+	}
+
+	for i := 0; i < count; i++ {
+		heights = append(heights, bottom+(top-bottom)*float64(i)/float64(count-1))
+	}
+	return
+}
+
+// hstickRows - куски всех рядов прожилин участка и количество стыков на столбах
+// runs - длины пролетов непрерывных рядов, см. SpanRuns, на разрыве ряда куски стыкуются на столбе
+func hstickRows(runs [][]float64, count, stickLength float64) (pieces []float64, joints int) {
+	var rowPieces []float64
+	var rowJoints int
+	for i, run := range runs {
+		runPieces, runJoints := HStickPieces(run, stickLength)
+		rowPieces = append(rowPieces, runPieces...)
+		rowJoints += runJoints
+		if i > 0 {
+			rowJoints++
+		}
+	}
+
+	for i := 0; i < int(count); i++ {
+		pieces = append(pieces, rowPieces...)
+		joints += rowJoints
+	}
+	return
+}
+
+func init() {
+	RegisterCalculation(MCHStick, MaterialCalculation{
+		Name: "Прожилины",
+		Func: MCHStickFunc,
+		Inputs: []CalculationInput{
+			{Name: "region_type", Kind: IKRegionType},
+			{Name: "segments", Kind: IKSegments},
+			{Name: "step_length", Unit: "м", Param: PTColumnStepLength},
+			{Name: "step_type", Param: PTColumnStepType},
+			{Name: "step_space", Param: PTColumnStepSpace},
+			{Name: "slope_type", Param: PTSlopeType},
+			{Name: "rows", Unit: "шт", Param: PTHStickCount},
+			{Name: "stick_length", Unit: "м", Param: PTHStickLeghth},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "bottom_space", Unit: "мм", Param: PTBottomSpace},
+			{Name: "up_space", Unit: "мм", Param: PTUpSpace},
+			{Name: "hstick_bottom_space", Unit: "мм", Param: PTHStickBottomSpace},
+			{Name: "hstick_up_space", Unit: "мм", Param: PTHStickUpSpace},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "metal", Unit: "м", Result: RSNomenclature},
+			{Name: "cuts", Unit: "шт", Result: RSDivisionService},
+			{Name: "waste", Unit: "м", Result: RSWaste},
+			{Name: "sticks", Unit: "шт", Result: RSHStickCount},
+			{Name: "joints", Unit: "шт", Result: RSHStickJoints},
+			{Name: "heights", Unit: "м", Result: RSHStickHeight, List: true},
+		},
+	})
+
+	RegisterCalculation(MCHStickMount, MaterialCalculation{
+		Name: "Монтаж прожилин",
+		Func: MCHStickMountFunc,
+		Inputs: []CalculationInput{
+			{Name: "enabled", Param: PTBoolInstallHStick},
+			{Name: "rows", Unit: "шт", Param: PTHStickCount},
+			{Name: "columns", Unit: "шт", Kind: IKResult, Calculation: MCColumns, Output: "count"},
+			{Name: "joints", Unit: "шт", Kind: IKResult, Calculation: MCHStick, Output: "joints"},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "points", Unit: "шт", Result: RSNomenclature},
+		},
+	})
+}
+
+// MCHStickFunc - расчет прожилин: раскладка со стыками на столбах и раскрой по длине прожилин
+// Пролеты отрезков участка разбиваются так же, как в расчете MCColumns,
+// ряд прожилин прерывается на угловых столбах и ступенях, см. SpanRuns
+// Номенклатура прожилин: Division - складские длины, в м, если длина прожилин не задана
+//
+// Результаты:
+// metal - металл на прожилины, в м: длина заготовок с учетом раскроя или общая длина прожилин
+// cuts - количество резов
+// waste - длина обрезков, в м
+// sticks - количество прожилин (заготовок)
+// joints - количество стыков на столбах
+// heights - высоты рядов прожилин от земли, в м
+func MCHStickFunc(args CalculationArgs) (res []float64) {
+	count := args.Float("rows")
+	stickLength := args.Float("stick_length")
+	slopeType := args.Float("slope_type")
+	spans := RegionSpans(args.RegionType, args.Segments, args.Float("step_length"), args.Float("step_type"), args.Float("step_space"))
+	pieces, joints := hstickRows(SpanRuns(args.Segments, spans, slopeType), count, stickLength)
+
+	// Pieces are cut from the sticks of the chosen length, or from stock lengths of nomenclature
+	n := args.Nomenclature
+	if stickLength > 0 {
+		n.Division = []float64{stickLength}
+	}
+
+	var sticks int
+	if len(n.Division) > 0 {
+		sticks = len(CutStock(pieces, n.Division).Bars)
+	} else {
+		sticks = len(pieces)
+	}
+
+	res = args.cuttingResults(pieces, n)
+	res = append(res, float64(sticks), float64(joints))
+	res = append(res, HStickHeights(int(count), args.Float("height"), args.Float("bottom_space"), args.Float("up_space"),
+		args.Float("hstick_bottom_space"), args.Float("hstick_up_space"))...)
+	return
+}
+
+// MCHStickMountFunc - расчет монтажа прожилин: количество точек крепления к столбам
+// Каждая прожилина крепится к каждому столбу, через который проходит, на стыке крепятся оба куска
+// Количество столбов и стыков берется из результатов расчетов MCColumns и MCHStick
+//
+// Результаты:
+// points - количество точек крепления, пустой срез, если монтаж не выбран
+func MCHStickMountFunc(args CalculationArgs) (res []float64) {
+	if args.Float("enabled") != 1 {
+		return
+	}
+
+	res = []float64{args.Float("rows")*args.Float("columns") + args.Float("joints")}
+	return
+}
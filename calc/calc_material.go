@@ -1,48 +1,223 @@
 package calc
 
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
 // Тип расчета материала
+// Идентификаторы хранятся в tpart.tcalculation_id, поэтому новые расчеты добавляются только в конец списка
 type MaterialCalculationID int64
 
 const (
 	MCDoNotCalculate MaterialCalculationID = iota
-	MCPaintArea
+	MCPaintArea                            // Не регистрируется и не принимается API: краска считается расчетами MCColumnPaint, MCHStickPaint, MCCanvasPaint, части с этим расчетом переведены на них при конвертации БД
+	MCColumns
+	MCColumnPaint
+	MCHStickPaint
+	MCCanvasPaint
+	MCProfileSheet
+	MCHStick
+	MCHStickMount
+	MCFoundationCement
+	MCFoundationGravel
+	MCFoundationSand
+	MCFoundationHILST
+	MCFoundationFlanges
+	MCFix
+	MCMeshPanels
+	MCMeshClamps
+	MCGateFrame
+	MCBrickPillars
+	MCBrickMortar
+	MCBrickCaps
+	MCBrickLaying
+	MCWeldedPickets
+	MCWeldedJoints
+)
+
+// Вид входящего значения расчета
+type InputKind int
+
+const (
+	IKParam        InputKind = iota // Значение параметра участка
+	IKResult                        // Результат расчета другой части участка
+	IKRegionType                    // Тип участка
+	IKNomenclature                  // Номенклатура части
+	IKSegments                      // Отрезки участка
 )
 
+// Названия видов входящих значений для API
+var InputKindNames = [...]string{
+	IKParam:        "param",
+	IKResult:       "result",
+	IKRegionType:   "region_type",
+	IKNomenclature: "nomenclature",
+	IKSegments:     "segments",
+}
+
+// CalculationInput - описание входящего значения расчета
+type CalculationInput struct {
+	Name string // Имя, по которому расчет получает значение из CalculationArgs
+	Unit string
+	Kind InputKind
+
+	Param ParamTypeID // IKParam: тип параметра участка
+
+	// IKResult: расчет и имя его результата
+	Calculation MaterialCalculationID
+	Output      string
+}
+
+// CalculationOutput - описание результата расчета
+type CalculationOutput struct {
+	Name   string       // Имя, по которому результат используется другими расчетами
+	Unit   string       //
+	Result ResultTypeID // Тип записи в таблице результатов
+
+	// Список значений произвольной длины, допускается только последним результатом
+	List bool
+}
+
+// CalculationArgs - входящие значения расчета
+type CalculationArgs struct {
+	RegionType   RegionTypeID
+	Segments     []Segment // Отрезки участка, не меньше одного
+	Nomenclature Nomenclature
+	Values       map[string][]float64 // Значения параметров и результатов других расчетов по именам входов
+
+	cutting *[]Bar // Заготовки раскроя номенклатуры, выполненного расчетом, см. cuttingResults
+}
+
+// Float - значение входа name, 0 если значение не задано
+func (a CalculationArgs) Float(name string) float64 {
+	if v := a.Values[name]; len(v) > 0 {
+		return v[0]
+	}
+	return 0
+}
+
+// Floats - все значения входа name (для результатов-списков)
+func (a CalculationArgs) Floats(name string) []float64 {
+	return a.Values[name]
+}
+
 type MaterialCalculation struct {
-	Name string
-	Func func(...interface{}) []float64
+	Name    string
+	Inputs  []CalculationInput
+	Outputs []CalculationOutput
+
+	// Func возвращает значения результатов в порядке Outputs
+	// Результат-список занимает все оставшиеся значения, лишние значения отбрасываются
+	Func func(CalculationArgs) []float64
+}
+
+// OutputIndex - порядковый номер результата с именем name, -1 если такого результата нет
+func (mc MaterialCalculation) OutputIndex(name string) int {
+	for i, o := range mc.Outputs {
+		if o.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Split - разбиение значений, возвращенных Func, по именам результатов
+func (mc MaterialCalculation) Split(values []float64) map[string][]float64 {
+	named := make(map[string][]float64)
+	for i, o := range mc.Outputs {
+		if i >= len(values) {
+			break
+		}
+		if o.List {
+			named[o.Name] = values[i:]
+			break
+		}
+		named[o.Name] = values[i : i+1]
+	}
+	return named
+}
+
+// ResultType - тип записи в таблице результатов для значения с порядковым номером i
+// ok = false, если значение не соответствует ни одному результату
+func (mc MaterialCalculation) ResultType(i int) (rt ResultTypeID, ok bool) {
+	if i < 0 || len(mc.Outputs) == 0 {
+		return
+	}
+	if i < len(mc.Outputs) {
+		return mc.Outputs[i].Result, true
+	}
+	if last := mc.Outputs[len(mc.Outputs)-1]; last.List {
+		return last.Result, true
+	}
+	return
 }
 
-var MaterialCalculations = [...]MaterialCalculation{
-	MCDoNotCalculate: {
+// Зарегистрированные расчеты материалов
+var MaterialCalculations = make(map[MaterialCalculationID]MaterialCalculation)
+
+// RegisterCalculation - регистрация расчета материала
+// Вызывается из init() файла, реализующего расчет
+func RegisterCalculation(id MaterialCalculationID, mc MaterialCalculation) {
+	if _, ok := MaterialCalculations[id]; ok {
+		panic(fmt.Sprintf("calc: расчет %d (%s) уже зарегистрирован", id, mc.Name))
+	}
+	for i, o := range mc.Outputs {
+		if o.List && i != len(mc.Outputs)-1 {
+			panic(fmt.Sprintf("calc: результат-список '%s' расчета '%s' должен быть последним", o.Name, mc.Name))
+		}
+	}
+	if mc.Func == nil {
+		mc.Func = MCDoNotCalculateFunc
+	}
+	MaterialCalculations[id] = mc
+}
+
+// MaterialCalculationIDs - идентификаторы зарегистрированных расчетов по возрастанию
+func MaterialCalculationIDs() (ids []MaterialCalculationID) {
+	for id := range MaterialCalculations {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+func init() {
+	RegisterCalculation(MCDoNotCalculate, MaterialCalculation{
 		Name: "Не расчитывать",
 		Func: MCDoNotCalculateFunc,
-	},
-
-	MCPaintArea: {
-		Name: "Покраска поверхности",
-		Func: MCPaintAreaFunc,
-	},
+	})
 }
 
 // MCDoNotCalculateFunc - функция-заглушка для материалов, не требующих расчета
 // Входящие параметры: нет
 // Результаты: пустой срез
-func MCDoNotCalculateFunc(...interface{}) []float64 {
+func MCDoNotCalculateFunc(CalculationArgs) []float64 {
 	return []float64{}
 }
 
-// MCPaintAreaFunc - расчет краски, требуемой на покраску поверхности определеной площади
-// Входящие параметры:
-// [0] Объем одной банки краски, в мл
-// [1] Площадь поверхности, в м2
-// [2] Коэффициент расхода краски, мл на 1 м2
+// PaintArea - расчет краски, требуемой на покраску поверхности определеной площади
+// canVolume - объем одной банки краски, в мл
+// area - площадь поверхности, в м2
+// consumption - коэффициент расхода краски, мл на 1 м2
 //
 // Результаты:
 // [0] Количество банок краски
 // [1] Объем требуемой краски, в мл
 // [2] Остаток, в мл
-func MCPaintAreaFunc(...interface{}) (res []float64) {
-	res = []float64{0} // TODO: implement real calculations
+func PaintArea(canVolume, area, consumption float64) (res []float64) {
+	volume := area * consumption
+
+	var cans float64
+	if canVolume > 0 {
+		cans = math.Ceil(volume/canVolume - lengthEpsilon)
+	}
+	rest := cans*canVolume - volume
+	if rest < 0 {
+		rest = 0
+	}
+
+	res = []float64{cans, volume, rest}
 	return
 }
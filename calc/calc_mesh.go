@@ -0,0 +1,83 @@
+package calc
+
+import "math"
+
+// MeshPanelWidth - ширина панели сетки, в мм
+// Берется из номенклатуры панели, если не задана в номенклатуре - из параметра участка
+func MeshPanelWidth(width float64, n Nomenclature) float64 {
+	if n.Width > 0 {
+		return n.Width
+	}
+	return width
+}
+
+// MeshPanels - количество панелей сетки на пролеты между столбами
+// spans - длины пролетов, в м
+// width - ширина панели, в мм
+//
+// Возвращает количество панелей и количество панелей, которые нужно подрезать под пролет
+func MeshPanels(spans []float64, width float64) (count, trimmed int) {
+	if width <= 0 {
+		return
+	}
+	for _, span := range spans {
+		n := math.Ceil(span*1000/width - lengthEpsilon)
+		count += int(n)
+		if n*width-span*1000 > lengthEpsilon*1000 {
+			trimmed++
+		}
+	}
+	return
+}
+
+func init() {
+	RegisterCalculation(MCMeshPanels, MaterialCalculation{
+		Name: "Панели сетки",
+		Func: MCMeshPanelsFunc,
+		Inputs: []CalculationInput{
+			{Name: "spans", Unit: "м", Kind: IKResult, Calculation: MCColumns, Output: "spans"},
+			{Name: "panel_width", Unit: "мм", Param: PTMeshPanelWidth},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "count", Unit: "шт", Result: RSNomenclature},
+			{Name: "trimmed", Unit: "шт", Result: RSDivisionService},
+		},
+	})
+
+	RegisterCalculation(MCMeshClamps, MaterialCalculation{
+		Name: "Хомуты панелей сетки",
+		Func: MCMeshClampsFunc,
+		Inputs: []CalculationInput{
+			{Name: "columns", Unit: "шт", Kind: IKResult, Calculation: MCColumns, Output: "count"},
+			{Name: "clamps", Unit: "шт", Param: PTMeshClamps},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "count", Unit: "шт", Result: RSNomenclature},
+		},
+	})
+}
+
+// MCMeshPanelsFunc - расчет панелей сетки (2D, 3D) на пролеты между столбами
+// Пролеты берутся из результата расчета MCColumns, пролет короче панели закрывается подрезанной панелью
+// Номенклатура панели: Width - ширина панели, в мм
+//
+// Результаты:
+// count - количество панелей
+// trimmed - количество панелей, подрезаемых под пролет
+func MCMeshPanelsFunc(args CalculationArgs) (res []float64) {
+	count, trimmed := MeshPanels(args.Floats("spans"), MeshPanelWidth(args.Float("panel_width"), args.Nomenclature))
+
+	res = []float64{float64(count), float64(trimmed)}
+	return
+}
+
+// MCMeshClampsFunc - расчет хомутов крепления панелей сетки к столбам
+// Хомут на промежуточном столбе крепит обе соседние панели, поэтому количество хомутов на каждый столб одинаково
+//
+// Результаты:
+// count - количество хомутов
+func MCMeshClampsFunc(args CalculationArgs) (res []float64) {
+	res = []float64{args.Float("columns") * args.Float("clamps")}
+	return
+}
@@ -0,0 +1,11 @@
+package calc
+
+// Nomenclature - свойства номенклатуры части, используемые в расчетах материалов
+type Nomenclature struct {
+	ID    int64
+	Size  float64 // Размер, смысл зависит от типа номенклатуры: для краски - объем банки, в мл, для цемента - масса мешка, в кг, для крепежа - количество в упаковке
+	Width float64 // Полезная ширина с учетом нахлеста, для профлиста, в мм
+
+	Division          []float64 // Складские длины, в м, из которых нарезается материал
+	DivisionServiceID *int64    // Номенклатура услуги резки
+}
@@ -0,0 +1,173 @@
+package calc
+
+// Расход краски по умолчанию, мл на 1 м2 (один слой): значение параметра PTPaintConsumption
+// для новых участков и расход для участков, в которых параметр не задан
+const PaintConsumption = 120
+
+// paintConsumption - расход краски, мл на 1 м2, из значения параметра PTPaintConsumption
+func paintConsumption(value float64) float64 {
+	if value > 0 {
+		return value
+	}
+	return PaintConsumption
+}
+
+// paintOn - проверка значения параметра-галочки окрашивания
+func paintOn(value float64) bool {
+	return value == 1
+}
+
+// paintResults - результаты расчета краски для поверхности заданной площади
+// consumption - значение параметра PTPaintConsumption, см. paintConsumption
+// Результаты:
+// [0] Площадь поверхности, в м2
+// [1] Количество банок краски
+// [2] Объем требуемой краски, в мл
+// [3] Остаток, в мл
+func paintResults(area, consumption float64, n Nomenclature) []float64 {
+	return append([]float64{area}, PaintArea(n.Size, area, paintConsumption(consumption))...)
+}
+
+// Результаты расчетов краски
+var paintOutputs = []CalculationOutput{
+	{Name: "area", Unit: "м2", Result: RSPaintArea},
+	{Name: "cans", Unit: "шт", Result: RSNomenclature},
+	{Name: "volume", Unit: "мл", Result: RSPaintVolume},
+	{Name: "rest", Unit: "мл", Result: RSPaintRest},
+}
+
+// ColumnPaintArea - площадь окрашиваемой поверхности столбов, в м2
+// Окрашивается надземная часть столбов по периметру профиля
+// columnCount - количество столбов
+// totalHeight - высота забора, в м
+// upSpace - выступ столбов сверху, в мм
+// columnSize - размер столбов, значение параметра PTColumnSize
+func ColumnPaintArea(columnCount, totalHeight, upSpace, columnSize float64) float64 {
+	width, height, _ := ProfileSize(ParamValueName(PTColumnSize, columnSize))
+	return columnCount * 2 * (width + height) / 1000 * (totalHeight + upSpace/1000)
+}
+
+// HStickPaintArea - площадь окрашиваемой поверхности прожилин, в м2
+// totalLength - длина ряда прожилин, в м: длина полотна пролетов, см. SpansCanvasLength
+// hstickCount - количество рядов прожилин
+// hstickSize - размер прожилин, значение параметра PTHStickSize
+func HStickPaintArea(totalLength, hstickCount, hstickSize float64) float64 {
+	width, height, _ := ProfileSize(ParamValueName(PTHStickSize, hstickSize))
+	return hstickCount * totalLength * 2 * (width + height) / 1000
+}
+
+// CanvasPaintArea - площадь окрашиваемой поверхности полотна забора с одной стороны, в м2
+// totalLength - длина полотна, в м: длина полотна пролетов, см. SpansCanvasLength
+// totalHeight - высота забора, в м
+// bottomSpace - зазор снизу, в мм
+func CanvasPaintArea(totalLength, totalHeight, bottomSpace float64) float64 {
+	height := totalHeight - bottomSpace/1000
+	if height < 0 {
+		height = 0
+	}
+	return totalLength * height
+}
+
+func init() {
+	RegisterCalculation(MCColumnPaint, MaterialCalculation{
+		Name: "Покраска столбов",
+		Func: MCColumnPaintFunc,
+		Inputs: []CalculationInput{
+			{Name: "enabled", Param: PTBoolColumnPaint},
+			{Name: "columns", Unit: "шт", Kind: IKResult, Calculation: MCColumns, Output: "count"},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "up_space", Unit: "мм", Param: PTUpSpace},
+			{Name: "column_size", Param: PTColumnSize},
+			{Name: "consumption", Unit: "мл/м2", Param: PTPaintConsumption},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: paintOutputs,
+	})
+
+	RegisterCalculation(MCHStickPaint, MaterialCalculation{
+		Name: "Покраска прожилин",
+		Func: MCHStickPaintFunc,
+		Inputs: []CalculationInput{
+			{Name: "enabled", Param: PTBoolHStickPaint},
+			{Name: "region_type", Kind: IKRegionType},
+			{Name: "segments", Kind: IKSegments},
+			{Name: "step_length", Unit: "м", Param: PTColumnStepLength},
+			{Name: "step_type", Param: PTColumnStepType},
+			{Name: "step_space", Param: PTColumnStepSpace},
+			{Name: "slope_type", Param: PTSlopeType},
+			{Name: "rows", Unit: "шт", Param: PTHStickCount},
+			{Name: "hstick_size", Param: PTHStickSize},
+			{Name: "consumption", Unit: "мл/м2", Param: PTPaintConsumption},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: paintOutputs,
+	})
+
+	RegisterCalculation(MCCanvasPaint, MaterialCalculation{
+		Name: "Покраска полотна",
+		Func: MCCanvasPaintFunc,
+		Inputs: []CalculationInput{
+			{Name: "enabled", Param: PTBoolCanvasPaint},
+			{Name: "region_type", Kind: IKRegionType},
+			{Name: "segments", Kind: IKSegments},
+			{Name: "step_length", Unit: "м", Param: PTColumnStepLength},
+			{Name: "step_type", Param: PTColumnStepType},
+			{Name: "step_space", Param: PTColumnStepSpace},
+			{Name: "slope_type", Param: PTSlopeType},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "bottom_space", Unit: "мм", Param: PTBottomSpace},
+			{Name: "consumption", Unit: "мл/м2", Param: PTPaintConsumption},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: paintOutputs,
+	})
+}
+
+// MCColumnPaintFunc - расчет краски на покраску столбов
+// Количество столбов берется из результата расчета MCColumns
+// Номенклатура краски: Size - объем банки, в мл; расход краски - параметр PTPaintConsumption
+//
+// Результаты: см. paintResults, пустой срез, если окрашивание не выбрано
+func MCColumnPaintFunc(args CalculationArgs) (res []float64) {
+	if !paintOn(args.Float("enabled")) {
+		return
+	}
+
+	area := ColumnPaintArea(args.Float("columns"), args.Float("height"), args.Float("up_space"), args.Float("column_size"))
+	res = paintResults(area, args.Float("consumption"), args.Nomenclature)
+	return
+}
+
+// MCHStickPaintFunc - расчет краски на покраску прожилин
+// Длина рядов прожилин - длина полотна пролетов отрезков участка, как в расчете MCHStick
+// Номенклатура краски: Size - объем банки, в мл; расход краски - параметр PTPaintConsumption
+//
+// Результаты: см. paintResults, пустой срез, если окрашивание не выбрано
+func MCHStickPaintFunc(args CalculationArgs) (res []float64) {
+	if !paintOn(args.Float("enabled")) {
+		return
+	}
+
+	spans := RegionSpans(args.RegionType, args.Segments, args.Float("step_length"), args.Float("step_type"), args.Float("step_space"))
+	length := SpansCanvasLength(spans, args.Float("slope_type"))
+	area := HStickPaintArea(length, args.Float("rows"), args.Float("hstick_size"))
+	res = paintResults(area, args.Float("consumption"), args.Nomenclature)
+	return
+}
+
+// MCCanvasPaintFunc - расчет краски на покраску полотна забора
+// Длина полотна - длина полотна пролетов отрезков участка: по уклону для полотна по уклону
+// Номенклатура краски: Size - объем банки, в мл; расход краски - параметр PTPaintConsumption
+//
+// Результаты: см. paintResults, пустой срез, если окрашивание не выбрано
+func MCCanvasPaintFunc(args CalculationArgs) (res []float64) {
+	if !paintOn(args.Float("enabled")) {
+		return
+	}
+
+	spans := RegionSpans(args.RegionType, args.Segments, args.Float("step_length"), args.Float("step_type"), args.Float("step_space"))
+	length := SpansCanvasLength(spans, args.Float("slope_type"))
+	area := CanvasPaintArea(length, args.Float("height"), args.Float("bottom_space"))
+	res = paintResults(area, args.Float("consumption"), args.Nomenclature)
+	return
+}
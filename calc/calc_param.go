@@ -1,5 +1,10 @@
 package calc
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Типы входящих параметров для расчета
 type ParamTypeID int64
 
@@ -35,6 +40,28 @@ const (
 	PTBoolInstallHStick
 	PTColorHStick
 	PTBoolHStickPaint
+	PTColumnHoleDiameter
+	PTFixStep
+	PTMeshPanelWidth
+	PTMeshPanelHeight
+	PTMeshClamps
+	PTColorMesh
+	PTBoolInstallMesh
+	PTGateLeafCount
+	PTGateHinges
+	PTBoolGateLock
+	PTBoolGateAutomation
+	PTGateFrameSize
+	PTGateCounterweight
+	PTBoolInstallGate
+	PTSlopeType
+	PTPaintConsumption
+	PTBrickPillarSize
+	PTBoolBrickCaps
+	PTBoolInstallBrick
+	PTWeldedPicketStep
+	PTWeldedPicketSize
+	PTBoolInstallWelded
 )
 
 const BoolParamName string = "<bool>" // Special label in Value.Name to mark the parameter as a checkbox (ON/OFF)
@@ -45,6 +72,7 @@ const UndefinedParamValue = -1 // This value means the parameter is not set
 type ParamValue struct {
 	Value           float64                   // Parameter value
 	Name            string                    // The value description, if needed
+	Size            float64                   // Numeric property of the value, if needed: effective width of profile sheet type, in mm
 	DependentParams map[ParamTypeID][]float64 //
 }
 
@@ -87,6 +115,18 @@ const (
 	ColumnInstallMethodFlanges                   // Фланцы
 )
 
+// Полотно на уклоне
+const (
+	SlopeStepped float64 = iota // Ступенчатое: пролеты горизонтальные, перепад на столбах
+	SlopeRaked                  // По уклону: полотно повторяет уклон земли
+)
+
+// Сечение кирпичного столба
+const (
+	BrickPillar15 float64 = iota // 1.5x1.5 кирпича
+	BrickPillar2                 // 2x2 кирпича
+)
+
 var Params = [...]Param{
 	PTTotalLength: {
 		Name: "Длина участка, в м",
@@ -137,8 +177,16 @@ var Params = [...]Param{
 		Values: []ParamValue{
 			{Value: ColumnInstallMethodСoncreting, Name: "Бетонирование"},
 			{Value: ColumnInstallMethodButting, Name: "Бутирование"},
-			{Value: ColumnInstallMethodHILST, Name: "HILST"},
-			{Value: ColumnInstallMethodFlanges, Name: "Фланцы"},
+			{Value: ColumnInstallMethodHILST, Name: "HILST",
+				DependentParams: map[ParamTypeID][]float64{
+					PTColumnHoleDiameter: {},
+				},
+			},
+			{Value: ColumnInstallMethodFlanges, Name: "Фланцы",
+				DependentParams: map[ParamTypeID][]float64{
+					PTColumnHoleDiameter: {},
+				},
+			},
 		},
 	},
 	PTColumnSize: {
@@ -215,11 +263,11 @@ var Params = [...]Param{
 		Name:        "Тип",
 		Description: "Тип профлиста",
 		Values: []ParamValue{
-			{Value: 0, Name: "ССм 10"},
-			{Value: 1, Name: "С10 М1"},
-			{Value: 2, Name: "Мп20"},
-			{Value: 3, Name: "С21"},
-			{Value: 4, Name: "С44"},
+			{Value: 0, Name: "ССм 10", Size: 1100},
+			{Value: 1, Name: "С10 М1", Size: 1100},
+			{Value: 2, Name: "Мп20", Size: 1100},
+			{Value: 3, Name: "С21", Size: 1000},
+			{Value: 4, Name: "С44", Size: 1000},
 		},
 	},
 	PTBoolInstallCanvas: { // Галочка "Монтаж" забора
@@ -283,6 +331,7 @@ var Params = [...]Param{
 				Name:  BoolParamName,
 				DependentParams: map[ParamTypeID][]float64{
 					PTColorFix: {},
+					PTFixStep:  {},
 				},
 			},
 			{Value: 1, Name: BoolParamName},
@@ -356,4 +405,233 @@ var Params = [...]Param{
 			{Value: 1, Name: BoolParamName},
 		},
 	},
+	PTColumnHoleDiameter: {
+		Prio: 1,
+		Name: "Диаметр ямы под столб, в мм",
+		Values: []ParamValue{
+			{Value: 200},
+		},
+	},
+	PTFixStep: {
+		Prio:        1,
+		Name:        "Шаг крепежа, мм",
+		Description: "Шаг саморезов вдоль прожилины или столба",
+		Values: []ParamValue{
+			{Value: 250},
+		},
+	},
+	PTMeshPanelWidth: {
+		Name:        "Ширина панели, мм",
+		Description: "Ширина панели сетки",
+		Values: []ParamValue{
+			{Value: 2500},
+		},
+	},
+	PTMeshPanelHeight: {
+		Name:        "Высота панели, мм",
+		Description: "Высота панели сетки, определяет количество хомутов на столб",
+		Values: []ParamValue{
+			{Value: 1030, DependentParams: map[ParamTypeID][]float64{PTMeshClamps: {2}}},
+			{Value: 1230, DependentParams: map[ParamTypeID][]float64{PTMeshClamps: {3}}},
+			{Value: 1530, DependentParams: map[ParamTypeID][]float64{PTMeshClamps: {3}}},
+			{Value: 1730, DependentParams: map[ParamTypeID][]float64{PTMeshClamps: {4}}},
+			{Value: 2030, DependentParams: map[ParamTypeID][]float64{PTMeshClamps: {4}}},
+			{Value: 2430, DependentParams: map[ParamTypeID][]float64{PTMeshClamps: {5}}},
+		},
+	},
+	PTMeshClamps: {
+		Prio:        1,
+		Name:        "Хомуты",
+		Description: "Количество хомутов крепления панелей на один столб",
+		Values: []ParamValue{
+			{Value: 2},
+			{Value: 3},
+			{Value: 4},
+			{Value: 5},
+		},
+	},
+	PTColorMesh: {
+		Name:        "Цвет",
+		Description: "Цвет панелей сетки и хомутов",
+		Values: []ParamValue{
+			{Value: 0, Name: ColorParamName},
+		},
+	},
+	PTBoolInstallMesh: { // Галочка "Монтаж" панелей
+		Name:        "Монтаж",
+		Description: "Монтаж панелей сетки",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+	PTGateLeafCount: {
+		Name:        "Створки",
+		Description: "Количество створок распашных ворот",
+		Values: []ParamValue{
+			{Value: 2},
+			{Value: 1},
+		},
+	},
+	PTGateHinges: {
+		Name:        "Петли",
+		Description: "Количество петель на одну створку",
+		Values: []ParamValue{
+			{Value: 2},
+			{Value: 3},
+		},
+	},
+	PTBoolGateLock: {
+		Name:        "Замок",
+		Description: "Замок ворот или калитки",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+	PTBoolGateAutomation: {
+		Name:        "Автоматика",
+		Description: "Привод ворот",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+	PTGateFrameSize: {
+		Name:        "Материалы",
+		Description: "Размер профиля каркаса створки",
+		Values: []ParamValue{
+			{Value: 0, Name: "40x20x2"},
+			{Value: 1, Name: "60x40x2"},
+			{Value: 2, Name: "60x40x3"},
+		},
+	},
+	PTGateCounterweight: {
+		Name:        "Противовес, %",
+		Description: "Длина противовеса откатных ворот в процентах от ширины проема",
+		Values: []ParamValue{
+			{Value: 50},
+		},
+	},
+	PTBoolInstallGate: { // Галочка "Монтаж" ворот
+		Name:        "Монтаж",
+		Description: "Монтаж ворот или калитки",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+	PTSlopeType: {
+		Name:        "Уклон",
+		Description: "Полотно на участках с перепадом высоты",
+		Values: []ParamValue{
+			{Value: SlopeStepped, Name: "Ступенчатое"},
+			{Value: SlopeRaked, Name: "По уклону"},
+		},
+	},
+	PTPaintConsumption: {
+		Name:        "Расход краски, мл/м2",
+		Description: "Расход краски на один слой, мл на 1 м2",
+		Values: []ParamValue{
+			{Value: PaintConsumption},
+		},
+	},
+	PTBrickPillarSize: {
+		Name:        "Размер столба",
+		Description: "Сечение кирпичного столба, в кирпичах",
+		Values: []ParamValue{
+			{Value: BrickPillar15, Name: "1.5x1.5 (380x380)", Size: 380},
+			{Value: BrickPillar2, Name: "2x2 (510x510)", Size: 510},
+		},
+	},
+	PTBoolBrickCaps: { // Галочка "Колпаки" кирпичных столбов
+		Name:        "Колпаки",
+		Description: "Колпаки на кирпичные столбы",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+	PTBoolInstallBrick: { // Галочка "Монтаж" кирпичных столбов
+		Name:        "Монтаж",
+		Description: "Кладка кирпичных столбов",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+	PTWeldedPicketStep: {
+		Name:        "Шаг прутьев, в мм",
+		Description: "Расстояние между осями вертикальных прутьев сварного полотна",
+		Values: []ParamValue{
+			{Value: 120},
+		},
+	},
+	PTWeldedPicketSize: {
+		Name:        "Материалы",
+		Description: "Размер прутьев сварного полотна",
+		Values: []ParamValue{
+			{Value: 0, Name: "15x15x1.5"},
+			{Value: 1, Name: "20x20x1.5"},
+			{Value: 2, Name: "20x20x2"},
+		},
+	},
+	PTBoolInstallWelded: { // Галочка "Монтаж" сварного полотна
+		Name:        "Монтаж",
+		Description: "Сварка и монтаж полотна",
+		Values: []ParamValue{
+			{Value: 0, Name: BoolParamName},
+			{Value: 1, Name: BoolParamName},
+		},
+	},
+}
+
+// ParamValueName - наименование значения параметра из списка возможных значений
+// Если значение не найдено в списке, возвращается пустая строка
+func ParamValueName(paramTypeID ParamTypeID, value float64) string {
+	if paramTypeID < 0 || int(paramTypeID) >= len(Params) {
+		return ""
+	}
+
+	for _, v := range Params[paramTypeID].Values {
+		if v.Value == value {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// ParamValueSize - числовая характеристика значения параметра из списка возможных значений
+// Если значение не найдено в списке, возвращается 0
+func ParamValueSize(paramTypeID ParamTypeID, value float64) float64 {
+	if paramTypeID < 0 || int(paramTypeID) >= len(Params) {
+		return 0
+	}
+
+	for _, v := range Params[paramTypeID].Values {
+		if v.Value == value {
+			return v.Size
+		}
+	}
+	return 0
+}
+
+// ProfileSize - размеры профильной трубы из наименования вида "60x40x2", в мм
+// Возвращает ширину, высоту и толщину стенки, нули - если наименование не удается распознать
+func ProfileSize(name string) (width, height, thickness float64) {
+	sizes := strings.Split(name, "x")
+	if len(sizes) != 3 {
+		return
+	}
+
+	var values [3]float64
+	for i, size := range sizes {
+		var err error
+		values[i], err = strconv.ParseFloat(size, 64)
+		if err != nil {
+			return
+		}
+	}
+
+	return values[0], values[1], values[2]
 }
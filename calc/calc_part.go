@@ -1,6 +1,8 @@
 package calc
 
 type Part struct {
-	Name string
-	MC   MaterialCalculationID
+	Name    string
+	MC      MaterialCalculationID
+	Formula string        // Формула количества вместо расчета, см. ParseFormula
+	Params  []ParamTypeID // Параметры, от значений которых зависит выбор номенклатуры части
 }
@@ -1,10 +1,11 @@
 package calc
 
 // Типы участков
-// TODO: добавить недостающие типы участков: Кирпичный забор, Сварной забор, Калитки, Ворота и т.д.
 type Region struct {
 	Name       string
 	Components []ComponentTypeID // Типы компонентов по умолчанию, для начального заполнения бд, могут быть изменены
+	Horizontal bool              // Горизонтальное расположение листов полотна
+	Gate       bool              // Один проем между двумя столбами: ворота, калитки
 }
 
 type RegionTypeID int64
@@ -22,6 +23,11 @@ const (
 	RT2D
 	RT3D
 	RTGrandLine
+	RTSwingGate
+	RTSlidingGate
+	RTWicket
+	RTBrickFence
+	RTWeldedFence
 )
 
 var Regions = [...]Region{
@@ -36,6 +42,7 @@ var Regions = [...]Region{
 	RTProflistFenceHor: {
 		Name:       "Классический забор из профнастила горизонтальный",
 		Components: []ComponentTypeID{CMRegion, CMColumns, CMFilling},
+		Horizontal: true,
 	},
 
 	RTBattenFenceVer: {
@@ -45,6 +52,7 @@ var Regions = [...]Region{
 	RTBattenFenceHor: {
 		Name:       "Классический забор из штакетника горизонтальный",
 		Components: []ComponentTypeID{CMColumns, CMFilling},
+		Horizontal: true,
 	},
 	RTKnxProflistFence: {
 		Name:       "Забор KNX из профнастила",
@@ -64,14 +72,47 @@ var Regions = [...]Region{
 	},
 	RT2D: {
 		Name:       "2D забор",
-		Components: []ComponentTypeID{CMColumns, CMHStick, CMFilling},
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMMeshPanels},
 	},
 	RT3D: {
 		Name:       "3D забор",
-		Components: []ComponentTypeID{CMColumns, CMHStick, CMFilling},
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMMeshPanels},
 	},
 	RTGrandLine: {
 		Name:       "Модульный забор Grand Line",
 		Components: []ComponentTypeID{CMColumns, CMHStick, CMFilling},
 	},
+	RTSwingGate: {
+		Name:       "Распашные ворота",
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMSwingGate, CMFilling},
+		Gate:       true,
+	},
+	RTSlidingGate: {
+		Name:       "Откатные ворота",
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMSlidingGate, CMFilling},
+		Gate:       true,
+	},
+	RTWicket: {
+		Name:       "Калитка",
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMWicket, CMFilling},
+		Gate:       true,
+	},
+	RTBrickFence: {
+		Name:       "Забор с кирпичными столбами",
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMBrickPillars, CMHStick, CMFilling},
+	},
+	RTWeldedFence: {
+		Name:       "Сварной забор",
+		Components: []ComponentTypeID{CMRegion, CMColumns, CMHStick, CMWeldedFilling},
+	},
+}
+
+// Horizontal - горизонтальное расположение листов полотна для участка типа id
+func (id RegionTypeID) Horizontal() bool {
+	return id >= 0 && int(id) < len(Regions) && Regions[id].Horizontal
+}
+
+// Gate - участок типа id является проемом ворот или калитки между двумя столбами
+func (id RegionTypeID) Gate() bool {
+	return id >= 0 && int(id) < len(Regions) && Regions[id].Gate
 }
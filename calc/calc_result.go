@@ -5,6 +5,27 @@ type ResultTypeID int64
 
 const (
 	RSNomenclature ResultTypeID = iota
+	RSColumnCount
+	RSColumnLength
+	RSColumnSpan
+	RSPaintArea
+	RSPaintVolume
+	RSPaintRest
+	RSSheetTotalLength
+	RSSheetLength
+	RSDivisionService
+	RSWaste
+	RSHStickCount
+	RSHStickJoints
+	RSHStickHeight
+	RSFoundationVolume
+	RSFixCount
+	RSSheetWidth
+	RSGateLeafCount
+	RSGateLeafWidth
+	RSCornerColumnCount
+	RSMasonryVolume
+	RSPicketCount
 )
 
 type Result struct {
@@ -17,4 +38,88 @@ var Results = [...]Result{
 		Name:        "Позиция",
 		Description: "Номенклатура, расход материала или услуги",
 	},
+	RSColumnCount: {
+		Name:        "Количество столбов",
+		Description: "Количество столбов на участке, шт",
+	},
+	RSColumnLength: {
+		Name:        "Длина столба",
+		Description: "Длина одного столба с учетом заглубления и выступа сверху, м",
+	},
+	RSColumnSpan: {
+		Name:        "Пролет",
+		Description: "Расстояние между соседними столбами, м",
+	},
+	RSPaintArea: {
+		Name:        "Площадь покраски",
+		Description: "Площадь окрашиваемой поверхности, м2",
+	},
+	RSPaintVolume: {
+		Name:        "Расход краски",
+		Description: "Объем краски, требуемой на покраску, мл",
+	},
+	RSPaintRest: {
+		Name:        "Остаток краски",
+		Description: "Остаток краски в последней банке, мл",
+	},
+	RSSheetTotalLength: {
+		Name:        "Длина листов",
+		Description: "Общая длина листов полотна, м",
+	},
+	RSSheetLength: {
+		Name:        "Длина листа",
+		Description: "Длина листа полотна, м",
+	},
+	RSDivisionService: {
+		Name:        "Резка",
+		Description: "Количество резов при раскрое материала, услуга резки номенклатуры",
+	},
+	RSWaste: {
+		Name:        "Обрезки",
+		Description: "Длина обрезков, остающихся после раскроя материала, м",
+	},
+	RSHStickCount: {
+		Name:        "Количество прожилин",
+		Description: "Количество прожилин заданной длины, шт",
+	},
+	RSHStickJoints: {
+		Name:        "Стыки прожилин",
+		Description: "Количество стыков прожилин на столбах, шт",
+	},
+	RSHStickHeight: {
+		Name:        "Высота прожилины",
+		Description: "Высота ряда прожилин от земли, м",
+	},
+	RSFoundationVolume: {
+		Name:        "Объем ям",
+		Description: "Объем ям под столбы за вычетом столбов, м3",
+	},
+	RSFixCount: {
+		Name:        "Количество крепежа",
+		Description: "Количество саморезов для крепления полотна без учета упаковки, шт",
+	},
+	RSSheetWidth: {
+		Name:        "Ширина листа",
+		Description: "Полезная ширина листа профнастила, мм",
+	},
+	RSGateLeafCount: {
+		Name:        "Количество створок",
+		Description: "Количество створок ворот или калитки, шт",
+	},
+	RSGateLeafWidth: {
+		Name:        "Ширина створки",
+		Description: "Ширина створки с учетом противовеса откатных ворот, м",
+	},
+	RSCornerColumnCount: {
+		Name:        "Угловые столбы",
+		Description: "Количество столбов на поворотах участка, шт",
+	},
+	RSMasonryVolume: {
+		Name:        "Объем кладки",
+		Description: "Объем кладки кирпичных столбов, м3",
+	},
+	RSPicketCount: {
+		Name:        "Количество прутьев",
+		Description: "Количество вертикальных прутьев сварного полотна, шт",
+	},
 }
@@ -0,0 +1,103 @@
+package calc
+
+import "math"
+
+// Segment - прямой отрезок ломаной линии участка
+type Segment struct {
+	Length    float64 // Длина по горизонтали, в м
+	Angle     float64 // Угол поворота в начале отрезка относительно предыдущего, в градусах, у первого отрезка не учитывается
+	Elevation float64 // Перепад высоты земли от начала до конца отрезка, в м, положительный - подъем
+}
+
+// Span - пролет между соседними столбами
+type Span struct {
+	Segment int     // Индекс отрезка участка
+	Length  float64 // Длина по горизонтали, в м
+	Rise    float64 // Перепад высоты земли, в м
+}
+
+// SlopeLength - длина пролета по уклону, в м
+func (s Span) SlopeLength() float64 {
+	return math.Hypot(s.Length, s.Rise)
+}
+
+// CanvasLength - длина полотна пролета, в м: по уклону для полотна по уклону, по горизонтали для ступенчатого
+func (s Span) CanvasLength(slopeType float64) float64 {
+	if slopeType == SlopeRaked {
+		return s.SlopeLength()
+	}
+	return s.Length
+}
+
+// Угол поворота, меньше которого вершина не считается углом, в градусах
+const angleEpsilon = 0.5
+
+// IsCorner - в начале отрезка i поворот, на вершине ставится угловой столб
+func IsCorner(segments []Segment, i int) bool {
+	return i > 0 && i < len(segments) && math.Abs(segments[i].Angle) >= angleEpsilon
+}
+
+// SegmentSpans - разбиение всех отрезков участка на пролеты между столбами
+// Столбы ставятся на каждой вершине ломаной, каждый отрезок разбивается на пролеты отдельно, см. ColumnSpans
+// Перепад высоты отрезка делится между пролетами пропорционально их длине
+func SegmentSpans(segments []Segment, stepLength, stepType, stepSpace float64) (spans []Span) {
+	for i, segment := range segments {
+		for _, length := range ColumnSpans(segment.Length, stepLength, stepType, stepSpace) {
+			span := Span{Segment: i, Length: length}
+			if segment.Length > lengthEpsilon {
+				span.Rise = segment.Elevation * length / segment.Length
+			}
+			spans = append(spans, span)
+		}
+	}
+	return
+}
+
+// SegmentsLength - общая длина отрезков участка по горизонтали, в м
+func SegmentsLength(segments []Segment) (length float64) {
+	for _, segment := range segments {
+		length += segment.Length
+	}
+	return
+}
+
+// RegionSpans - пролеты участка типа regionType, см. SegmentSpans
+// Проем ворот или калитки - один пролет на каждый отрезок независимо от шага
+func RegionSpans(regionType RegionTypeID, segments []Segment, stepLength, stepType, stepSpace float64) []Span {
+	if regionType.Gate() {
+		return SegmentSpans(segments, 0, ColumnStepSpecified, ColumnStepSpaceEnd)
+	}
+	return SegmentSpans(segments, stepLength, stepType, stepSpace)
+}
+
+// SpansCanvasLength - общая длина полотна пролетов, в м, см. Span.CanvasLength
+func SpansCanvasLength(spans []Span, slopeType float64) (length float64) {
+	for _, span := range spans {
+		length += span.CanvasLength(slopeType)
+	}
+	return
+}
+
+// SpanRuns - длины полотна пролетов, сгруппированные в непрерывные ряды
+// Ряд прерывается на угловом столбе, при ступенчатом полотне - на каждой ступени,
+// при полотне по уклону - при изменении уклона
+func SpanRuns(segments []Segment, spans []Span, slopeType float64) (runs [][]float64) {
+	for i, span := range spans {
+		if i == 0 || spanBreak(segments, spans[i-1], span, slopeType) {
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], span.CanvasLength(slopeType))
+	}
+	return
+}
+
+// spanBreak - ряд полотна прерывается на столбе между пролетами prev и next
+func spanBreak(segments []Segment, prev, next Span, slopeType float64) bool {
+	if prev.Segment != next.Segment && IsCorner(segments, next.Segment) {
+		return true
+	}
+	if slopeType == SlopeRaked {
+		return math.Abs(prev.Rise/prev.Length-next.Rise/next.Length) > lengthEpsilon
+	}
+	return math.Abs(prev.Rise) > lengthEpsilon || math.Abs(next.Rise) > lengthEpsilon
+}
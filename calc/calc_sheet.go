@@ -0,0 +1,89 @@
+package calc
+
+import "math"
+
+// SheetWidth - полезная ширина листа профнастила, в мм
+// Берется из номенклатуры листа, если не задана в номенклатуре - из типа профлиста
+func SheetWidth(sheetType float64, n Nomenclature) float64 {
+	if n.Width > 0 {
+		return n.Width
+	}
+	return ParamValueSize(PTProfileSheetType, sheetType)
+}
+
+// SheetCount - количество листов, необходимое для закрытия полосы заданной ширины
+// length - ширина закрываемой полосы, в м
+// width - полезная ширина листа с учетом нахлеста, в мм
+func SheetCount(length, width float64) float64 {
+	if length <= lengthEpsilon || width <= 0 {
+		return 0
+	}
+	return math.Ceil(length*1000/width - lengthEpsilon)
+}
+
+func init() {
+	RegisterCalculation(MCProfileSheet, MaterialCalculation{
+		Name: "Листы профнастила",
+		Func: MCProfileSheetFunc,
+		Inputs: []CalculationInput{
+			{Name: "region_type", Kind: IKRegionType},
+			{Name: "segments", Kind: IKSegments},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "bottom_space", Unit: "мм", Param: PTBottomSpace},
+			{Name: "spans", Unit: "м", Kind: IKResult, Calculation: MCColumns, Output: "spans"},
+			{Name: "sheet_type", Param: PTProfileSheetType},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "count", Unit: "шт", Result: RSNomenclature},
+			{Name: "total_length", Unit: "м", Result: RSSheetTotalLength},
+			{Name: "width", Unit: "мм", Result: RSSheetWidth},
+			{Name: "lengths", Unit: "м", Result: RSSheetLength, List: true},
+		},
+	})
+}
+
+// MCProfileSheetFunc - расчет количества и длины листов профнастила для полотна забора
+// Для вертикального полотна листы ставятся на высоту полотна по длине каждого отрезка участка,
+// на вершине ломаной лист не переходит на следующий отрезок
+// Для горизонтального полотна листы укладываются рядами в каждом пролете между столбами,
+// пролеты берутся из результата расчета MCColumns
+// Номенклатура профлиста: Width - полезная ширина листа, в мм
+//
+// Результаты:
+// count - количество листов
+// total_length - общая длина листов, в м
+// width - полезная ширина листа, в мм
+// lengths - длины листов, в м: одно значение для вертикального полотна, длины листов каждого пролета для горизонтального
+func MCProfileSheetFunc(args CalculationArgs) (res []float64) {
+	height := args.Float("height") - args.Float("bottom_space")/1000
+	width := SheetWidth(args.Float("sheet_type"), args.Nomenclature)
+
+	if height < 0 {
+		height = 0
+	}
+
+	var count, total float64
+	var lengths []float64
+
+	if args.RegionType.Horizontal() {
+		// Горизонтальное полотно: ряды листов на высоту полотна в каждом пролете
+		rows := SheetCount(height, width)
+		for _, span := range args.Floats("spans") {
+			count += rows
+			total += rows * span
+			lengths = append(lengths, span)
+		}
+	} else {
+		// Вертикальное полотно: листы на высоту полотна по длине каждого отрезка
+		for _, segment := range args.Segments {
+			count += SheetCount(segment.Length, width)
+		}
+		total = count * height
+		lengths = append(lengths, height)
+	}
+
+	res = []float64{count, total, width}
+	res = append(res, lengths...)
+	return
+}
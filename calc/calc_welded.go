@@ -0,0 +1,84 @@
+package calc
+
+import "math"
+
+// WeldedPickets - количество вертикальных прутьев сварного полотна в пролетах между столбами
+// spans - длины пролетов, в м
+// step - расстояние между осями прутьев, в мм: первый пруток отступает от столба на шаг, у столба пруток не ставится
+func WeldedPickets(spans []float64, step float64) (count int) {
+	if step <= 0 {
+		return
+	}
+	for _, span := range spans {
+		if n := int(math.Ceil(span*1000/step-lengthEpsilon)) - 1; n > 0 {
+			count += n
+		}
+	}
+	return
+}
+
+func init() {
+	RegisterCalculation(MCWeldedPickets, MaterialCalculation{
+		Name: "Прутья сварного полотна",
+		Func: MCWeldedPicketsFunc,
+		Inputs: []CalculationInput{
+			{Name: "spans", Unit: "м", Kind: IKResult, Calculation: MCColumns, Output: "spans"},
+			{Name: "step", Unit: "мм", Param: PTWeldedPicketStep},
+			{Name: "height", Unit: "м", Param: PTTotalHeight},
+			{Name: "bottom_space", Unit: "мм", Param: PTBottomSpace},
+			{Name: "nomenclature", Kind: IKNomenclature},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "count", Unit: "шт", Result: RSPicketCount},
+			{Name: "metal", Unit: "м", Result: RSNomenclature},
+			{Name: "cuts", Unit: "шт", Result: RSDivisionService},
+			{Name: "waste", Unit: "м", Result: RSWaste},
+		},
+	})
+
+	RegisterCalculation(MCWeldedJoints, MaterialCalculation{
+		Name: "Сварка прутьев",
+		Func: MCWeldedJointsFunc,
+		Inputs: []CalculationInput{
+			{Name: "pickets", Unit: "шт", Kind: IKResult, Calculation: MCWeldedPickets, Output: "count"},
+			{Name: "rows", Unit: "шт", Param: PTHStickCount},
+		},
+		Outputs: []CalculationOutput{
+			{Name: "joints", Unit: "шт", Result: RSNomenclature},
+		},
+	})
+}
+
+// MCWeldedPicketsFunc - расчет металла на вертикальные прутья сварного полотна
+// Пролеты берутся из результата расчета MCColumns, длина прутка - высота забора за вычетом зазора снизу
+// Номенклатура прутьев: Division - складские длины, в м
+//
+// Результаты:
+// count - количество прутьев
+// metal - металл на прутья, в м: длина заготовок с учетом раскроя или общая длина прутьев
+// cuts - количество резов
+// waste - длина обрезков, в м
+func MCWeldedPicketsFunc(args CalculationArgs) (res []float64) {
+	count := WeldedPickets(args.Floats("spans"), args.Float("step"))
+	length := args.Float("height") - args.Float("bottom_space")/1000
+
+	var pieces []float64
+	if length > lengthEpsilon {
+		for i := 0; i < count; i++ {
+			pieces = append(pieces, length)
+		}
+	}
+
+	res = []float64{float64(count)}
+	res = append(res, args.cuttingResults(pieces, args.Nomenclature)...)
+	return
+}
+
+// MCWeldedJointsFunc - расчет сварных швов: каждый пруток приваривается к каждому ряду прожилин
+//
+// Результаты:
+// joints - количество сварных швов
+func MCWeldedJointsFunc(args CalculationArgs) (res []float64) {
+	res = []float64{args.Float("pickets") * args.Float("rows")}
+	return
+}
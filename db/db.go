@@ -6,12 +6,18 @@ import (
 	"os"
 	"path/filepath"
 
-	_ "github.com/mattn/go-sqlite3"
-	"knx/calc"
+	"github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
+// Querier - DB or a transaction: reads used both on their own and inside the transaction of the caller
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 var sqlDeclarations []string = []string{
 	`CREATE TABLE meta (
     key      TEXT NOT NULL DEFAULT '' UNIQUE,
@@ -23,7 +29,17 @@ var sqlDeclarations []string = []string{
     name     TEXT NOT NULL DEFAULT '',
     phone    TEXT NOT NULL DEFAULT '',
     position TEXT NOT NULL DEFAULT '',
-    comment  TEXT NOT NULL DEFAULT '')`,
+    comment  TEXT NOT NULL DEFAULT '',
+    password_hash TEXT NOT NULL DEFAULT '',
+    admin    INTEGER NOT NULL DEFAULT 0)`,
+
+	`CREATE TABLE session (
+    token_hash    TEXT PRIMARY KEY,
+    user_id       INTEGER REFERENCES user(id) ON DELETE CASCADE NOT NULL,
+    created       TEXT NOT NULL,
+    expires       TEXT NOT NULL)`,
+
+	`CREATE INDEX idx_session_expires ON session(expires)`,
 
 	`CREATE TABLE client (
     id       INTEGER PRIMARY KEY,
@@ -41,7 +57,27 @@ var sqlDeclarations []string = []string{
     contract_date DATETIME,
     install_date  DATETIME,
     address       TEXT NOT NULL DEFAULT '',
-    comment       TEXT NOT NULL DEFAULT '')`,
+    comment       TEXT NOT NULL DEFAULT '',
+    status        TEXT NOT NULL DEFAULT 'draft',
+    version_id    INTEGER REFERENCES project_version(id) ON DELETE SET NULL)`,
+
+	`CREATE INDEX idx_project_status ON project(status)`,
+
+	`CREATE TABLE project_status (
+    id            INTEGER PRIMARY KEY,
+    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    status        TEXT NOT NULL DEFAULT '',
+    date          DATETIME NOT NULL,
+    user_id       INTEGER REFERENCES user(id) NOT NULL)`,
+
+	`CREATE TABLE project_version (
+    id            INTEGER PRIMARY KEY,
+    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    name          TEXT NOT NULL DEFAULT '',
+    date          DATETIME NOT NULL,
+    user_id       INTEGER REFERENCES user(id) NOT NULL,
+    comment       TEXT NOT NULL DEFAULT '',
+    UNIQUE(project_id, name))`,
 
 	`CREATE TABLE tregion (
     id            INTEGER PRIMARY KEY,
@@ -50,9 +86,11 @@ var sqlDeclarations []string = []string{
 	`CREATE TABLE region (
     id            INTEGER PRIMARY KEY,
     description   TEXT NOT NULL DEFAULT '',
-    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    version_id    INTEGER REFERENCES project_version(id) ON DELETE CASCADE ON UPDATE CASCADE,
     tregion_id    INTEGER REFERENCES tregion(id) NOT NULL,
-    nr            INTEGER NOT NULL DEFAULT 0)`,
+    nr            INTEGER NOT NULL DEFAULT 0,
+    CHECK((project_id IS NULL) <> (version_id IS NULL)))`,
 
 	`CREATE TABLE tparam (
     id            INTEGER PRIMARY KEY,
@@ -74,7 +112,8 @@ var sqlDeclarations []string = []string{
 	`CREATE TABLE tparamvalue (
     tparam_id     INTEGER REFERENCES tparam(id) NOT NULL,
     value         FLOAT NOT NULL DEFAULT 0,
-    name          TEXT NOT NULL DEFAULT '')`,
+    name          TEXT NOT NULL DEFAULT '',
+    size          FLOAT NOT NULL DEFAULT 0)`,
 
 	`CREATE TABLE tresult (
     id            INTEGER PRIMARY KEY,
@@ -106,6 +145,7 @@ var sqlDeclarations []string = []string{
     thickness        FLOAT NOT NULL DEFAULT 0,
     color_id         INTEGER REFERENCES color(id),
     size             FLOAT NOT NULL DEFAULT 0,
+    width            FLOAT NOT NULL DEFAULT 0,
     division         TEXT NOT NULL DEFAULT '',
     division_service_nomenclature_id INTEGER REFERENCES nomenclature(id) )`,
 
@@ -116,7 +156,10 @@ var sqlDeclarations []string = []string{
     tresult_id      INTEGER REFERENCES tresult(id) NOT NULL,
     region_id       INTEGER REFERENCES region(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
     nomenclature_id INTEGER REFERENCES nomenclature(id),
-    value           FLOAT NOT NULL DEFAULT 0)`,
+    value           FLOAT NOT NULL DEFAULT 0,
+    price_date      DATETIME,
+    price           INTEGER,
+    cost_price      INTEGER)`,
 
 	`CREATE TABLE cn_tnomenclature_usefield (
     tnomenclature_id INTEGER REFERENCES tnomenclature(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
@@ -134,7 +177,8 @@ var sqlDeclarations []string = []string{
     id              INTEGER PRIMARY KEY,
     name            TEXT NOT NULL DEFAULT '',
     tcomponent_id   INTEGER REFERENCES tcomponent(id) NOT NULL,
-    tcalculation_id INTEGER REFERENCES tcalculation(id) )`,
+    tcalculation_id INTEGER REFERENCES tcalculation(id),
+    formula         TEXT NOT NULL DEFAULT '' )`,
 
 	`CREATE TABLE cn_tpart_nomenclature (
     tpart_id        INTEGER REFERENCES tpart(id) NOT NULL,
@@ -156,6 +200,14 @@ var sqlDeclarations []string = []string{
     nomenclature_id INTEGER REFERENCES nomenclature(id) ON DELETE SET NULL ON UPDATE CASCADE,
     UNIQUE(tpart_id, component_id) )`,
 
+	`CREATE TABLE segment (
+    id              INTEGER PRIMARY KEY,
+    region_id       INTEGER REFERENCES region(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    nr              INTEGER NOT NULL DEFAULT 0,
+    length          FLOAT NOT NULL DEFAULT 0,
+    angle           FLOAT NOT NULL DEFAULT 0,
+    elevation       FLOAT NOT NULL DEFAULT 0 )`,
+
 	`CREATE TABLE price (
     nomenclature_id INTEGER REFERENCES nomenclature(id) NOT NULL,
     date            DATETIME NOT NULL,
@@ -163,6 +215,23 @@ var sqlDeclarations []string = []string{
     price           INTEGER NOT NULL DEFAULT 0,
     UNIQUE(nomenclature_id, date) )`,
 
+	`CREATE TABLE stock (
+    id              INTEGER PRIMARY KEY,
+    nomenclature_id INTEGER REFERENCES nomenclature(id) NOT NULL,
+    date            DATETIME NOT NULL,
+    quantity        FLOAT NOT NULL DEFAULT 0,
+    project_id      INTEGER REFERENCES project(id) ON DELETE SET NULL,
+    comment         TEXT NOT NULL DEFAULT '' )`,
+
+	`CREATE INDEX idx_stock_nomenclature ON stock(nomenclature_id)`,
+
+	`CREATE TABLE reservation (
+    project_id      INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    nomenclature_id INTEGER REFERENCES nomenclature(id) NOT NULL,
+    date            DATETIME NOT NULL,
+    quantity        FLOAT NOT NULL DEFAULT 0,
+    UNIQUE(project_id, nomenclature_id) )`,
+
 	`CREATE TABLE cn_tparam_tpart (
     tparam_id       INTEGER REFERENCES tparam(id) NOT NULL,
     tpart_id        INTEGER REFERENCES tpart(id) NOT NULL,
@@ -193,176 +262,151 @@ var sqlDeclarations []string = []string{
 
 const (
 	MetaKeyVersion = "version"
+
+	// Реквизиты компании и настройки печатных форм
+	MetaKeyCompanyName    = "company_name"
+	MetaKeyCompanyAddress = "company_address"
+	MetaKeyCompanyPhone   = "company_phone"
+	MetaKeyCompanyEmail   = "company_email"
+	MetaKeyOfferValidDays = "offer_valid_days" // Срок действия коммерческого предложения, в днях
+	MetaKeyPDFFont        = "pdf_font"         // Путь к шрифту TrueType для PDF, пустой - поиск шрифта, см. report.FindFont
 )
 
 var MetaValues map[string]string = map[string]string{
-	MetaKeyVersion: "2018-05-13",
+	MetaKeyVersion:        "2026-10-18.17",
+	MetaKeyCompanyName:    "KNX",
+	MetaKeyCompanyAddress: "",
+	MetaKeyCompanyPhone:   "",
+	MetaKeyCompanyEmail:   "",
+	MetaKeyOfferValidDays: "14",
+	MetaKeyPDFFont:        "",
 }
 
-// convertDB - convert DB from one version to another
-func convertDB(db *sql.DB, sourceVersion string, targetVersion string) error {
-	// TODO: Make DB converter
-	return fmt.Errorf("DB has unsupportable version '%s'. Actual version should be '%s'. Converting DB is not supported!",
-		sourceVersion, targetVersion)
+// IsConflict - check if the error is a violation of the unique constraint
+func IsConflict(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && (e.ExtendedCode == sqlite3.ErrConstraintUnique || e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
 }
 
-// createDB - create new DB with actual version
-func createDB(db *sql.DB) (err error) {
-	// Start transaction
-	tx, err := db.Begin()
+// IsForeignKey - check if the error is a violation of the foreign key constraint
+func IsForeignKey(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && e.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// MissingReferences - columns of the row of the table, which refer to missing rows of other tables
+// values - values of the columns of the row, columns without foreign keys are ignored
+// SQLite does not tell the column of the violated foreign key, so the references are checked one by one
+func MissingReferences(table string, values map[string]interface{}) (columns []string, err error) {
+	type reference struct{ column, table, to string }
+	var references []reference
+
+	rows, err := DB.Query("PRAGMA foreign_key_list(" + table + ")")
 	if err != nil {
 		return
 	}
-
-	defer tx.Commit()
-
-	// Create empty tables
-	for _, sqlQuery := range sqlDeclarations {
-		_, err = tx.Exec(sqlQuery)
+	defer rows.Close()
+	for rows.Next() {
+		var id, seq int
+		var r reference
+		var to sql.NullString
+		var onUpdate, onDelete, match string
+		err = rows.Scan(&id, &seq, &r.table, &r.column, &to, &onUpdate, &onDelete, &match)
 		if err != nil {
 			return
 		}
-	}
-
-	// Fill the tables with constant enums
-	// [meta]
-	for key, value := range MetaValues {
-		_, err = tx.Exec("INSERT INTO meta(key,value) VALUES(?,?)", key, value)
-		if err != nil {
-			return
+		r.to = "id"
+		if to.Valid {
+			r.to = to.String
 		}
+		references = append(references, r)
 	}
-
-	// [tregion]
-	for id, tregion := range calc.Regions {
-		_, err = tx.Exec("INSERT INTO tregion(id,name) VALUES(?,?)", id, tregion.Name)
-		if err != nil {
-			return
-		}
+	err = rows.Err()
+	if err != nil {
+		return
 	}
+	rows.Close()
 
-	// [tparam] [tparamvalue]
-	for id, tparam := range calc.Params {
-		_, err = tx.Exec("INSERT INTO tparam(id,prio,name,description) VALUES(?,?,?,?)", id, tparam.Prio, tparam.Name, tparam.Description)
+	for _, r := range references {
+		value, ok := values[r.column]
+		if !ok || value == nil {
+			continue
+		}
+		var count int
+		err = DB.QueryRow("SELECT count(*) FROM "+r.table+" WHERE "+r.to+"=?", value).Scan(&count)
 		if err != nil {
 			return
 		}
-
-		for _, value := range tparam.Values {
-
-			// For color param declaration: use colors from color scheme as values
-			if value.Name == calc.ColorParamName {
-				cs := calc.ColorSchemes[int(value.Value)]
-				for _, color := range cs.Colors {
-					_, err = tx.Exec("INSERT INTO tparamvalue(tparam_id,value,name) VALUES(?,?,?)", id, color.Value, color.Name)
-					if err != nil {
-						return
-					}
-				}
-				continue
-			}
-
-			_, err = tx.Exec("INSERT INTO tparamvalue(tparam_id,value,name) VALUES(?,?,?)", id, value.Value, value.Name)
-			if err != nil {
-				return
-			}
+		if count == 0 {
+			columns = append(columns, r.column)
 		}
 	}
+	return
+}
 
-	// [cn_tparamvalue_tparamvalue]
-	for id, tparam := range calc.Params {
-		for _, value := range tparam.Values {
-			for dependendParamID, dependentValues := range value.DependentParams {
-				// For empty value list add -1 means value is not set and is not available to choose
-				if len(dependentValues) == 0 {
-					_, err = tx.Exec("INSERT INTO cn_tparamvalue_tparamvalue(tparam_id,value,dependent_tparam_id, dependent_value) VALUES(?,?,?,?)",
-						id, value.Value, dependendParamID, -1)
-					if err != nil {
-						return
-					}
-				}
-				// Add all the values, manually set in declaration array
-				for _, dependentValue := range dependentValues {
-					_, err = tx.Exec("INSERT INTO cn_tparamvalue_tparamvalue(tparam_id,value,dependent_tparam_id, dependent_value) VALUES(?,?,?,?)",
-						id, value.Value, dependendParamID, dependentValue)
-					if err != nil {
-						return
-					}
-				}
-			}
-		}
+// Meta - значение из таблицы meta, если значение не задано - значение по умолчанию из MetaValues
+func Meta(key string) (value string, err error) {
+	err = DB.QueryRow("SELECT value FROM meta WHERE key=?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return MetaValues[key], nil
 	}
+	return
+}
 
-	// [tresult]
-	for id, tresult := range calc.Results {
-		_, err = tx.Exec("INSERT INTO tresult(id,name,description) VALUES(?,?,?)", id, tresult.Name, tresult.Description)
-		if err != nil {
-			return
-		}
+// SetMeta - set the value of the meta key
+func SetMeta(tx *sql.Tx, key, value string) (err error) {
+	_, err = tx.Exec("INSERT OR REPLACE INTO meta(key, value) VALUES(?, ?)", key, value)
+	return
+}
+
+// createDB - create new DB with actual version
+func createDB(db *sql.DB) (err error) {
+	// Start transaction
+	tx, err := db.Begin()
+	if err != nil {
+		return
 	}
 
-	// [tcalculation]
-	for id, tcalc := range calc.MaterialCalculations {
-		_, err = tx.Exec("INSERT INTO tcalculation(id,name) VALUES(?,?)", id, tcalc.Name)
+	defer tx.Commit()
+
+	// Create empty tables
+	for _, sqlQuery := range sqlDeclarations {
+		_, err = tx.Exec(sqlQuery)
 		if err != nil {
 			return
 		}
 	}
 
-	// [tcomponent] [tpart]
-	for id, tcomp := range calc.Components {
-		_, err = tx.Exec("INSERT INTO tcomponent(id, name) VALUES(?, ?)", id, tcomp.Name)
+	// Fill the tables with constant enums
+	// [meta]
+	for key, value := range MetaValues {
+		_, err = tx.Exec("INSERT INTO meta(key,value) VALUES(?,?)", key, value)
 		if err != nil {
 			return
 		}
-		for _, tpart := range tcomp.Parts {
-			_, err = tx.Exec("INSERT INTO tpart(tcomponent_id, name, tcalculation_id) VALUES(?,?,?)", id, tpart.Name, tpart.MC)
-			if err != nil {
-				return
-			}
-		}
 	}
 
-	// [cn_tregion_tcomponent] [cn_tparam_tregion]
-	for id, tregion := range calc.Regions {
-		for _, compID := range tregion.Components {
-			_, err = tx.Exec("INSERT INTO cn_tregion_tcomponent(tregion_id,tcomponent_id) VALUES(?,?)", id, compID)
-			if err != nil {
-				return
-			}
-
-			c := calc.Components[compID]
-			for _, paramID := range c.Params {
-				_, err = tx.Exec("INSERT INTO cn_tparam_tregion(tparam_id,tregion_id,tcomponent_id) VALUES(?,?,?)", paramID, id, compID)
-				if err != nil {
-					return
-				}
-			}
-		}
+	// [tregion] [tparam] [tresult] [tcalculation] [tcomponent] [tpart] [color] ...
+	err = syncEnums(tx)
+	if err != nil {
+		return
 	}
 
-	// [color]
-	for _, cs := range calc.ColorSchemes {
-		var res sql.Result
-		res, err = tx.Exec("INSERT INTO color_scheme(name) VALUES(?)", cs.Name)
-		if err != nil {
-			return
-		}
-		id, _ := res.LastInsertId()
-
-		for _, color := range cs.Colors {
-			_, err = tx.Exec("INSERT INTO color(name,color_scheme_id,value) VALUES(?,?,?)", color.Name, id, color.Value)
-			if err != nil {
-				return
-			}
-		}
+	// Default user to login into the new DB and add other users, the random password is shown once and should be changed
+	var password, hash string
+	password, err = RandomPassword()
+	if err != nil {
+		return
 	}
-
-	// Default test user, use this user everywhere until the user login system implemented
-	_, err = tx.Exec("INSERT INTO user(login,name,phone,position,comment) VALUES(?,?,?,?,?)", "coder", "Test Coder", "+7 923 241-44-42", "coder", "Default test user for the testing period until user login system implemented.")
+	hash, err = HashPassword(password)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec("INSERT INTO user(login,name,phone,position,comment,password_hash,admin) VALUES(?,?,?,?,?,?,1)", "coder", "Test Coder", "+7 923 241-44-42", "coder", "Default user of the new DB, change the password after the first login.", hash)
 	if err != nil {
 		return
 	}
+	fmt.Printf("New DB is created. Login: 'coder', password: '%s'. Change the password after the first login.\n", password)
 
 	return
 }
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// AddComponents - add components of the given types with all their parts to the region
+// Only component types available for the region type are added, components already existing in the region are skipped
+// Nomenclature of the new parts is set to default (min of possible values) and corrected according to the region params
+// If componentTypeIDs is nil, all the component types of the region type are added
+// Runs in the transaction of the caller
+//
+func AddComponents(tx *sql.Tx, regionID int64, componentTypeIDs []int64) (err error) {
+	filter := ""
+	if componentTypeIDs != nil {
+		filter = "AND cn.tcomponent_id IN (" + idList(componentTypeIDs) + ")"
+	}
+
+	_, err = tx.Exec(`INSERT INTO component(region_id, tcomponent_id)
+		SELECT r.id, cn.tcomponent_id FROM region r
+		INNER JOIN cn_tregion_tcomponent cn ON cn.tregion_id = r.tregion_id
+		WHERE r.id = ? `+filter+`
+		AND cn.tcomponent_id NOT IN (SELECT tcomponent_id FROM component WHERE region_id = r.id)`, regionID)
+	if err != nil {
+		return
+	}
+
+	// Add all the parts of new components, set default value of nomenclature for each part (AS SELECT min from possible values)
+	_, err = tx.Exec(`INSERT INTO part(tpart_id, component_id, nomenclature_id)
+		SELECT p.id, c.id, min(cn.nomenclature_id)
+		FROM component c
+		INNER JOIN tpart p ON c.tcomponent_id = p.tcomponent_id
+		LEFT JOIN cn_tpart_nomenclature cn ON p.id = cn.tpart_id
+		WHERE c.region_id = ? AND c.id NOT IN (SELECT component_id FROM part)
+		GROUP BY p.id, c.id`, regionID)
+	if err != nil {
+		return
+	}
+
+	// Correct dependent param and part values
+	var resParams map[int64]DBParamValue
+	var resParts map[int64]DBPartNomenclatureValue
+	resParams, resParts, err = GetParamPartValues(tx, regionID, map[int64]float64{}, map[int64]int64{})
+	if err != nil {
+		return
+	}
+	return WriteParamPartValues(tx, regionID, resParams, resParts)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// DeleteComponents - delete components of the given types with all their parts from the region
+// Runs in the transaction of the caller
+//
+func DeleteComponents(tx *sql.Tx, regionID int64, componentTypeIDs []int64) (err error) {
+	if len(componentTypeIDs) == 0 {
+		return
+	}
+	_, err = tx.Exec(`DELETE FROM component WHERE region_id=? AND tcomponent_id IN (`+idList(componentTypeIDs)+`)`, regionID)
+	return
+}
+
+// idList - list of IDs for SQL IN (...), "NULL" for empty list
+func idList(ids []int64) string {
+	if len(ids) == 0 {
+		return "NULL"
+	}
+	var list string
+	for i, id := range ids {
+		if i > 0 {
+			list += ","
+		}
+		list += fmt.Sprintf("%d", id)
+	}
+	return list
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// ComponentTypesOfRegion - types of all the components of the region
+//
+func ComponentTypesOfRegion(q Querier, regionID int64) (ids []int64, err error) {
+	var rows *sql.Rows
+	rows, err = q.Query("SELECT tcomponent_id FROM component WHERE region_id=? ORDER BY id", regionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,549 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"knx/calc"
+)
+
+// migration - step of the DB conversion from the version From to the version To
+// The statements of the step and the update of the version run in one transaction
+type migration struct {
+	From, To string
+	SQL      []string
+
+	// Changes of the data which can not be done by SQL, run after SQL in the same transaction
+	Func func(tx *sql.Tx) error
+
+	// The step recreates tables: foreign keys are turned off for the step, else dropping the old table
+	// deletes the rows which refer to it. References are checked by PRAGMA foreign_key_check before the commit
+	Rebuild bool
+}
+
+// migrations - steps of the DB conversion in the order of versions, the last step converts to MetaValues[MetaKeyVersion]
+// The statements of a step are the schema of its time, they are not changed when the schema changes later:
+// a new version of the schema needs a new step
+// Versions: the date of the release of the schema, the steps of the same day are numbered after it: <date>.<n>
+// The constant enums from calc are synchronized after the last step, so the steps without the schema changes have no statements
+var migrations = []migration{
+	{From: "2018-05-13", To: "2026-10-18"},
+
+	{From: "2026-10-18", To: "2026-10-18.1", SQL: []string{
+		`ALTER TABLE nomenclature ADD COLUMN width FLOAT NOT NULL DEFAULT 0`,
+	}},
+
+	{From: "2026-10-18.1", To: "2026-10-18.2"},
+
+	{From: "2026-10-18.2", To: "2026-10-18.3", SQL: []string{
+		`ALTER TABLE tparamvalue ADD COLUMN size FLOAT NOT NULL DEFAULT 0`,
+	}},
+
+	{From: "2026-10-18.3", To: "2026-10-18.4"},
+
+	{From: "2026-10-18.4", To: "2026-10-18.5", SQL: []string{
+		`ALTER TABLE tpart ADD COLUMN formula TEXT NOT NULL DEFAULT ''`,
+	}},
+
+	{From: "2026-10-18.5", To: "2026-10-18.6"},
+
+	{From: "2026-10-18.6", To: "2026-10-18.7", SQL: []string{
+		`CREATE TABLE segment (
+    id              INTEGER PRIMARY KEY,
+    region_id       INTEGER REFERENCES region(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    nr              INTEGER NOT NULL DEFAULT 0,
+    length          FLOAT NOT NULL DEFAULT 0,
+    angle           FLOAT NOT NULL DEFAULT 0,
+    elevation       FLOAT NOT NULL DEFAULT 0 )`,
+	}},
+
+	{From: "2026-10-18.7", To: "2026-10-18.8"},
+
+	{From: "2026-10-18.8", To: "2026-10-18.9", SQL: []string{
+		`CREATE TABLE stock (
+    id              INTEGER PRIMARY KEY,
+    nomenclature_id INTEGER REFERENCES nomenclature(id) NOT NULL,
+    date            DATETIME NOT NULL,
+    quantity        FLOAT NOT NULL DEFAULT 0,
+    project_id      INTEGER REFERENCES project(id) ON DELETE SET NULL,
+    comment         TEXT NOT NULL DEFAULT '' )`,
+
+		`CREATE INDEX idx_stock_nomenclature ON stock(nomenclature_id)`,
+
+		`CREATE TABLE reservation (
+    project_id      INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    nomenclature_id INTEGER REFERENCES nomenclature(id) NOT NULL,
+    date            DATETIME NOT NULL,
+    quantity        FLOAT NOT NULL DEFAULT 0,
+    UNIQUE(project_id, nomenclature_id) )`,
+	}},
+
+	{From: "2026-10-18.9", To: "2026-10-18.10", SQL: []string{
+		`ALTER TABLE project ADD COLUMN status TEXT NOT NULL DEFAULT 'draft'`,
+
+		`CREATE INDEX idx_project_status ON project(status)`,
+
+		`CREATE TABLE project_status (
+    id            INTEGER PRIMARY KEY,
+    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    status        TEXT NOT NULL DEFAULT '',
+    date          DATETIME NOT NULL,
+    user_id       INTEGER REFERENCES user(id) NOT NULL)`,
+
+		`ALTER TABLE result ADD COLUMN price_date DATETIME`,
+		`ALTER TABLE result ADD COLUMN price INTEGER`,
+		`ALTER TABLE result ADD COLUMN cost_price INTEGER`,
+	}},
+
+	// The region belongs to the project or to the version of the project: project_id is nullable now
+	{From: "2026-10-18.10", To: "2026-10-18.11", Rebuild: true, SQL: []string{
+		`CREATE TABLE project_version (
+    id            INTEGER PRIMARY KEY,
+    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+    name          TEXT NOT NULL DEFAULT '',
+    date          DATETIME NOT NULL,
+    user_id       INTEGER REFERENCES user(id) NOT NULL,
+    comment       TEXT NOT NULL DEFAULT '',
+    UNIQUE(project_id, name))`,
+
+		`ALTER TABLE project ADD COLUMN version_id INTEGER REFERENCES project_version(id) ON DELETE SET NULL`,
+
+		`CREATE TABLE region_new (
+    id            INTEGER PRIMARY KEY,
+    description   TEXT NOT NULL DEFAULT '',
+    project_id    INTEGER REFERENCES project(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    version_id    INTEGER REFERENCES project_version(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    tregion_id    INTEGER REFERENCES tregion(id) NOT NULL,
+    nr            INTEGER NOT NULL DEFAULT 0,
+    CHECK((project_id IS NULL) <> (version_id IS NULL)))`,
+
+		`INSERT INTO region_new(id, description, project_id, tregion_id, nr)
+    SELECT id, description, project_id, tregion_id, nr FROM region`,
+
+		`DROP TABLE region`,
+
+		`ALTER TABLE region_new RENAME TO region`,
+	}},
+
+	{From: "2026-10-18.11", To: "2026-10-18.12", SQL: []string{
+		`ALTER TABLE user ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''`,
+
+		`CREATE TABLE session (
+    token_hash    TEXT PRIMARY KEY,
+    user_id       INTEGER REFERENCES user(id) ON DELETE CASCADE NOT NULL,
+    created       TEXT NOT NULL,
+    expires       TEXT NOT NULL)`,
+
+		`CREATE INDEX idx_session_expires ON session(expires)`,
+	}},
+
+	// Users are changed by administrators, the first user of the existing DB becomes the administrator
+	{From: "2026-10-18.12", To: "2026-10-18.13", SQL: []string{
+		`ALTER TABLE user ADD COLUMN admin INTEGER NOT NULL DEFAULT 0`,
+
+		`UPDATE user SET admin=1 WHERE id=(SELECT MIN(id) FROM user)`,
+	}},
+
+	// The default user had the known password 'coder', administrators of the DB created before the login system had no password
+	{From: "2026-10-18.13", To: "2026-10-18.14", Func: replaceDefaultPasswords},
+
+	// Part types with the unregistered calculation 1 (painting of the area) get the painting calculation of their component:
+	// columns (1) - 3, horizontal sticks (2) - 4, filling (3) - 5, the others are not calculated
+	// The painting calculations are added here: the enums are synchronized only after the last step
+	{From: "2026-10-18.14", To: "2026-10-18.15", SQL: []string{
+		`INSERT OR IGNORE INTO tcalculation(id, name) VALUES(3, 'Покраска столбов'), (4, 'Покраска прожилин'), (5, 'Покраска полотна')`,
+
+		`UPDATE tpart SET tcalculation_id = CASE tcomponent_id WHEN 1 THEN 3 WHEN 2 THEN 4 WHEN 3 THEN 5 ELSE 0 END
+    WHERE tcalculation_id=1`,
+
+		`DELETE FROM tcalculation WHERE id=1`,
+	}},
+
+	// Paint consumption is the parameter of the painted components, existing regions get it with the default value
+	{From: "2026-10-18.15", To: "2026-10-18.16", Func: addRegionParams},
+
+	// Region types of the brick and welded fences: their enums are added by syncEnums at the last step
+	{From: "2026-10-18.16", To: "2026-10-18.17"},
+}
+
+// convertDB - convert DB from one version to another by the steps of migrations
+// Each step is committed separately, so the conversion interrupted by an error continues from the failed step
+func convertDB(db *sql.DB, sourceVersion string, targetVersion string) (err error) {
+	start := -1
+	for i, m := range migrations {
+		if m.From == sourceVersion {
+			start = i
+			break
+		}
+	}
+	if start < 0 || migrations[len(migrations)-1].To != targetVersion {
+		return fmt.Errorf("DB has unsupportable version '%s'. Actual version should be '%s'. Converting DB is not supported!",
+			sourceVersion, targetVersion)
+	}
+
+	// PRAGMA foreign_keys works only outside of a transaction and only for its connection, so all the steps use one connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for i, m := range migrations[start:] {
+		err = migrate(ctx, conn, m, start+i == len(migrations)-1)
+		if err != nil {
+			return fmt.Errorf("Converting DB from version '%s' to '%s' failed: %v", m.From, m.To, err)
+		}
+	}
+	return
+}
+
+// migrate - run the step of the DB conversion, last - the step to the actual version
+func migrate(ctx context.Context, conn *sql.Conn, m migration, last bool) (err error) {
+	if m.Rebuild {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys=OFF")
+		if err != nil {
+			return
+		}
+		defer func() {
+			_, errOn := conn.ExecContext(ctx, "PRAGMA foreign_keys=ON")
+			if err == nil {
+				err = errOn
+			}
+		}()
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	// References are checked at the commit: the statements may refer to the rows of constant enums added by syncEnums
+	_, err = tx.Exec("PRAGMA defer_foreign_keys=ON")
+	if err != nil {
+		return
+	}
+
+	for _, sqlQuery := range m.SQL {
+		_, err = tx.Exec(sqlQuery)
+		if err != nil {
+			return
+		}
+	}
+
+	if m.Func != nil {
+		err = m.Func(tx)
+		if err != nil {
+			return
+		}
+	}
+
+	if m.Rebuild {
+		var rows *sql.Rows
+		rows, err = tx.Query("PRAGMA foreign_key_check")
+		if err != nil {
+			return
+		}
+		violated := rows.Next()
+		rows.Close()
+		if violated {
+			return fmt.Errorf("foreign key check failed after recreating tables")
+		}
+	}
+
+	if last {
+		// New meta keys with default values, existing values are kept
+		for key, value := range MetaValues {
+			_, err = tx.Exec("INSERT OR IGNORE INTO meta(key,value) VALUES(?,?)", key, value)
+			if err != nil {
+				return
+			}
+		}
+
+		err = syncEnums(tx)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE meta SET value=? WHERE key=?", m.To, MetaKeyVersion)
+	return
+}
+
+// replaceDefaultPasswords - administrators without a password (DB created before the login system) or with the known
+// password 'coder' of the default user get the random password, it is shown once
+func replaceDefaultPasswords(tx *sql.Tx) (err error) {
+	type user struct {
+		id    int64
+		login string
+	}
+	var users []user
+
+	rows, err := tx.Query("SELECT id, login, password_hash FROM user WHERE admin=1")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u user
+		var hash string
+		err = rows.Scan(&u.id, &u.login, &hash)
+		if err != nil {
+			return
+		}
+		if hash == "" || CheckPassword(hash, "coder") {
+			users = append(users, u)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	for _, u := range users {
+		var password, hash string
+		password, err = RandomPassword()
+		if err != nil {
+			return
+		}
+		hash, err = HashPassword(password)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec("UPDATE user SET password_hash=? WHERE id=?", hash, u.id)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec("DELETE FROM session WHERE user_id=?", u.id)
+		if err != nil {
+			return
+		}
+		fmt.Printf("The password of the administrator '%s' is set, new password: '%s'. Change the password after the login.\n", u.login, password)
+	}
+	return
+}
+
+// addRegionParams - add the parameters of the region types missing in the existing regions, with the default values
+// as for the new region: the minimum of the possible values or 0. The enums are synchronized first to get the new parameters
+func addRegionParams(tx *sql.Tx) (err error) {
+	err = syncEnums(tx)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec(`INSERT INTO param(region_id, tparam_id, value)
+		SELECT r.id, p.tparam_id, ifnull(min(v.value), 0)
+		FROM region r
+		INNER JOIN cn_tparam_tregion p ON p.tregion_id = r.tregion_id
+		LEFT JOIN tparamvalue v ON v.tparam_id = p.tparam_id
+		WHERE NOT EXISTS(SELECT 1 FROM param WHERE region_id = r.id AND tparam_id = p.tparam_id)
+		GROUP BY r.id, p.tparam_id`)
+	return
+}
+
+// syncEnums - fill the tables of constant enums from calc: the new DB gets all the rows, the converted DB gets new
+// rows and changes of the existing ones. Rows absent in calc are not deleted, the user data may refer to them
+// tpart and color have no constant ids and are matched by name, existing part types are edited by users,
+// so only the part types without any calculation get the calculation and the formula from calc
+func syncEnums(tx *sql.Tx) (err error) {
+	// [tregion]
+	for id, tregion := range calc.Regions {
+		err = syncEnumRow(tx, "tregion", int64(id), []string{"name"}, tregion.Name)
+		if err != nil {
+			return
+		}
+	}
+
+	// [tparam] [tparamvalue] [cn_tparamvalue_tparamvalue]
+	// Values and dependencies are filled again: priorities are checked by the trigger at the insert of dependencies
+	for _, table := range []string{"cn_tparamvalue_tparamvalue", "tparamvalue"} {
+		_, err = tx.Exec("DELETE FROM " + table)
+		if err != nil {
+			return
+		}
+	}
+
+	for id, tparam := range calc.Params {
+		err = syncEnumRow(tx, "tparam", int64(id), []string{"prio", "name", "description"}, tparam.Prio, tparam.Name, tparam.Description)
+		if err != nil {
+			return
+		}
+
+		for _, value := range tparam.Values {
+
+			// For color param declaration: use colors from color scheme as values
+			if value.Name == calc.ColorParamName {
+				cs := calc.ColorSchemes[int(value.Value)]
+				for _, color := range cs.Colors {
+					_, err = tx.Exec("INSERT INTO tparamvalue(tparam_id,value,name) VALUES(?,?,?)", id, color.Value, color.Name)
+					if err != nil {
+						return
+					}
+				}
+				continue
+			}
+
+			_, err = tx.Exec("INSERT INTO tparamvalue(tparam_id,value,name,size) VALUES(?,?,?,?)", id, value.Value, value.Name, value.Size)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	for id, tparam := range calc.Params {
+		for _, value := range tparam.Values {
+			for dependendParamID, dependentValues := range value.DependentParams {
+				// For empty value list add -1 means value is not set and is not available to choose
+				if len(dependentValues) == 0 {
+					_, err = tx.Exec("INSERT INTO cn_tparamvalue_tparamvalue(tparam_id,value,dependent_tparam_id, dependent_value) VALUES(?,?,?,?)",
+						id, value.Value, dependendParamID, -1)
+					if err != nil {
+						return
+					}
+				}
+				// Add all the values, manually set in declaration array
+				for _, dependentValue := range dependentValues {
+					_, err = tx.Exec("INSERT INTO cn_tparamvalue_tparamvalue(tparam_id,value,dependent_tparam_id, dependent_value) VALUES(?,?,?,?)",
+						id, value.Value, dependendParamID, dependentValue)
+					if err != nil {
+						return
+					}
+				}
+			}
+		}
+	}
+
+	// [tresult]
+	for id, tresult := range calc.Results {
+		err = syncEnumRow(tx, "tresult", int64(id), []string{"name", "description"}, tresult.Name, tresult.Description)
+		if err != nil {
+			return
+		}
+	}
+
+	// [tcalculation]
+	for id, tcalc := range calc.MaterialCalculations {
+		err = syncEnumRow(tx, "tcalculation", int64(id), []string{"name"}, tcalc.Name)
+		if err != nil {
+			return
+		}
+	}
+
+	// [tcomponent] [tpart] [cn_tparam_tpart]
+	for id, tcomp := range calc.Components {
+		err = syncEnumRow(tx, "tcomponent", int64(id), []string{"name"}, tcomp.Name)
+		if err != nil {
+			return
+		}
+		for _, tpart := range tcomp.Parts {
+			if tpart.Formula != "" {
+				_, err = calc.ParseFormula(tpart.Formula)
+				if err != nil {
+					err = fmt.Errorf("Ошибка в формуле части '%s' компонента '%s': %v", tpart.Name, tcomp.Name, err)
+					return
+				}
+			}
+
+			var tpartID int64
+			err = tx.QueryRow("SELECT id FROM tpart WHERE tcomponent_id=? AND name=?", id, tpart.Name).Scan(&tpartID)
+			if err == sql.ErrNoRows {
+				var res sql.Result
+				res, err = tx.Exec("INSERT INTO tpart(tcomponent_id, name, tcalculation_id, formula) VALUES(?,?,?,?)", id, tpart.Name, tpart.MC, tpart.Formula)
+				if err != nil {
+					return
+				}
+				tpartID, _ = res.LastInsertId()
+			} else if err == nil {
+				_, err = tx.Exec("UPDATE tpart SET tcalculation_id=?, formula=? WHERE id=? AND IFNULL(tcalculation_id, 0)=0 AND formula=''",
+					tpart.MC, tpart.Formula, tpartID)
+			}
+			if err != nil {
+				return
+			}
+
+			for _, paramID := range tpart.Params {
+				_, err = tx.Exec("INSERT OR IGNORE INTO cn_tparam_tpart(tparam_id,tpart_id) VALUES(?,?)", paramID, tpartID)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	// [cn_tregion_tcomponent] [cn_tparam_tregion]
+	for _, table := range []string{"cn_tregion_tcomponent", "cn_tparam_tregion"} {
+		_, err = tx.Exec("DELETE FROM " + table)
+		if err != nil {
+			return
+		}
+	}
+	for id, tregion := range calc.Regions {
+		for _, compID := range tregion.Components {
+			_, err = tx.Exec("INSERT INTO cn_tregion_tcomponent(tregion_id,tcomponent_id) VALUES(?,?)", id, compID)
+			if err != nil {
+				return
+			}
+
+			c := calc.Components[compID]
+			for _, paramID := range c.Params {
+				_, err = tx.Exec("INSERT INTO cn_tparam_tregion(tparam_id,tregion_id,tcomponent_id) VALUES(?,?,?)", paramID, id, compID)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	// [color_scheme] [color]
+	for _, cs := range calc.ColorSchemes {
+		var id int64
+		err = tx.QueryRow("SELECT id FROM color_scheme WHERE name=?", cs.Name).Scan(&id)
+		if err == sql.ErrNoRows {
+			var res sql.Result
+			res, err = tx.Exec("INSERT INTO color_scheme(name) VALUES(?)", cs.Name)
+			if err != nil {
+				return
+			}
+			id, _ = res.LastInsertId()
+		}
+		if err != nil {
+			return
+		}
+
+		for _, color := range cs.Colors {
+			_, err = tx.Exec(`INSERT INTO color(name,color_scheme_id,value) SELECT ?,?,?
+    WHERE NOT EXISTS (SELECT 1 FROM color WHERE color_scheme_id=? AND name=?)`, color.Name, id, color.Value, id, color.Name)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// syncEnumRow - insert the row of the constant enum with the id or update the existing row
+func syncEnumRow(tx *sql.Tx, table string, id int64, columns []string, values ...interface{}) (err error) {
+	_, err = tx.Exec("INSERT OR IGNORE INTO "+table+"(id) VALUES(?)", id)
+	if err != nil {
+		return
+	}
+
+	sqlText := "UPDATE " + table + " SET "
+	for i, column := range columns {
+		if i > 0 {
+			sqlText += ", "
+		}
+		sqlText += column + "=?"
+	}
+	_, err = tx.Exec(sqlText+" WHERE id=?", append(values, id)...)
+	return
+}
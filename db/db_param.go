@@ -19,8 +19,9 @@ type DBPartNomenclatureValue struct {
 // GetParamPartValues - return params dependent on the given param values, return nomenclature for parts, dependent on the given param values
 // key of nomenclature value is an ID of part type
 // Input map is local param values entered by user to replace values from DB
+// q is DB or the transaction of the caller, if the region is changed in it
 //
-func GetParamPartValues(regionID int64, localParams map[int64]float64, localParts map[int64]int64) (resParams map[int64]DBParamValue, resParts map[int64]DBPartNomenclatureValue, err error) {
+func GetParamPartValues(q Querier, regionID int64, localParams map[int64]float64, localParts map[int64]int64) (resParams map[int64]DBParamValue, resParts map[int64]DBPartNomenclatureValue, err error) {
 	resParams = make(map[int64]DBParamValue)
 	resParts = make(map[int64]DBPartNomenclatureValue)
 
@@ -35,7 +36,7 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 
 	// Get all the parameters of this region from DB
 	var rows *sql.Rows
-	rows, err = DB.Query(`SELECT t.prio, p.tparam_id, p.value FROM param p
+	rows, err = q.Query(`SELECT t.prio, p.tparam_id, p.value FROM param p
 		INNER JOIN tparam t ON t.id = p.tparam_id
 		WHERE region_id=? ORDER BY t.prio`, regionID)
 	if err != nil {
@@ -84,7 +85,7 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 
 			// Get count of dependencies
 			var row *sql.Row
-			row = DB.QueryRow(`SELECT count(*) FROM cn_tparamvalue_tparamvalue
+			row = q.QueryRow(`SELECT count(*) FROM cn_tparamvalue_tparamvalue
 				WHERE tparam_id=? AND value=? AND dependent_tparam_id=?`, mParam.ParamTypeID, mParam.Value, param.ParamTypeID)
 			var countOfDepValues int
 			err = row.Scan(&countOfDepValues)
@@ -104,7 +105,7 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 
 		sqlSelect := fmt.Sprintf(sqlFormat, sqlJoin)
 
-		rows, err = DB.Query(sqlSelect, param.ParamTypeID)
+		rows, err = q.Query(sqlSelect, param.ParamTypeID)
 		if err != nil {
 			return
 		}
@@ -154,7 +155,7 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 
 	// Get all the part with set nomenclature from DB
 	// Replace given part with local nomenclature
-	rows, err = DB.Query(`SELECT p.tpart_id, p.nomenclature_id
+	rows, err = q.Query(`SELECT p.tpart_id, p.nomenclature_id
 		FROM part p INNER JOIN component c ON c.id = p.component_id
 		WHERE c.region_id=?`, regionID)
 	if err != nil {
@@ -194,7 +195,7 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 		for mI, mParam := range regionParams {
 			// Get count of dependencies
 			var row *sql.Row
-			row = DB.QueryRow(`SELECT count(*) FROM cn_tparamvalue_nomenclature n
+			row = q.QueryRow(`SELECT count(*) FROM cn_tparamvalue_nomenclature n
 				INNER JOIN cn_tparam_tpart p ON p.tparam_id=n.tparam_id
 				WHERE n.tparam_id=? AND n.value=? AND p.tpart_id=?`, mParam.ParamTypeID, mParam.Value, tpartID)
 			var countOfDepValues int
@@ -214,7 +215,7 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 		}
 
 		sqlSelect := fmt.Sprintf(sqlFormat, sqlJoin)
-		rows, err = DB.Query(sqlSelect, tpartID)
+		rows, err = q.Query(sqlSelect, tpartID)
 		if err != nil {
 			return
 		}
@@ -268,24 +269,9 @@ func GetParamPartValues(regionID int64, localParams map[int64]float64, localPart
 
 ///////////////////////////////////////////////////////////////////////////////
 // WriteParamPartValues - write into db values of parameters and parts for the given region
+// Runs in the transaction of the caller
 //
-func WriteParamPartValues(regionID int64, params map[int64]DBParamValue, parts map[int64]DBPartNomenclatureValue) (err error) {
-	// Begin transaction
-	var tx *sql.Tx
-	tx, err = DB.Begin()
-	if err != nil {
-		return
-	}
-
-	// Commit or rollback transaction at the end
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
-		}
-	}()
-
+func WriteParamPartValues(tx *sql.Tx, regionID int64, params map[int64]DBParamValue, parts map[int64]DBPartNomenclatureValue) (err error) {
 	// Param values
 	for tparamID, paramValue := range params {
 		_, err = tx.Exec("UPDATE param SET value=? WHERE region_id=? AND tparam_id=?", paramValue.Value, regionID, tparamID)
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"knx/calc"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CheckPartFormula - check that the part types referenced by the formula f of part type partTypeID exist
+// and that the formulas of part types do not reference each other in a loop
+// partTypeID is 0 for a new part type, nobody can reference it yet
+//
+func CheckPartFormula(partTypeID int64, f *calc.Formula) (err error) {
+	if f == nil {
+		return
+	}
+
+	// Part types referenced by the formulas of all the part types
+	exists := make(map[int64]bool)
+	refs := make(map[int64][]int64)
+
+	var rows *sql.Rows
+	rows, err = DB.Query("SELECT id, formula FROM tpart")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var formula string
+		err = rows.Scan(&id, &formula)
+		if err != nil {
+			return
+		}
+		exists[id] = true
+		if formula == "" || id == partTypeID {
+			continue
+		}
+		// Formulas are validated on save, so a broken formula may only come from direct DB editing: ignore it here
+		if pf, perr := calc.ParseFormula(formula); perr == nil {
+			refs[id] = pf.Parts
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	refs[partTypeID] = f.Parts
+
+	for _, id := range f.Parts {
+		if !exists[id] {
+			return fmt.Errorf("Тип части '%d', указанный в формуле, не существует", id)
+		}
+	}
+
+	// Depth-first search for a loop starting at the changed part type
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[int64]int)
+	var visit func(id int64) error
+	visit = func(id int64) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("Формула типа части '%d' циклически ссылается на тип части '%d'", partTypeID, id)
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		for _, ref := range refs[id] {
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		return nil
+	}
+	return visit(partTypeID)
+}
@@ -0,0 +1,73 @@
+package db
+
+import "database/sql"
+
+// Project statuses
+const (
+	ProjectDraft      = "draft"
+	ProjectQuoted     = "quoted"
+	ProjectContracted = "contracted"
+	ProjectScheduled  = "scheduled"
+	ProjectInstalled  = "installed"
+	ProjectClosed     = "closed"
+	ProjectCancelled  = "cancelled"
+)
+
+// ProjectStatuses - all the project statuses in the order of the workflow
+var ProjectStatuses []string = []string{ProjectDraft, ProjectQuoted, ProjectContracted, ProjectScheduled,
+	ProjectInstalled, ProjectClosed, ProjectCancelled}
+
+// ProjectTransitions - allowed transitions from the status of the project to the next ones
+var ProjectTransitions map[string][]string = map[string][]string{
+	ProjectDraft:      {ProjectQuoted, ProjectCancelled},
+	ProjectQuoted:     {ProjectDraft, ProjectContracted, ProjectCancelled},
+	ProjectContracted: {ProjectScheduled, ProjectCancelled},
+	ProjectScheduled:  {ProjectInstalled, ProjectCancelled},
+	ProjectInstalled:  {ProjectClosed},
+	ProjectClosed:     {},
+	ProjectCancelled:  {},
+}
+
+// ProjectFrozenSQL - condition on the project p having frozen quantities and prices:
+// since the contract the regions of the project are not changed and not recalculated
+const ProjectFrozenSQL = `p.status IN ('contracted', 'scheduled', 'installed', 'closed')`
+
+// PriceDateSQL - date of the prices of the project p: the contract date or the current date if there is no contract
+const PriceDateSQL = `ifnull(date(p.contract_date), date('now'))`
+
+// CanChangeProjectStatus - check if the project may be moved from one status to another
+func CanChangeProjectStatus(from, to string) bool {
+	for _, s := range ProjectTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// SetProjectStatus - move the project to the new status and record the transition with the user and the time
+// Also used for the first status of the new project
+// When the project is contracted, the prices at the price date are saved in the results of the project
+// Runs in the transaction of the caller together with the other changes of the project
+//
+func SetProjectStatus(tx *sql.Tx, projectID int64, status string, userID int64) (err error) {
+	_, err = tx.Exec("UPDATE project SET status=? WHERE id=?", status, projectID)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec("INSERT INTO project_status(project_id, status, date, user_id) VALUES(?, ?, datetime('now'), ?)",
+		projectID, status, userID)
+	if err != nil || status != ProjectContracted {
+		return
+	}
+
+	err = savePrices(tx, projectID, RegionsOfProject, projectID)
+	return
+}
+
+// ProjectFrozen - check if quantities and prices of the project are frozen
+func ProjectFrozen(projectID int64) (frozen bool, err error) {
+	err = DB.QueryRow(`SELECT count(*) > 0 FROM project p WHERE p.id=? AND `+ProjectFrozenSQL, projectID).Scan(&frozen)
+	return
+}
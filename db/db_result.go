@@ -0,0 +1,279 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"knx/calc"
+)
+
+// ParseDivision - convert text list of stock lengths "<value>,<value>,..." of nomenclature into numbers
+// Values which can not be parsed are skipped
+func ParseDivision(division string) (lengths []float64) {
+	for _, s := range strings.Split(division, ",") {
+		l, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			continue
+		}
+		lengths = append(lengths, l)
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CalculateRegion - run material calculations of all the parts of the region and replace results of the region in DB
+// Runs in the transaction of the caller: the region is read and the results are written together with the changes of the region
+//
+func CalculateRegion(tx *sql.Tx, regionID int64) (err error) {
+	data, parts, err := regionData(tx, regionID)
+	if err != nil {
+		return
+	}
+
+	// Run calculations of all the parts, the results of one part may be used by the others
+	var values [][]float64
+	values, _, err = calc.CalculateRegion(data)
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM result WHERE region_id=?", regionID)
+	if err != nil {
+		return
+	}
+
+	for i, p := range parts {
+		for j, value := range values[i] {
+			resultType, ok := data.Parts[i].ResultType(j)
+			if !ok {
+				break
+			}
+
+			// Only the quantity of the material is linked with nomenclature of the part
+			// and the count of cuts - with the cutting service of this nomenclature
+			var nomenclatureID *int64
+			switch resultType {
+			case calc.RSNomenclature:
+				nomenclatureID = p.NomenclatureID
+			case calc.RSDivisionService:
+				nomenclatureID = p.Nomenclature.DivisionServiceID
+			}
+
+			_, err = tx.Exec("INSERT INTO result(tresult_id, region_id, nomenclature_id, value) VALUES(?,?,?,?)",
+				resultType, regionID, nomenclatureID, value)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// RegionCutting - stock bars of the nomenclature of the region parts by the nomenclature id, as the calculation cuts them
+// The bars are not kept in DB, the calculation of the region is run again without writing the results
+//
+func RegionCutting(regionID int64) (bars map[int64][]calc.Bar, err error) {
+	data, parts, err := regionData(DB, regionID)
+	if err != nil {
+		return
+	}
+
+	var partBars [][]calc.Bar
+	_, partBars, err = calc.CalculateRegion(data)
+	if err != nil {
+		return
+	}
+
+	bars = make(map[int64][]calc.Bar)
+	for i, p := range parts {
+		if p.NomenclatureID != nil && len(partBars[i]) > 0 {
+			bars[*p.NomenclatureID] = append(bars[*p.NomenclatureID], partBars[i]...)
+		}
+	}
+	return
+}
+
+// regionData - input of the calculation of the region and nomenclature of its parts
+func regionData(q Querier, regionID int64) (data calc.RegionData, parts []regionPart, err error) {
+	// Get values of all the parameters of the region
+	data.Params, err = regionParams(q, regionID)
+	if err != nil {
+		return
+	}
+
+	// Get region type
+	err = q.QueryRow(`SELECT tregion_id FROM region WHERE id=?`, regionID).Scan(&data.RegionType)
+	if err != nil {
+		return
+	}
+
+	// Get segments of the region, the region without segments is one straight segment of the total length
+	data.Segments, err = regionSegments(q, regionID)
+	if err != nil {
+		return
+	}
+
+	// Get calculation type or formula and nomenclature of all the parts of the region
+	parts, data.Parts, err = regionParts(q, regionID)
+	return
+}
+
+// regionParams - values of all the parameters of the region
+func regionParams(q Querier, regionID int64) (params map[calc.ParamTypeID]float64, err error) {
+	params = make(map[calc.ParamTypeID]float64)
+
+	rows, err := q.Query(`SELECT tparam_id, value FROM param WHERE region_id=?`, regionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tparamID calc.ParamTypeID
+		var value float64
+		err = rows.Scan(&tparamID, &value)
+		if err != nil {
+			return
+		}
+		params[tparamID] = value
+	}
+	err = rows.Err()
+	return
+}
+
+// regionSegments - segments of the region in order
+func regionSegments(q Querier, regionID int64) (segments []calc.Segment, err error) {
+	rows, err := q.Query(`SELECT length, angle, elevation FROM segment WHERE region_id=? ORDER BY nr, id`, regionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s calc.Segment
+		err = rows.Scan(&s.Length, &s.Angle, &s.Elevation)
+		if err != nil {
+			return
+		}
+		segments = append(segments, s)
+	}
+	err = rows.Err()
+	return
+}
+
+// regionPart - nomenclature of the part of the region for the results
+type regionPart struct {
+	NomenclatureID *int64
+	Nomenclature   calc.Nomenclature
+}
+
+// regionParts - parts of the region having calculation or formula: nomenclature for the results and input of the calculations
+func regionParts(q Querier, regionID int64) (parts []regionPart, calcParts []calc.RegionPart, err error) {
+	rows, err := q.Query(`SELECT tp.id, ifnull(tp.tcalculation_id, 0), tp.formula, p.nomenclature_id, ifnull(n.size, 0), ifnull(n.width, 0),
+		ifnull(n.division, ''), n.division_service_nomenclature_id
+		FROM part p INNER JOIN component c ON c.id = p.component_id
+		INNER JOIN tpart tp ON tp.id = p.tpart_id
+		LEFT JOIN nomenclature n ON n.id = p.nomenclature_id
+		WHERE c.region_id=? AND (tp.tcalculation_id IS NOT NULL OR tp.formula <> '') ORDER BY c.id, p.id`, regionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p regionPart
+		var rp calc.RegionPart
+		var formula, division string
+		err = rows.Scan(&rp.PartTypeID, &rp.Calculation, &formula, &p.NomenclatureID, &p.Nomenclature.Size, &p.Nomenclature.Width,
+			&division, &p.Nomenclature.DivisionServiceID)
+		if err != nil {
+			return
+		}
+		p.Nomenclature.Division = ParseDivision(division)
+		if p.NomenclatureID != nil {
+			p.Nomenclature.ID = *p.NomenclatureID
+		}
+		rp.Nomenclature = p.Nomenclature
+
+		// Formula of the part is used instead of the calculation
+		if formula != "" {
+			rp.Formula, err = calc.ParseFormula(formula)
+			if err != nil {
+				err = fmt.Errorf("Ошибка в формуле типа части '%d': %v", rp.PartTypeID, err)
+				return
+			}
+		}
+
+		parts = append(parts, p)
+		calcParts = append(calcParts, rp)
+	}
+	err = rows.Err()
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CalculateRegionsOfPartType - recalculate all the regions having a part of the given type
+// Used when calculation or formula of the part type is changed
+//
+func CalculateRegionsOfPartType(partTypeID int64) error {
+	return calculateRegions(`SELECT DISTINCT c.region_id FROM component c
+		INNER JOIN part p ON p.component_id = c.id WHERE p.tpart_id=?`, partTypeID)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CalculateRegionsOfNomenclature - recalculate all the regions having a part with the given nomenclature
+// Used when size, width or stock lengths of the nomenclature are changed
+//
+func CalculateRegionsOfNomenclature(nomenclatureID int64) error {
+	return calculateRegions(`SELECT DISTINCT c.region_id FROM component c
+		INNER JOIN part p ON p.component_id = c.id WHERE p.nomenclature_id=?`, nomenclatureID)
+}
+
+// calculateRegions - recalculate all the regions selected by the query in one transaction
+// Regions of the projects with frozen quantities are skipped
+func calculateRegions(query string, args ...interface{}) (err error) {
+	var regions []int64
+
+	var rows *sql.Rows
+	rows, err = DB.Query(`SELECT g.id FROM region g INNER JOIN project p ON p.id = g.project_id
+		WHERE g.id IN (`+query+`) AND NOT `+ProjectFrozenSQL, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		regions = append(regions, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	var tx *sql.Tx
+	tx, err = DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, id := range regions {
+		err = CalculateRegion(tx, id)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"database/sql"
+	"knx/calc"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// UpdateRegionLength - set the total length parameter of the region to the sum of its segment lengths
+// The parameter is not changed for the region without segments
+// Runs in the transaction of the caller
+//
+func UpdateRegionLength(tx *sql.Tx, regionID int64) (err error) {
+	_, err = tx.Exec(`UPDATE param SET value=(SELECT sum(length) FROM segment WHERE region_id=?)
+		WHERE region_id=? AND tparam_id=? AND EXISTS(SELECT 1 FROM segment WHERE region_id=?)`,
+		regionID, regionID, calc.PTTotalLength, regionID)
+	return
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+	"knx/calc"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// ReserveProject - reserve the nomenclature of the project results on the stock
+// Quantities of the material results of the project regions are summed by nomenclature, services are not reserved,
+// the previous reservation of the project is replaced
+// Runs in the transaction of the caller, so the reservation follows the project status
+//
+func ReserveProject(tx *sql.Tx, projectID int64) (err error) {
+	_, err = tx.Exec("DELETE FROM reservation WHERE project_id=?", projectID)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec(`INSERT INTO reservation(project_id, nomenclature_id, date, quantity)
+		SELECT g.project_id, r.nomenclature_id, date('now'), sum(r.value)
+		FROM result r INNER JOIN region g ON g.id = r.region_id
+		WHERE g.project_id=? AND r.tresult_id=? AND r.nomenclature_id IS NOT NULL
+		GROUP BY r.nomenclature_id HAVING sum(r.value) > 0`, projectID, calc.RSNomenclature)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// ReleaseProject - remove the reservation of the project
+//
+func ReleaseProject(tx *sql.Tx, projectID int64) (err error) {
+	_, err = tx.Exec("DELETE FROM reservation WHERE project_id=?", projectID)
+	return
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionLifetime - the session is closed in this time after the login
+const SessionLifetime = 30 * 24 * time.Hour
+
+// Password hash: pbkdf2-sha256$<iterations>$<salt>$<hash>, salt and hash in base64
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 100000
+	passwordSaltLen    = 16
+)
+
+// ErrLogin - wrong login or password, the same error for both so as not to reveal existing logins
+var ErrLogin = errors.New("wrong login or password")
+
+// dummyHash - hash checked for the unknown login and the user without password,
+// the failed login takes the same time whether the login exists or not
+var dummyHash struct {
+	once sync.Once
+	hash string
+}
+
+// HashPassword - salted hash of the password to keep in user.password_hash
+func HashPassword(password string) (hash string, err error) {
+	salt := make([]byte, passwordSaltLen)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return
+	}
+	key := pbkdf2SHA256([]byte(password), salt, passwordIterations, sha256.Size)
+	hash = fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	return
+}
+
+// RandomPassword - random password for the user created by the program, it is shown once and should be changed
+func RandomPassword() (password string, err error) {
+	random := make([]byte, 12)
+	_, err = rand.Read(random)
+	if err != nil {
+		return
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// CheckPassword - check the password against the hash made by HashPassword, the empty hash matches no password
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, pbkdf2SHA256([]byte(password), salt, iterations, len(key))) == 1
+}
+
+// pbkdf2SHA256 - PBKDF2 key derivation (RFC 8018) with HMAC-SHA256
+// golang.org/x/crypto/pbkdf2 is not used: the project depends only on the standard library and go-sqlite3
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	var block [4]byte
+	for i := uint32(1); len(key) < keyLen; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := make([]byte, len(u))
+		copy(t, u)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// hashToken - the session is kept by the hash of the token, the token itself is known only to the user
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// Login - check the login and the password of the user and open a new session
+// Returns ErrLogin and userID 0 for the unknown login, the user without password or the wrong password
+//
+func Login(login, password string) (userID int64, token string, expires time.Time, err error) {
+	var id int64
+	var hash string
+	err = DB.QueryRow("SELECT id, password_hash FROM user WHERE login=?", login).Scan(&id, &hash)
+	if err == sql.ErrNoRows || err == nil && hash == "" {
+		dummyHash.once.Do(func() {
+			dummyHash.hash, _ = HashPassword("")
+		})
+		CheckPassword(dummyHash.hash, password)
+		err = ErrLogin
+	} else if err == nil && !CheckPassword(hash, password) {
+		err = ErrLogin
+	}
+	if err != nil {
+		return
+	}
+	userID = id
+
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		return
+	}
+	token = hex.EncodeToString(random)
+	expires = time.Now().UTC().Add(SessionLifetime).Truncate(time.Second)
+
+	// Expired sessions are removed at the login of anybody
+	_, err = DB.Exec("DELETE FROM session WHERE expires <= datetime('now')")
+	if err != nil {
+		return
+	}
+	_, err = DB.Exec("INSERT INTO session(token_hash, user_id, created, expires) VALUES(?, ?, datetime('now'), ?)",
+		hashToken(token), userID, expires.Format("2006-01-02 15:04:05"))
+	return
+}
+
+// SessionUser - user of the open session by the token, 0 if there is no such session or it is expired
+func SessionUser(token string) (userID int64, err error) {
+	err = DB.QueryRow("SELECT user_id FROM session WHERE token_hash=? AND expires > datetime('now')", hashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return
+}
+
+// Logout - close the session by the token
+func Logout(token string) (err error) {
+	_, err = DB.Exec("DELETE FROM session WHERE token_hash=?", hashToken(token))
+	return
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors of PBKDF2-HMAC-SHA256 from RFC 7914, section 11
+func TestPBKDF2SHA256(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		key            string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		key := hex.EncodeToString(pbkdf2SHA256([]byte(test.password), []byte(test.salt), test.iterations, len(test.key)/2))
+		if key != test.key {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d) = %s, want %s", test.password, test.salt, test.iterations, key, test.key)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "secret") {
+		t.Error("CheckPassword rejects the right password")
+	}
+	if CheckPassword(hash, "Secret") {
+		t.Error("CheckPassword accepts the wrong password")
+	}
+	if CheckPassword("", "") {
+		t.Error("CheckPassword accepts the empty hash")
+	}
+}
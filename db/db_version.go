@@ -0,0 +1,195 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// Owner of the regions: the project (the active regions) or the version of the project (the snapshot)
+const (
+	RegionsOfProject = "project_id"
+	RegionsOfVersion = "version_id"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// SaveVersion - save the regions of the project with their params, components, parts, segments and results
+// as the named version of the project
+// Results of the version keep the prices at the price date of the project, frozen prices of the contracted project are kept as is
+//
+func SaveVersion(projectID int64, name, comment string, userID int64) (versionID int64, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var res sql.Result
+	res, err = tx.Exec("INSERT INTO project_version(project_id, name, date, user_id, comment) VALUES(?, ?, datetime('now'), ?, ?)",
+		projectID, name, userID, comment)
+	if err != nil {
+		return
+	}
+	versionID, err = res.LastInsertId()
+	if err != nil {
+		return
+	}
+
+	err = copyRegions(tx, RegionsOfProject, projectID, RegionsOfVersion, versionID)
+	if err != nil {
+		return
+	}
+
+	var frozen bool
+	err = tx.QueryRow(`SELECT count(*) > 0 FROM project p WHERE p.id=? AND `+ProjectFrozenSQL, projectID).Scan(&frozen)
+	if err != nil || frozen {
+		return
+	}
+	err = savePrices(tx, projectID, RegionsOfVersion, versionID)
+	return
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PromoteVersion - replace the regions of the project with the copy of the regions of the version
+// The version itself stays unchanged and is marked as the active version of the project
+//
+func PromoteVersion(projectID, versionID int64) (err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	_, err = tx.Exec("DELETE FROM region WHERE project_id=?", projectID)
+	if err != nil {
+		return
+	}
+	err = copyRegions(tx, RegionsOfVersion, versionID, RegionsOfProject, projectID)
+	if err != nil {
+		return
+	}
+	_, err = tx.Exec("UPDATE project SET version_id=? WHERE id=?", versionID, projectID)
+	return
+}
+
+// copyRegions - copy all the regions of the project or the version with their params, components, parts, segments and results
+// from and to - RegionsOfProject or RegionsOfVersion
+func copyRegions(tx *sql.Tx, from string, fromID int64, to string, toID int64) (err error) {
+	var regions []int64
+
+	var rows *sql.Rows
+	rows, err = tx.Query("SELECT id FROM region WHERE "+from+"=? ORDER BY id", fromID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		regions = append(regions, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	for _, id := range regions {
+		var res sql.Result
+		res, err = tx.Exec("INSERT INTO region(description, "+to+", tregion_id, nr) SELECT description, ?, tregion_id, nr FROM region WHERE id=?",
+			toID, id)
+		if err != nil {
+			return
+		}
+		var regionID int64
+		regionID, err = res.LastInsertId()
+		if err != nil {
+			return
+		}
+
+		for _, query := range []string{
+			"INSERT INTO param(tparam_id, region_id, value) SELECT tparam_id, ?, value FROM param WHERE region_id=? ORDER BY id",
+			"INSERT INTO segment(region_id, nr, length, angle, elevation) SELECT ?, nr, length, angle, elevation FROM segment WHERE region_id=? ORDER BY id",
+			`INSERT INTO result(tresult_id, region_id, nomenclature_id, value, price_date, price, cost_price)
+			SELECT tresult_id, ?, nomenclature_id, value, price_date, price, cost_price FROM result WHERE region_id=? ORDER BY id`,
+		} {
+			_, err = tx.Exec(query, regionID, id)
+			if err != nil {
+				return
+			}
+		}
+
+		err = copyComponents(tx, id, regionID)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// copyComponents - copy components of the region with their parts to another region
+func copyComponents(tx *sql.Tx, fromRegionID, toRegionID int64) (err error) {
+	var components []int64
+
+	var rows *sql.Rows
+	rows, err = tx.Query("SELECT id FROM component WHERE region_id=? ORDER BY id", fromRegionID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		components = append(components, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	rows.Close()
+
+	for _, id := range components {
+		var res sql.Result
+		res, err = tx.Exec("INSERT INTO component(tcomponent_id, region_id) SELECT tcomponent_id, ? FROM component WHERE id=?", toRegionID, id)
+		if err != nil {
+			return
+		}
+		var componentID int64
+		componentID, err = res.LastInsertId()
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec("INSERT INTO part(tpart_id, component_id, nomenclature_id) SELECT tpart_id, ?, nomenclature_id FROM part WHERE component_id=? ORDER BY id",
+			componentID, id)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// savePrices - save the prices at the price date of the project in the results of the regions of the project or the version
+func savePrices(tx *sql.Tx, projectID int64, regions string, id int64) (err error) {
+	_, err = tx.Exec(`UPDATE result SET (price_date, price, cost_price) = (
+		SELECT pr.date, pr.price, pr.cost_price FROM price pr, project p
+		WHERE p.id=? AND pr.nomenclature_id = result.nomenclature_id
+		AND pr.date = (SELECT max(date) FROM price WHERE nomenclature_id = result.nomenclature_id AND date <= `+PriceDateSQL+`))
+		WHERE region_id IN (SELECT id FROM region WHERE `+regions+`=?)`, projectID, id)
+	return
+}
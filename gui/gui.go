@@ -2,6 +2,7 @@ package gui
 
 import (
 	"html/template"
+	"knx/report"
 )
 
 var Templates map[string]*template.Template
@@ -9,4 +10,8 @@ var Templates map[string]*template.Template
 func init() {
 	Templates = make(map[string]*template.Template)
 	Templates["projects"] = template.Must(template.ParseFiles("../html/projects.html"))
+
+	// Templates of the documents are used by the API through the report package
+	Templates["offer"] = template.Must(template.New("offer.html").Funcs(report.TemplateFuncs).ParseFiles("../html/offer.html"))
+	report.Templates["offer"] = Templates["offer"]
 }
@@ -21,8 +21,17 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	// Return "projects" html created with JSON answer
 	defer Templates["projects"].Execute(w, &data)
 
-	// Ask API for the whole list of projects
-	resp, err := http.Get("http://localhost:8080/v0/projects")
+	// Ask API for the whole list of projects in the session of the user
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8080/v0/projects", nil)
+	if err != nil {
+		data.Message = err.Error()
+		return
+	}
+	if cookie, err := r.Cookie(api.SessionCookie); err == nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		data.Message = err.Error()
 		return
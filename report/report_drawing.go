@@ -0,0 +1,229 @@
+// Печатные формы: чертежи, коммерческие предложения, выгрузки
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"knx/calc"
+)
+
+// Elevation - исходные данные чертежа участка: развертка забора вдоль ломаной линии участка
+type Elevation struct {
+	Title        string
+	Height       float64        // Высота забора, в м
+	BottomSpace  float64        // Зазор снизу, в м
+	UpSpace      float64        // Выступ столбов сверху, в м
+	SlopeType    float64        // calc.SlopeStepped или calc.SlopeRaked
+	Segments     []calc.Segment //
+	Spans        []calc.Span    // Пролеты между столбами по порядку, см. calc.RegionSpans
+	ColumnWidth  float64        // Ширина столба, в м
+	StickHeights []float64      // Высоты рядов прожилин от земли, в м
+	SheetWidth   float64        // Ширина листа, в м, 0 - полотно без листов
+	Horizontal   bool           // Горизонтальные листы
+	Mesh         bool           // Полотно из панелей сетки
+
+	// Цвета: значения calc.Color, calc.NoColor - без покраски
+	ColumnColor int
+	StickColor  int
+	CanvasColor int
+}
+
+// Параметры чертежа, в пикселях
+const (
+	drawingScale  = 100 // Пикселей на метр
+	drawingMargin = 40
+	drawingLeft   = 90  // Отступ слева под размер высоты
+	drawingBottom = 100 // Отступ снизу под размерные линии
+)
+
+// Цвета чертежа
+const (
+	zincColor   = "#c0c4c8" // Оцинковка без покраски
+	steelColor  = "#4a4d52" // Металл без покраски
+	groundColor = "#8d6e4c"
+	dimColor    = "#333333"
+)
+
+// ColorHex - цвет из цветовой схемы в формате #rrggbb, def - если цвет не задан
+func ColorHex(value int, def string) string {
+	if value < 0 {
+		return def
+	}
+	return fmt.Sprintf("#%06x", value)
+}
+
+// SVG - чертеж участка в формате SVG: столбы, прожилины, листы полотна и размеры
+func (e Elevation) SVG() []byte {
+	// Координаты столбов по горизонтали и высота земли у каждого столба, в м
+	x := []float64{0}
+	ground := []float64{0}
+	for _, span := range e.Spans {
+		x = append(x, x[len(x)-1]+span.Length)
+		ground = append(ground, ground[len(ground)-1]+span.Rise)
+	}
+
+	// Полотно каждого пролета: низ и верх в начале и конце пролета
+	type canvas struct{ bottom0, bottom1, top0, top1 float64 }
+	canvases := make([]canvas, len(e.Spans))
+	for i := range e.Spans {
+		if e.SlopeType == calc.SlopeRaked {
+			canvases[i] = canvas{ground[i] + e.BottomSpace, ground[i+1] + e.BottomSpace, ground[i] + e.Height, ground[i+1] + e.Height}
+		} else {
+			base := math.Max(ground[i], ground[i+1])
+			canvases[i] = canvas{base + e.BottomSpace, base + e.BottomSpace, base + e.Height, base + e.Height}
+		}
+	}
+
+	// Верх столбов: выступ над самым высоким из соседних пролетов
+	tops := make([]float64, len(x))
+	for j := range x {
+		tops[j] = ground[j] + e.Height
+		if j > 0 {
+			tops[j] = math.Max(tops[j], canvases[j-1].top1)
+		}
+		if j < len(canvases) {
+			tops[j] = math.Max(tops[j], canvases[j].top0)
+		}
+		tops[j] += e.UpSpace
+	}
+
+	// Границы чертежа
+	maxTop, minGround := e.Height+e.UpSpace, 0.0
+	for j := range x {
+		maxTop = math.Max(maxTop, tops[j])
+		minGround = math.Min(minGround, ground[j])
+	}
+	width := x[len(x)-1]*drawingScale + drawingLeft + drawingMargin
+	height := (maxTop-minGround)*drawingScale + drawingMargin*2 + drawingBottom
+	px := func(v float64) float64 { return drawingLeft + v*drawingScale }
+	py := func(v float64) float64 { return drawingMargin*2 + (maxTop-v)*drawingScale }
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %[1]s %[2]s" font-family="sans-serif" font-size="12">`+"\n",
+		num(width), num(height))
+	fmt.Fprintf(&b, `<defs><pattern id="mesh" width="10" height="20" patternUnits="userSpaceOnUse">`+
+		`<path d="M0 0V20M0 0H10" stroke="%s" stroke-width="1.5" fill="none"/></pattern></defs>`+"\n", ColorHex(e.CanvasColor, zincColor))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="16">%s</text>`+"\n", drawingMargin, drawingMargin, escape(e.Title))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">Размеры в метрах</text>`+"\n", drawingMargin, drawingMargin+18, dimColor)
+
+	// Земля
+	b.WriteString(`<polyline fill="none" stroke="` + groundColor + `" stroke-width="2" points="`)
+	for j := range x {
+		fmt.Fprintf(&b, "%s,%s ", num(px(x[j])), num(py(ground[j])))
+	}
+	b.WriteString("\"/>\n")
+
+	// Полотно и листы
+	segmentStart := make(map[int]float64)
+	for i, span := range e.Spans {
+		if _, ok := segmentStart[span.Segment]; !ok {
+			segmentStart[span.Segment] = x[i]
+		}
+	}
+	for i, c := range canvases {
+		x0, x1 := x[i], x[i+1]
+		fill := ColorHex(e.CanvasColor, zincColor)
+		if e.Mesh {
+			fill = "url(#mesh)"
+		}
+		fmt.Fprintf(&b, `<polygon fill="%s" stroke="%s" points="%s,%s %s,%s %s,%s %s,%s"/>`+"\n", fill, steelColor,
+			num(px(x0)), num(py(c.bottom0)), num(px(x1)), num(py(c.bottom1)), num(px(x1)), num(py(c.top1)), num(px(x0)), num(py(c.top0)))
+
+		if e.SheetWidth <= 0 || e.Mesh {
+			continue
+		}
+		at := func(v0, v1, xv float64) float64 { return v0 + (v1-v0)*(xv-x0)/(x1-x0) }
+		if e.Horizontal {
+			// Стыки горизонтальных листов по высоте полотна
+			for h := e.SheetWidth; h < e.Height-e.BottomSpace-0.01; h += e.SheetWidth {
+				fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="0.5"/>`+"\n",
+					num(px(x0)), num(py(c.bottom0+h)), num(px(x1)), num(py(c.bottom1+h)), steelColor)
+			}
+		} else {
+			// Стыки вертикальных листов от начала отрезка
+			start := segmentStart[e.Spans[i].Segment]
+			for xv := start + e.SheetWidth*math.Ceil((x0-start)/e.SheetWidth+1e-6); xv < x1-0.01; xv += e.SheetWidth {
+				fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="0.5"/>`+"\n",
+					num(px(xv)), num(py(at(c.bottom0, c.bottom1, xv))), num(px(xv)), num(py(at(c.top0, c.top1, xv))), steelColor)
+			}
+		}
+	}
+
+	// Прожилины за полотном - штриховые линии
+	for i, c := range canvases {
+		for _, h := range e.StickHeights {
+			y0, y1 := ground[i]+h, ground[i+1]+h
+			if e.SlopeType != calc.SlopeRaked {
+				y0 = c.bottom0 - e.BottomSpace + h
+				y1 = y0
+			}
+			fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="3" stroke-dasharray="8,4"/>`+"\n",
+				num(px(x[i])), num(py(y0)), num(px(x[i+1])), num(py(y1)), ColorHex(e.StickColor, steelColor))
+		}
+	}
+
+	// Столбы, угловые столбы отмечены углом поворота
+	cw := math.Max(e.ColumnWidth*drawingScale, 3)
+	for j := range x {
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s" stroke="%s"/>`+"\n",
+			num(px(x[j])-cw/2), num(py(tops[j])), num(cw), num((tops[j]-ground[j])*drawingScale), ColorHex(e.ColumnColor, steelColor), steelColor)
+	}
+	for i, span := range e.Spans {
+		if (i == 0 || e.Spans[i-1].Segment != span.Segment) && calc.IsCorner(e.Segments, span.Segment) {
+			fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" fill="%s">%s°</text>`+"\n",
+				num(px(x[i])), num(py(tops[i])-6), dimColor, num(e.Segments[span.Segment].Angle))
+		}
+	}
+
+	// Размеры: пролеты, отрезки, высота забора
+	dimY := py(minGround) + 30
+	for i := range e.Spans {
+		dimension(&b, px(x[i]), px(x[i+1]), dimY, e.Spans[i].Length)
+	}
+	for j := range x {
+		fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%[1]s" y2="%s" stroke="%s" stroke-width="0.5"/>`+"\n",
+			num(px(x[j])), num(py(ground[j])), num(dimY+5), dimColor)
+	}
+	if len(e.Segments) > 1 {
+		for s, segment := range e.Segments {
+			start, ok := segmentStart[s]
+			if !ok {
+				continue
+			}
+			dimension(&b, px(start), px(start+segment.Length), dimY+35, segment.Length)
+		}
+	}
+	if len(canvases) > 0 {
+		hx := float64(drawingLeft - 40)
+		fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%[1]s" y2="%s" stroke="%s"/>`+"\n", num(hx), num(py(ground[0])), num(py(canvases[0].top0)), dimColor)
+		fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" transform="rotate(-90 %[1]s %[2]s)" fill="%s">%s</text>`+"\n",
+			num(hx-6), num((py(ground[0])+py(canvases[0].top0))/2), dimColor, num(e.Height))
+	}
+
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// dimension - горизонтальная размерная линия от x0 до x1 с засечками и значением длины в м
+func dimension(b *bytes.Buffer, x0, x1, y, length float64) {
+	fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%[2]s" stroke="%s"/>`, num(x0), num(y), num(x1), dimColor)
+	fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%[1]s" y2="%s" stroke="%s"/>`, num(x0), num(y-5), num(y+5), dimColor)
+	fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%[1]s" y2="%s" stroke="%s"/>`, num(x1), num(y-5), num(y+5), dimColor)
+	fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle" fill="%s">%s</text>`+"\n", num((x0+x1)/2), num(y-4), dimColor, num(length))
+}
+
+// num - число для вывода: не больше трех знаков после запятой, без лишних нулей
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// escape - экранирование текста для XML
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
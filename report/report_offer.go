@@ -0,0 +1,233 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// Company - реквизиты компании для шапки документов
+type Company struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+}
+
+// Contact - заказчик или менеджер в документе
+type Contact struct {
+	Name     string
+	Position string
+	Phone    string
+}
+
+// Offer - коммерческое предложение по проекту
+type Offer struct {
+	Company       Company
+	Number        string
+	Date          time.Time
+	ValidUntil    time.Time // Срок действия предложения
+	Client        Contact
+	Manager       Contact
+	Address       string // Адрес объекта
+	Regions       []OfferRegion
+	Total         int64 // Итого по ценам, в копейках
+	MissingPrices int   // Количество позиций без цены
+}
+
+// OfferRegion - участок в коммерческом предложении
+type OfferRegion struct {
+	Title       string
+	Description string        // Основные размеры участка
+	Drawing     template.HTML // Чертеж участка в формате SVG, только в HTML
+	Lines       []OfferLine
+	Total       int64
+}
+
+// OfferLine - позиция спецификации участка
+type OfferLine struct {
+	Name       string
+	VendorCode string
+	Unit       string
+	Quantity   float64
+	Price      int64 // Цена за единицу, в копейках
+	Priced     bool  // Цена на дату предложения задана
+	Total      int64
+}
+
+// Templates - шаблоны документов, загружаются пакетом gui из ../html вместе с шаблонами страниц
+// "offer" - коммерческое предложение, ../html/offer.html
+var Templates map[string]*template.Template = make(map[string]*template.Template)
+
+// TemplateFuncs - функции шаблонов документов
+var TemplateFuncs template.FuncMap = template.FuncMap{
+	"money":    Money,
+	"quantity": Quantity,
+	"date":     func(t time.Time) string { return t.Format(DateLayout) },
+	"inc":      func(i int) int { return i + 1 },
+}
+
+// Формат дат в документах
+const DateLayout = "02.01.2006"
+
+// Money - сумма в копейках в формате "12 345,67"
+func Money(kopecks int64) string {
+	sign := ""
+	if kopecks < 0 {
+		sign, kopecks = "-", -kopecks
+	}
+	rubles := fmt.Sprint(kopecks / 100)
+	for i := len(rubles) - 3; i > 0; i -= 3 {
+		rubles = rubles[:i] + " " + rubles[i:]
+	}
+	return fmt.Sprintf("%s%s,%02d", sign, rubles, kopecks%100)
+}
+
+// Quantity - количество с запятой в качестве десятичного разделителя
+func Quantity(v float64) string {
+	return strings.Replace(num(v), ".", ",", 1)
+}
+
+// HTML - коммерческое предложение в формате HTML по шаблону Templates["offer"]
+func (o Offer) HTML() ([]byte, error) {
+	t := Templates["offer"]
+	if t == nil {
+		return nil, fmt.Errorf("Шаблон коммерческого предложения не загружен")
+	}
+	var b bytes.Buffer
+	err := t.Execute(&b, o)
+	return b.Bytes(), err
+}
+
+// Поля и колонки спецификации в PDF, в пунктах
+const (
+	pdfMargin   = 40
+	pdfNr       = pdfMargin
+	pdfName     = pdfMargin + 22
+	pdfUnit     = 330
+	pdfQuantity = 410 // Правый край колонки
+	pdfPrice    = 480 // Правый край колонки
+	pdfTotal    = PageWidth - pdfMargin
+)
+
+// PDF - коммерческое предложение в формате PDF со шрифтом font, без чертежей участков
+func (o Offer) PDF(font *Font) []byte {
+	d := NewDocument(font)
+	y := float64(pdfMargin)
+
+	// need - переход на новую страницу, если до нижнего поля не помещается height
+	need := func(height float64) {
+		if y+height > PageHeight-pdfMargin {
+			d.AddPage()
+			y = pdfMargin
+		}
+	}
+	text := func(size float64, bold bool, s string) {
+		if s == "" {
+			return
+		}
+		for _, line := range font.Wrap(s, size, pdfTotal-pdfMargin) {
+			need(size * 1.4)
+			y += size * 1.4
+			d.Text(pdfMargin, y, size, bold, line)
+		}
+	}
+
+	// Company header
+	text(14, true, o.Company.Name)
+	contacts := []string{}
+	for _, s := range []string{o.Company.Address, o.Company.Phone, o.Company.Email} {
+		if s != "" {
+			contacts = append(contacts, s)
+		}
+	}
+	text(9, false, strings.Join(contacts, ", "))
+	y += 6
+	d.Line(pdfMargin, y, pdfTotal, y, 1)
+	y += 16
+
+	text(14, true, fmt.Sprintf("Коммерческое предложение № %s от %s", o.Number, o.Date.Format(DateLayout)))
+	y += 6
+	text(10, false, "Заказчик: "+contactLine(o.Client))
+	if o.Address != "" {
+		text(10, false, "Адрес объекта: "+o.Address)
+	}
+
+	for i, r := range o.Regions {
+		y += 12
+		need(60)
+		text(11, true, fmt.Sprintf("%d. %s", i+1, r.Title))
+		if r.Description != "" {
+			text(9, false, r.Description)
+		}
+		y += 4
+		header := func() {
+			y += 12
+			d.Text(pdfNr, y, 8, true, "№")
+			d.Text(pdfName, y, 8, true, "Наименование")
+			d.Text(pdfUnit, y, 8, true, "Ед.")
+			d.TextRight(pdfQuantity, y, 8, true, "Кол-во")
+			d.TextRight(pdfPrice, y, 8, true, "Цена, руб.")
+			d.TextRight(pdfTotal, y, 8, true, "Сумма, руб.")
+			d.Line(pdfMargin, y+4, pdfTotal, y+4, 0.5)
+		}
+		header()
+		for j, line := range r.Lines {
+			name := line.Name
+			if line.VendorCode != "" {
+				name += " (" + line.VendorCode + ")"
+			}
+			names := font.Wrap(name, 8, pdfUnit-pdfName-6)
+			if y+float64(len(names))*10+6 > PageHeight-pdfMargin {
+				d.AddPage()
+				y = pdfMargin
+				header()
+			}
+			y += 12
+			d.Text(pdfNr, y, 8, false, fmt.Sprint(j+1))
+			d.Text(pdfUnit, y, 8, false, line.Unit)
+			d.TextRight(pdfQuantity, y, 8, false, Quantity(line.Quantity))
+			price, total := "—", "—"
+			if line.Priced {
+				price, total = Money(line.Price), Money(line.Total)
+			}
+			d.TextRight(pdfPrice, y, 8, false, price)
+			d.TextRight(pdfTotal, y, 8, false, total)
+			for k, s := range names {
+				if k > 0 {
+					y += 10
+				}
+				d.Text(pdfName, y, 8, false, s)
+			}
+		}
+		need(20)
+		d.Line(pdfMargin, y+4, pdfTotal, y+4, 0.5)
+		y += 16
+		d.TextRight(pdfTotal, y, 9, true, "Итого по участку: "+Money(r.Total)+" руб.")
+	}
+
+	y += 16
+	need(80)
+	text(12, true, "Итого: "+Money(o.Total)+" руб.")
+	if o.MissingPrices > 0 {
+		text(9, false, fmt.Sprintf("Цены позиций без стоимости (%d) уточняются и в итог не включены.", o.MissingPrices))
+	}
+	text(10, false, "Предложение действительно до "+o.ValidUntil.Format(DateLayout)+".")
+	y += 12
+	text(10, false, "Менеджер: "+contactLine(o.Manager))
+
+	return d.Bytes()
+}
+
+// contactLine - контакт одной строкой: имя, должность, телефон
+func contactLine(c Contact) string {
+	line := c.Name
+	for _, s := range []string{c.Position, c.Phone} {
+		if s != "" {
+			line += ", " + s
+		}
+	}
+	return line
+}
@@ -0,0 +1,338 @@
+package report
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Размер страницы A4, в пунктах
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Font - шрифт TrueType для встраивания в PDF
+// Из файла шрифта читаются только таблицы, нужные для вывода текста: cmap, hmtx, hhea, head
+type Font struct {
+	Name       string
+	data       []byte
+	unitsPerEm float64
+	ascent     float64
+	descent    float64
+	bbox       [4]float64
+	advances   []uint16        // Ширина глифов, в единицах шрифта
+	cmap       []byte          // Таблица cmap формата 4: символы Unicode до U+FFFF
+	glyphs     map[rune]uint16 // Найденные глифы символов
+}
+
+// LoadFont - чтение шрифта TrueType из файла
+func LoadFont(path string) (*Font, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удается прочитать шрифт '%s' (%v)", path, err)
+	}
+	f, err := ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("Шрифт '%s': %v", path, err)
+	}
+	f.Name = strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	return f, nil
+}
+
+// FontPaths - файлы шрифтов с кириллицей, которые ищутся, если путь к шрифту не задан:
+// шрифт рядом с шаблонами документов, затем DejaVu Sans в каталогах шрифтов Debian, Fedora и Arch
+var FontPaths []string = []string{
+	"../html/fonts/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu-sans-fonts/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+}
+
+// FindFont - шрифт для PDF: из файла path, если путь задан, иначе первый найденный из FontPaths
+func FindFont(path string) (*Font, error) {
+	if path != "" {
+		return LoadFont(path)
+	}
+	for _, p := range FontPaths {
+		if _, err := os.Stat(p); err == nil {
+			return LoadFont(p)
+		}
+	}
+	return nil, fmt.Errorf("Не найден шрифт TrueType с кириллицей для PDF: положите DejaVuSans.ttf в %s "+
+		"или задайте путь к шрифту параметром pdf_font (POST /company). Проверены: %s",
+		filepath.Dir(FontPaths[0]), strings.Join(FontPaths, ", "))
+}
+
+// ParseFont - разбор шрифта TrueType
+func ParseFont(data []byte) (f *Font, err error) {
+	// Reads are not bounds-checked: a broken file must not panic the server
+	defer func() {
+		if r := recover(); r != nil {
+			f, err = nil, fmt.Errorf("поврежденный файл шрифта")
+		}
+	}()
+
+	u16 := func(b []byte, off int) int { return int(binary.BigEndian.Uint16(b[off:])) }
+	i16 := func(b []byte, off int) float64 { return float64(int16(binary.BigEndian.Uint16(b[off:]))) }
+
+	tables := make(map[string][]byte)
+	for i := 0; i < u16(data, 4); i++ {
+		rec := data[12+16*i:]
+		off, length := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		tables[string(rec[:4])] = data[off : off+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "cmap"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("нет таблицы '%s', нужен шрифт TrueType", tag)
+		}
+	}
+
+	f = &Font{data: data, glyphs: make(map[rune]uint16)}
+	head, hhea, hmtx := tables["head"], tables["hhea"], tables["hmtx"]
+	f.unitsPerEm = float64(u16(head, 18))
+	f.bbox = [4]float64{i16(head, 36), i16(head, 38), i16(head, 40), i16(head, 42)}
+	f.ascent, f.descent = i16(hhea, 4), i16(hhea, 6)
+	for i := 0; i < u16(hhea, 34); i++ {
+		f.advances = append(f.advances, uint16(u16(hmtx, 4*i)))
+	}
+
+	// Unicode BMP subtable: Windows Unicode (3,1) or Unicode platform (0,*)
+	cmap := tables["cmap"]
+	for i := 0; i < u16(cmap, 2); i++ {
+		platform, encoding := u16(cmap, 4+8*i), u16(cmap, 6+8*i)
+		sub := cmap[binary.BigEndian.Uint32(cmap[8+8*i:]):]
+		if (platform == 3 && encoding == 1 || platform == 0) && u16(sub, 0) == 4 {
+			f.cmap = sub[:u16(sub, 2)]
+			break
+		}
+	}
+	if f.cmap == nil || f.unitsPerEm == 0 || len(f.advances) == 0 {
+		return nil, fmt.Errorf("нет таблицы символов Unicode")
+	}
+	return f, nil
+}
+
+// glyph - номер глифа символа r, 0 - символа нет в шрифте
+func (f *Font) glyph(r rune) uint16 {
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+	var g uint16
+	c := int(r)
+	b := f.cmap
+	u16 := func(off int) int { return int(binary.BigEndian.Uint16(b[off:])) }
+	segCount := u16(6) / 2
+	for i := 0; i < segCount && c <= 0xFFFF; i++ {
+		end := u16(14 + 2*i)
+		if c > end {
+			continue
+		}
+		start := u16(16 + 2*segCount + 2*i)
+		delta := u16(16 + 4*segCount + 2*i)
+		rangeOffsetPos := 16 + 6*segCount + 2*i
+		if c < start {
+			break
+		}
+		if rangeOffset := u16(rangeOffsetPos); rangeOffset == 0 {
+			g = uint16(c + delta)
+		} else if pos := rangeOffsetPos + rangeOffset + 2*(c-start); pos+2 <= len(b) {
+			if g = uint16(u16(pos)); g != 0 {
+				g = uint16(int(g) + delta)
+			}
+		}
+		break
+	}
+	f.glyphs[r] = g
+	return g
+}
+
+// advance - ширина глифа g в тысячных долях размера шрифта
+func (f *Font) advance(g uint16) float64 {
+	a := f.advances[len(f.advances)-1]
+	if int(g) < len(f.advances) {
+		a = f.advances[g]
+	}
+	return float64(a) * 1000 / f.unitsPerEm
+}
+
+// Width - ширина строки s при размере шрифта size, в пунктах
+func (f *Font) Width(s string, size float64) (width float64) {
+	for _, r := range s {
+		width += f.advance(f.glyph(r))
+	}
+	return width * size / 1000
+}
+
+// Wrap - разбиение текста на строки шириной не больше width по словам
+func (f *Font) Wrap(s string, size, width float64) (lines []string) {
+	var line string
+	for _, word := range strings.Fields(s) {
+		if line != "" && f.Width(line+" "+word, size) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// Document - документ PDF из страниц A4 с текстом одним шрифтом и линиями
+// Координаты - в пунктах от левого верхнего угла страницы
+type Document struct {
+	font  *Font
+	pages []*bytes.Buffer
+	used  map[uint16]rune // Глифы, выведенные в документе, и их символы
+}
+
+// NewDocument - новый документ с одной пустой страницей
+func NewDocument(font *Font) *Document {
+	d := &Document{font: font, used: make(map[uint16]rune)}
+	d.AddPage()
+	return d
+}
+
+// AddPage - добавление новой страницы, дальнейший вывод идет на нее
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, new(bytes.Buffer))
+}
+
+// Text - вывод строки s от точки (x, y) на базовой линии шрифта, bold - полужирным начертанием
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	var hex bytes.Buffer
+	for _, r := range s {
+		g := d.font.glyph(r)
+		if _, ok := d.used[g]; !ok {
+			d.used[g] = r
+		}
+		fmt.Fprintf(&hex, "%04X", g)
+	}
+	page := d.pages[len(d.pages)-1]
+	if bold {
+		// Bold is drawn with the same font: fill and stroke the outlines
+		fmt.Fprintf(page, "q 2 Tr %s w ", num(size/30))
+	}
+	fmt.Fprintf(page, "BT /F1 %s Tf %s %s Td <%s> Tj ET", num(size), num(x), num(PageHeight-y), hex.String())
+	if bold {
+		page.WriteString(" Q")
+	}
+	page.WriteString("\n")
+}
+
+// TextRight - вывод строки s, выровненной по правому краю x
+func (d *Document) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-d.font.Width(s, size), y, size, bold, s)
+}
+
+// Line - отрезок от (x0, y0) до (x1, y1) толщиной width
+func (d *Document) Line(x0, y0, x1, y1, width float64) {
+	fmt.Fprintf(d.pages[len(d.pages)-1], "%s w %s %s m %s %s l S\n", num(width), num(x0), num(PageHeight-y0), num(x1), num(PageHeight-y1))
+}
+
+// Bytes - документ в формате PDF
+// Шрифт встраивается целиком как CIDFontType2 с кодировкой Identity-H, поэтому выводится любой символ шрифта
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	var offsets []int
+	object := func(format string, a ...interface{}) int {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&b, format, a...)
+		b.WriteString("\nendobj\n")
+		return len(offsets)
+	}
+	stream := func(data []byte, dict string) int {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write(data)
+		w.Close()
+		return object("<< /Length %d /Filter /FlateDecode %s>>\nstream\n%s\nendstream", z.Len(), dict, z.Bytes())
+	}
+
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 and 2 are the catalog and the page tree, pages follow the font
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	offsets = append(offsets, 0)
+
+	// Font: widths and Unicode mapping of the used glyphs
+	f := d.font
+	glyphs := make([]int, 0, len(d.used))
+	for g := range d.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+	var widths, toUnicode bytes.Buffer
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, "%d [%s] ", g, num(f.advance(uint16(g))))
+	}
+	toUnicode.WriteString("/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def /CMapType 2 def\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n")
+	for i := 0; i < len(glyphs); i += 100 {
+		chunk := glyphs[i:]
+		if len(chunk) > 100 {
+			chunk = chunk[:100]
+		}
+		fmt.Fprintf(&toUnicode, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			r := d.used[uint16(g)]
+			if r > 0xFFFF {
+				r = 0xFFFD
+			}
+			fmt.Fprintf(&toUnicode, "<%04X> <%04X>\n", g, r)
+		}
+		toUnicode.WriteString("endbfchar\n")
+	}
+	toUnicode.WriteString("endcmap CMapName currentdict /CMap defineresource pop end end")
+
+	scale := 1000 / f.unitsPerEm
+	fontFile := stream(f.data, fmt.Sprintf("/Length1 %d ", len(f.data)))
+	descriptor := object("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%s %s %s %s] /ItalicAngle 0 "+
+		"/Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %d 0 R >>", f.Name,
+		num(f.bbox[0]*scale), num(f.bbox[1]*scale), num(f.bbox[2]*scale), num(f.bbox[3]*scale),
+		num(f.ascent*scale), num(f.descent*scale), num(f.ascent*scale), fontFile)
+	cidFont := object("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW 1000 /W [%s] >>", f.Name, descriptor, widths.String())
+	unicodeMap := stream(toUnicode.Bytes(), "")
+	font := object("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.Name, cidFont, unicodeMap)
+
+	var kids bytes.Buffer
+	for _, page := range d.pages {
+		content := stream(page.Bytes(), "")
+		p := object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), font, content)
+		fmt.Fprintf(&kids, "%d 0 R ", p)
+	}
+
+	// Page tree is written last, when the page objects are known
+	offsets[1] = b.Len()
+	fmt.Fprintf(&b, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", kids.String(), len(d.pages))
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.Bytes()
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table - лист выгрузки: заголовок и строки значений
+// Значения: string, float64 или Kopecks
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Kopecks - сумма в копейках: в XLSX выводится числом в рублях, в CSV - в формате "12345,67"
+type Kopecks int64
+
+// Разделитель CSV, тот же, что в файлах импорта номенклатуры
+const CSVComma = ';'
+
+// CSV - выгрузка листов в один файл CSV: название листа, заголовок и строки, листы разделены пустой строкой
+// Числа выводятся с запятой в качестве десятичного разделителя
+func CSV(tables []Table) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Comma = CSVComma
+	for i, t := range tables {
+		if i > 0 {
+			w.Write([]string{})
+		}
+		if len(tables) > 1 {
+			w.Write([]string{t.Name})
+		}
+		w.Write(t.Header)
+		for _, row := range t.Rows {
+			record := make([]string, len(row))
+			for j, v := range row {
+				record[j] = csvValue(v)
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// csvValue - значение ячейки для CSV
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return Quantity(v)
+	case Kopecks:
+		return strings.Replace(Money(int64(v)), "\u00a0", "", -1)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// SheetName - допустимое название листа: без символов []:*?/\, не длиннее 31 символа, не совпадает с used
+func SheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Лист"
+	}
+	unique := name
+	for i := 2; ; i++ {
+		unique = truncate(unique, 31)
+		if !used[strings.ToLower(unique)] {
+			break
+		}
+		suffix := fmt.Sprintf(" (%d)", i)
+		unique = truncate(name, 31-len(suffix)) + suffix
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// truncate - первые n символов строки s
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,163 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Стили ячеек XLSX, индексы cellXfs в xlsxStyles
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleMoney   = 2
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+%s</Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="#,##0.00"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+// XLSX - выгрузка листов в книгу Excel (Office Open XML)
+// Строки записываются в ячейки как есть (inline strings), суммы Kopecks - числом в рублях с форматом денег
+func XLSX(tables []Table) ([]byte, error) {
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	file := func(name, content string) error {
+		w, err := z.Create(name)
+		if err == nil {
+			_, err = w.Write([]byte(content))
+		}
+		return err
+	}
+
+	var overrides, sheets, rels bytes.Buffer
+	used := make(map[string]bool)
+	for i, t := range tables {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", i+1)
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%[2]d"/>`, escape(SheetName(t.Name, used)), i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%[1]d.xml"/>`+"\n", i+1)
+		if err := file(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxSheet(t)); err != nil {
+			return nil, err
+		}
+	}
+	// Styles relationship goes after the sheets
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`+"\n", len(tables)+1)
+
+	for _, f := range []struct{ name, content string }{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, overrides.String())},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + "\n" + rels.String() + `</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		if err := file(f.name, f.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// xlsxSheet - лист книги: ширина колонок по содержимому, заголовок полужирным и закреплен
+func xlsxSheet(t Table) string {
+	rows := append([][]interface{}{make([]interface{}, len(t.Header))}, t.Rows...)
+	for j, h := range t.Header {
+		rows[0][j] = h
+	}
+
+	// Column widths in characters
+	var widths []int
+	for _, row := range rows {
+		for j, v := range row {
+			for len(widths) <= j {
+				widths = append(widths, 8)
+			}
+			if n := utf8.RuneCountInString(csvValue(v)) + 2; n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(t.Header) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	if len(widths) > 0 {
+		b.WriteString("<cols>")
+		for j, w := range widths {
+			fmt.Fprintf(&b, `<col min="%d" max="%[1]d" width="%d" customWidth="1"/>`, j+1, int(math.Min(float64(w), 80)))
+		}
+		b.WriteString("</cols>")
+	}
+	b.WriteString("<sheetData>\n")
+	for i, row := range rows {
+		if i == 0 && len(t.Header) == 0 {
+			continue
+		}
+		style := xlsxStyleDefault
+		if i == 0 {
+			style = xlsxStyleHeader
+		}
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, v := range row {
+			if v == nil || v == "" {
+				continue
+			}
+			ref := xlsxColumn(j) + strconv.Itoa(i+1)
+			switch v := v.(type) {
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+			case Kopecks:
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleMoney, strconv.FormatFloat(float64(v)/100, 'f', 2, 64))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(fmt.Sprint(v)))
+			}
+		}
+		b.WriteString("</row>\n")
+	}
+	b.WriteString("</sheetData></worksheet>")
+	return b.String()
+}
+
+// xlsxColumn - буквенное обозначение колонки по индексу: 0 - A, 25 - Z, 26 - AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}